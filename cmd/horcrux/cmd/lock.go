@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/strangelove-ventures/horcrux/v3/signer"
+
+	cometproto "github.com/cometbft/cometbft/proto/tendermint/types"
+)
+
+func lockCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Commands to inspect the horcrux signer's consensus lock",
+	}
+
+	cmd.AddCommand(evalLockCmd())
+
+	return cmd
+}
+
+func evalLockCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "eval",
+		Short: "Evaluate whether a sign request would be allowed by the consensus lock of a sign state file",
+		Long: "Evaluate whether a sign request would be allowed by the consensus lock of a sign state file.\n\n" +
+			"The step is 1 (propose), 2 (prevote) or 3 (precommit). An empty value evaluates a vote for nil.\n" +
+			"Prevotes are evaluated without a POL round, so a prevote for a value other than the locked one is denied.",
+		Example:      `horcrux lock eval --state state/cosmoshub-4_priv_validator_state.json --hrs 100/5/2 --value 0a1b...`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			statePath, _ := cmd.Flags().GetString("state")
+			hrsArg, _ := cmd.Flags().GetString("hrs")
+			valueArg, _ := cmd.Flags().GetString("value")
+			chainID, _ := cmd.Flags().GetString("chain-id")
+
+			hrs, err := parseHRS(hrsArg)
+			if err != nil {
+				return err
+			}
+
+			value, err := hex.DecodeString(valueArg)
+			if err != nil {
+				return fmt.Errorf("invalid value %q, expected a hex encoded block hash: %w", valueArg, err)
+			}
+
+			signState, err := signer.LoadSignState(statePath)
+			if err != nil {
+				return err
+			}
+
+			decision, _ := signState.Evaluate(hrs, evalSignBytes(chainID, hrs, value))
+
+			out := cmd.OutOrStdout()
+			if decision.Allowed {
+				fmt.Fprintf(out, "allow: %s\n", decision.Reason)
+			} else {
+				fmt.Fprintf(out, "deny: %s\n", decision.Reason)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().String("state", "", "path to the sign state file")
+	cmd.Flags().String("hrs", "", "height/round/step of the sign request, e.g. 100/5/2")
+	cmd.Flags().String("value", "", "hex encoded block hash of the sign request, empty for nil")
+	cmd.Flags().String("chain-id", "", "chain ID used to build the sign bytes")
+	_ = cmd.MarkFlagRequired("state")
+	_ = cmd.MarkFlagRequired("hrs")
+
+	return cmd
+}
+
+// parseHRS parses a height/round/step triple such as 100/5/2.
+func parseHRS(s string) (signer.HRSKey, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return signer.HRSKey{}, fmt.Errorf("invalid hrs %q, expected height/round/step", s)
+	}
+
+	height, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || height <= 0 {
+		return signer.HRSKey{}, fmt.Errorf("invalid height %q in hrs %q", parts[0], s)
+	}
+
+	round, err := strconv.ParseInt(parts[1], 10, 32)
+	if err != nil || round < 0 {
+		return signer.HRSKey{}, fmt.Errorf("invalid round %q in hrs %q", parts[1], s)
+	}
+
+	step, err := strconv.ParseInt(parts[2], 10, 8)
+	if err != nil || step < int64(signer.StepPropose) || step > int64(signer.StepPrecommit) {
+		return signer.HRSKey{}, fmt.Errorf(
+			"unknown step %q in hrs %q, expected 1 (propose), 2 (prevote) or 3 (precommit)", parts[2], s)
+	}
+
+	return signer.HRSKey{Height: height, Round: round, Step: int8(step)}, nil
+}
+
+// evalSignBytes builds the canonical sign bytes of a proposal or vote for value at hrs.
+// hrs.Step must be a known step.
+func evalSignBytes(chainID string, hrs signer.HRSKey, value []byte) []byte {
+	var blockID cometproto.BlockID
+	if len(value) > 0 {
+		blockID.Hash = value
+	}
+
+	msgType := signer.StepToType(hrs.Step)
+	if msgType == cometproto.ProposalType {
+		return signer.ProposalToBlock(chainID, &cometproto.Proposal{
+			Type:     msgType,
+			Height:   hrs.Height,
+			Round:    int32(hrs.Round),
+			PolRound: -1,
+			BlockID:  blockID,
+		}).SignBytes
+	}
+	return signer.VoteToBlock(chainID, &cometproto.Vote{
+		Type:    msgType,
+		Height:  hrs.Height,
+		Round:   int32(hrs.Round),
+		BlockID: blockID,
+	}).SignBytes
+}
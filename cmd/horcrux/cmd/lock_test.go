@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/strangelove-ventures/horcrux/v3/signer"
+	"github.com/stretchr/testify/require"
+
+	cometjson "github.com/cometbft/cometbft/libs/json"
+)
+
+func TestLockEvalCmd(t *testing.T) {
+	lockedValue := bytes.Repeat([]byte{0xaa}, 32)
+	otherValue := bytes.Repeat([]byte{0xbb}, 32)
+
+	stateBz, err := cometjson.Marshal(&signer.SignState{
+		Height: 100,
+		Round:  5,
+		Step:   3,
+		ConsensusLock: signer.ConsensusLock{
+			Height:    100,
+			Round:     5,
+			Value:     lockedValue,
+			UpdatedAt: time.Now(),
+			SetBy:     signer.HRSKey{Height: 100, Round: 5, Step: 3},
+		},
+	})
+	require.NoError(t, err)
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(statePath, stateBz, 0600))
+
+	tcs := []struct {
+		name      string
+		hrs       string
+		value     string
+		expectOut string
+		expectErr string
+	}{
+		{
+			name:      "prevote for locked value",
+			hrs:       "100/6/2",
+			value:     hex.EncodeToString(lockedValue),
+			expectOut: "allow:",
+		},
+		{
+			name:      "prevote for other value",
+			hrs:       "100/6/2",
+			value:     hex.EncodeToString(otherValue),
+			expectOut: "deny:",
+		},
+		{
+			name:      "prevote for nil",
+			hrs:       "100/6/2",
+			value:     "",
+			expectOut: "allow:",
+		},
+		{
+			name:      "invalid hex",
+			hrs:       "100/6/2",
+			value:     "not-hex",
+			expectErr: "invalid value",
+		},
+		{
+			name:      "unknown step",
+			hrs:       "100/6/4",
+			value:     hex.EncodeToString(lockedValue),
+			expectErr: "unknown step",
+		},
+		{
+			name:      "malformed hrs",
+			hrs:       "100/6",
+			value:     hex.EncodeToString(lockedValue),
+			expectErr: "invalid hrs",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := evalLockCmd()
+			cmd.SetOut(&out)
+			cmd.SetErr(&out)
+			cmd.SetArgs([]string{"--state", statePath, "--hrs", tc.hrs, "--value", tc.value})
+			err := cmd.Execute()
+
+			if tc.expectErr != "" {
+				require.ErrorContains(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(out.String(), tc.expectOut), out.String())
+		})
+	}
+}
@@ -39,6 +39,7 @@ horcrux create-ecies-shards
 	cmd.AddCommand(leaderElectionCmd())
 	cmd.AddCommand(getLeaderCmd())
 	cmd.AddCommand(stateCmd())
+	cmd.AddCommand(lockCmd())
 	cmd.AddCommand(versionCmd())
 
 	cmd.PersistentFlags().StringVar(
@@ -71,6 +71,9 @@ func startCmd() *cobra.Command {
 
 			go EnableDebugAndMetrics(cmd.Context(), out)
 
+			// Prime the sign bytes decoder so the first sign request isn't slowed by lazy initialization
+			signer.WarmUpDecoder()
+
 			services, err = signer.StartRemoteSigners(services, logger, val, config.Config.Nodes(), config.Config.MaxReadSize)
 			if err != nil {
 				return fmt.Errorf("failed to start remote signer(s): %w", err)
@@ -34,6 +34,7 @@ func stateCmd() *cobra.Command {
 	cmd.AddCommand(showStateCmd())
 	cmd.AddCommand(setStateCmd())
 	cmd.AddCommand(importStateCmd())
+	cmd.AddCommand(unhaltStateCmd())
 
 	return cmd
 }
@@ -232,6 +233,52 @@ func importStateCmd() *cobra.Command {
 	}
 }
 
+func unhaltStateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unhalt chain-id",
+		Short: "Let the signer sign again for a specific chain-id after it halted on a double sign attempt",
+		Long: "Let the signer sign again for a specific chain-id after it halted on a double sign attempt.\n\n" +
+			"Find out why the signer was asked to sign conflicting data before unhalting it.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chainID := args[0]
+
+			out := cmd.OutOrStdout()
+			logger := cometlog.NewTMLogger(cometlog.NewSyncWriter(out))
+
+			if _, err := os.Stat(config.HomeDir); os.IsNotExist(err) {
+				cmd.SilenceUsage = false
+				return fmt.Errorf("%s does not exist, initialize config with horcrux config init and try again", config.HomeDir)
+			}
+
+			// The running signer would overwrite the state files
+			if err := signer.RequireNotRunning(logger, config.PidFile); err != nil {
+				return err
+			}
+
+			for _, path := range []string{config.PrivValStateFile(chainID), config.CosignerStateFile(chainID)} {
+				if _, err := os.Stat(path); os.IsNotExist(err) {
+					continue
+				}
+				ss, err := signer.LoadSignState(path)
+				if err != nil {
+					return err
+				}
+				if !ss.Halted {
+					fmt.Fprintf(out, "%s is not halted\n", path)
+					continue
+				}
+				if err := ss.Unhalt(); err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "Unhalted %s\n", path)
+			}
+			return nil
+		},
+	}
+}
+
 func printSignState(out io.Writer, ss *signer.SignState) {
 	fmt.Fprintf(out, "  Height:    %v\n"+
 		"  Round:     %v\n"+
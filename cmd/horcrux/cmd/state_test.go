@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"bytes"
 	"io"
+	"os"
 	"path/filepath"
 	"strconv"
 	"testing"
@@ -9,6 +11,8 @@ import (
 
 	"github.com/strangelove-ventures/horcrux/v3/signer"
 	"github.com/stretchr/testify/require"
+
+	cometjson "github.com/cometbft/cometbft/libs/json"
 )
 
 func TestStateSetCmd(t *testing.T) {
@@ -85,3 +89,40 @@ func TestStateSetCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestStateUnhaltCmd(t *testing.T) {
+	tmpHome := t.TempDir()
+	tmpConfig := filepath.Join(tmpHome, ".horcrux")
+	stateDir := filepath.Join(tmpHome, ".horcrux", "state")
+
+	chainID := "horcrux-1"
+
+	cmd := rootCmd()
+	cmd.SetOutput(io.Discard)
+	cmd.SetArgs([]string{
+		"--home", tmpConfig,
+		"config", "init",
+		"-n", "tcp://10.168.0.1:1234",
+		"-t", "2",
+		"-c", "tcp://10.168.1.1:2222,tcp://10.168.1.2:2222,tcp://10.168.1.3:2222",
+	})
+	require.NoError(t, cmd.Execute())
+
+	require.NoError(t, os.MkdirAll(stateDir, 0700))
+	statePath := filepath.Join(stateDir, chainID+"_priv_validator_state.json")
+	stateBz, err := cometjson.Marshal(&signer.SignState{Height: 100, Round: 0, Step: 2, Halted: true})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(statePath, stateBz, 0600))
+
+	cmd = unhaltStateCmd()
+	var out bytes.Buffer
+	cmd.SetOutput(&out)
+	cmd.SetArgs([]string{chainID})
+	require.NoError(t, cmd.Execute())
+	require.Contains(t, out.String(), "Unhalted "+statePath)
+
+	ss, err := signer.LoadSignState(statePath)
+	require.NoError(t, err)
+	require.False(t, ss.Halted)
+	require.Equal(t, int64(100), ss.Height)
+}
@@ -0,0 +1,103 @@
+package signer
+
+import (
+	"bytes"
+	"sync"
+)
+
+// approvalCounter counts sign requests per height for the most recent heights, and
+// remembers the last one counted with add so that an immediate repeat can be spotted.
+type approvalCounter struct {
+	mu     sync.Mutex
+	counts map[int64]int
+
+	// last approved request, used to detect duplicates
+	lastHRS       HRSKey
+	lastSignBytes []byte
+	hasLast       bool
+}
+
+// add counts an approval of signBytes at hrs and forgets heights that have fallen out of the cache window.
+// It returns true if the approval repeats the immediately prior one: same HRS and, according to
+// sameValue, the same value. sameValue is only called for a repeated HRS with different sign bytes,
+// so that the common case does not decode the sign bytes.
+func (c *approvalCounter) add(hrs HRSKey, signBytes []byte, sameValue func(a, b []byte) bool) (duplicate bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	duplicate = c.hasLast && c.lastHRS == hrs &&
+		(bytes.Equal(c.lastSignBytes, signBytes) || sameValue(c.lastSignBytes, signBytes))
+	c.lastHRS, c.lastSignBytes, c.hasLast = hrs, signBytes, true
+	c.lockedInc(hrs.Height)
+	return duplicate
+}
+
+// inc counts an event at height without duplicate tracking, and forgets heights that have
+// fallen out of the cache window.
+func (c *approvalCounter) inc(height int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lockedInc(height)
+}
+
+// lockedInc increments the count of height. Not thread-safe (requires c.mu).
+func (c *approvalCounter) lockedInc(height int64) {
+	if c.counts == nil {
+		c.counts = make(map[int64]int)
+	}
+	c.counts[height]++
+	for h := range c.counts {
+		if h < height-blocksToCache {
+			delete(c.counts, h)
+		}
+	}
+}
+
+// clone returns a copy of c.
+func (c *approvalCounter) clone() approvalCounter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[int64]int, len(c.counts))
+	for h, count := range c.counts {
+		counts[h] = count
+	}
+	return approvalCounter{
+		counts:        counts,
+		lastHRS:       c.lastHRS,
+		lastSignBytes: append([]byte(nil), c.lastSignBytes...),
+		hasLast:       c.hasLast,
+	}
+}
+
+// prune forgets the counts of heights below minHeight.
+func (c *approvalCounter) prune(minHeight int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for h := range c.counts {
+		if h < minHeight {
+			delete(c.counts, h)
+		}
+	}
+}
+
+// total returns the sum of the counts of all tracked heights.
+func (c *approvalCounter) total() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var total int
+	for _, count := range c.counts {
+		total += count
+	}
+	return total
+}
+
+func (c *approvalCounter) get(height int64) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[height]
+}
+
+// ApprovalsAtHeight returns how many sign requests at height h passed consensus lock
+// validation. Only recent heights are tracked; older heights report zero.
+func (signState *SignState) ApprovalsAtHeight(h int64) int {
+	return signState.approvals.get(h)
+}
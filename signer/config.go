@@ -1,6 +1,7 @@
 package signer
 
 import (
+	"encoding/hex"
 	"fmt"
 	"net"
 	"net/url"
@@ -36,6 +37,10 @@ type Config struct {
 	DebugAddr           string               `yaml:"debugAddr"`
 	GRPCAddr            string               `yaml:"grpcAddr"`
 	MaxReadSize         int                  `yaml:"maxReadSize"`
+	ConsensusLock       *ConsensusLockConfig `yaml:"consensusLock,omitempty"`
+
+	// ChainConsensusLock overrides ConsensusLock for the listed chain IDs.
+	ChainConsensusLock map[string]ConsensusLockConfig `yaml:"chainConsensusLock,omitempty"`
 }
 
 func (c *Config) Nodes() (out []string) {
@@ -54,7 +59,24 @@ func (c *Config) MustMarshalYaml() []byte {
 }
 
 func (c *Config) ValidateSingleSignerConfig() error {
-	return c.ChainNodes.Validate()
+	if err := c.ChainNodes.Validate(); err != nil {
+		return err
+	}
+	return c.ValidateConsensusLockConfig()
+}
+
+func (c *Config) ValidateConsensusLockConfig() error {
+	if c.ConsensusLock != nil {
+		if _, err := c.ConsensusLock.Options(); err != nil {
+			return fmt.Errorf("invalid consensusLock: %w", err)
+		}
+	}
+	for chainID, cfg := range c.ChainConsensusLock {
+		if _, err := cfg.Options(); err != nil {
+			return fmt.Errorf("invalid chainConsensusLock for %s: %w", chainID, err)
+		}
+	}
+	return nil
 }
 
 func (c *Config) ValidateThresholdModeConfig() error {
@@ -102,6 +124,18 @@ type RuntimeConfig struct {
 	Config     Config
 }
 
+// ConsensusLockOptions returns the consensus lock options configured for chainID.
+func (c RuntimeConfig) ConsensusLockOptions(chainID string) (ConsensusLockOptions, error) {
+	cfg, ok := c.Config.ChainConsensusLock[chainID]
+	if !ok {
+		if c.Config.ConsensusLock == nil {
+			return ConsensusLockOptions{}, nil
+		}
+		cfg = *c.Config.ConsensusLock
+	}
+	return cfg.Options()
+}
+
 func (c RuntimeConfig) CosignerSecurityECIES() (*CosignerSecurityECIES, error) {
 	keyFile, err := c.KeyFileExistsCosignerECIES()
 	if err != nil {
@@ -224,6 +258,98 @@ type ThresholdModeConfig struct {
 	RaftTimeout string          `yaml:"raftTimeout"`
 }
 
+// ConsensusLockConfig is the on disk config format for the consensus lock options of
+// the sign states. See ConsensusLockOptions for what each option does.
+type ConsensusLockConfig struct {
+	DisableLock              bool     `yaml:"disableLock,omitempty"`
+	FailClosedOnMissingState bool     `yaml:"failClosedOnMissingState,omitempty"`
+	MultiHeight              bool     `yaml:"multiHeight,omitempty"`
+	MaxSignBytesLen          int      `yaml:"maxSignBytesLen,omitempty"`
+	MaxTimestampSkew         string   `yaml:"maxTimestampSkew,omitempty"`
+	MonotonicTimestamps      bool     `yaml:"monotonicTimestamps,omitempty"`
+	GlobalMonotonicHRS       bool     `yaml:"globalMonotonicHRS,omitempty"`
+	MaxRound                 int32    `yaml:"maxRound,omitempty"`
+	LongLockWarnRounds       int64    `yaml:"longLockWarnRounds,omitempty"`
+	ReleaseSteps             []string `yaml:"releaseSteps,omitempty"`
+	TrackPartSetHeader       bool     `yaml:"trackPartSetHeader,omitempty"`
+	HaltOnDoubleSign         bool     `yaml:"haltOnDoubleSign,omitempty"`
+	ShadowMode               bool     `yaml:"shadowMode,omitempty"`
+	CanaryMode               bool     `yaml:"canaryMode,omitempty"`
+	ExpectedProposerAddr     string   `yaml:"expectedProposerAddr,omitempty"`
+	StepEncoding             string   `yaml:"stepEncoding,omitempty"`
+	AllowZeroValue           bool     `yaml:"allowZeroValue,omitempty"`
+}
+
+// stepEncodingNames maps the stepEncoding config values to step encodings.
+var stepEncodingNames = map[string]StepEncoding{
+	"":                StepEncodingCometBFT,
+	"cometbft":        StepEncodingCometBFT,
+	"tendermint-0.34": StepEncodingTendermint034,
+}
+
+// Options returns the ConsensusLockOptions configured by cfg.
+func (cfg ConsensusLockConfig) Options() (ConsensusLockOptions, error) {
+	encoding, ok := stepEncodingNames[cfg.StepEncoding]
+	if !ok {
+		return ConsensusLockOptions{}, fmt.Errorf("invalid stepEncoding %q", cfg.StepEncoding)
+	}
+	if cfg.MaxSignBytesLen < 0 {
+		return ConsensusLockOptions{}, fmt.Errorf("maxSignBytesLen cannot be negative")
+	}
+	if cfg.MaxRound < 0 {
+		return ConsensusLockOptions{}, fmt.Errorf("maxRound cannot be negative")
+	}
+	if cfg.LongLockWarnRounds < 0 {
+		return ConsensusLockOptions{}, fmt.Errorf("longLockWarnRounds cannot be negative")
+	}
+
+	opts := ConsensusLockOptions{
+		DisableLock:              cfg.DisableLock,
+		FailClosedOnMissingState: cfg.FailClosedOnMissingState,
+		MultiHeight:              cfg.MultiHeight,
+		MaxSignBytesLen:          cfg.MaxSignBytesLen,
+		MonotonicTimestamps:      cfg.MonotonicTimestamps,
+		GlobalMonotonicHRS:       cfg.GlobalMonotonicHRS,
+		MaxRound:                 cfg.MaxRound,
+		LongLockWarnRounds:       cfg.LongLockWarnRounds,
+		TrackPartSetHeader:       cfg.TrackPartSetHeader,
+		HaltOnDoubleSign:         cfg.HaltOnDoubleSign,
+		ShadowMode:               cfg.ShadowMode,
+		CanaryMode:               cfg.CanaryMode,
+		StepEncoding:             encoding,
+		AllowZeroValue:           cfg.AllowZeroValue,
+	}
+
+	if cfg.MaxTimestampSkew != "" {
+		skew, err := time.ParseDuration(cfg.MaxTimestampSkew)
+		if err != nil {
+			return ConsensusLockOptions{}, fmt.Errorf("invalid maxTimestampSkew: %w", err)
+		}
+		opts.MaxTimestampSkew = skew
+	}
+
+	if cfg.ExpectedProposerAddr != "" {
+		addr, err := hex.DecodeString(cfg.ExpectedProposerAddr)
+		if err != nil {
+			return ConsensusLockOptions{}, fmt.Errorf("invalid expectedProposerAddr: %w", err)
+		}
+		opts.ExpectedProposerAddr = addr
+	}
+
+	if cfg.ReleaseSteps != nil {
+		opts.ReleaseSteps = make(map[int8]bool, len(cfg.ReleaseSteps))
+		for _, name := range cfg.ReleaseSteps {
+			step, err := encoding.ParseStep(name)
+			if err != nil {
+				return ConsensusLockOptions{}, fmt.Errorf("invalid releaseSteps: %w", err)
+			}
+			opts.ReleaseSteps[step] = true
+		}
+	}
+
+	return opts, nil
+}
+
 func (cfg *ThresholdModeConfig) LeaderElectMultiAddress() (string, error) {
 	addresses := make([]string, len(cfg.Cosigners))
 	for i, c := range cfg.Cosigners {
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/strangelove-ventures/horcrux/v3/signer"
 	"github.com/stretchr/testify/require"
@@ -343,6 +344,63 @@ func TestRuntimeConfigPrivValStateFile(t *testing.T) {
 	require.Equal(t, filepath.Join(dir, "chain-1_priv_validator_state.json"), c.PrivValStateFile("chain-1"))
 }
 
+func TestConsensusLockConfigOptions(t *testing.T) {
+	opts, err := signer.ConsensusLockConfig{
+		DisableLock:          true,
+		MaxTimestampSkew:     "5s",
+		MaxRound:             50,
+		ReleaseSteps:         []string{"prevote", "precommit"},
+		ExpectedProposerAddr: "0A0B",
+		StepEncoding:         "tendermint-0.34",
+	}.Options()
+	require.NoError(t, err)
+	require.Equal(t, signer.ConsensusLockOptions{
+		DisableLock:          true,
+		MaxTimestampSkew:     5 * time.Second,
+		MaxRound:             50,
+		ReleaseSteps:         map[int8]bool{2: true, 3: true},
+		ExpectedProposerAddr: []byte{0x0A, 0x0B},
+		StepEncoding:         signer.StepEncodingTendermint034,
+	}, opts)
+
+	for _, tc := range []struct {
+		name   string
+		config signer.ConsensusLockConfig
+	}{
+		{name: "invalid skew", config: signer.ConsensusLockConfig{MaxTimestampSkew: "5"}},
+		{name: "negative round", config: signer.ConsensusLockConfig{MaxRound: -1}},
+		{name: "invalid release step", config: signer.ConsensusLockConfig{ReleaseSteps: []string{"commit"}}},
+		{name: "invalid proposer", config: signer.ConsensusLockConfig{ExpectedProposerAddr: "xyz"}},
+		{name: "invalid encoding", config: signer.ConsensusLockConfig{StepEncoding: "tendermint-0.33"}},
+	} {
+		_, err := tc.config.Options()
+		require.Error(t, err, tc.name)
+
+		config := signer.Config{ChainConsensusLock: map[string]signer.ConsensusLockConfig{testChainID: tc.config}}
+		require.Error(t, config.ValidateSingleSignerConfig(), tc.name)
+	}
+}
+
+func TestRuntimeConfigConsensusLockOptions(t *testing.T) {
+	c := signer.RuntimeConfig{}
+	opts, err := c.ConsensusLockOptions(testChainID)
+	require.NoError(t, err)
+	require.Equal(t, signer.ConsensusLockOptions{}, opts)
+
+	c.Config.ConsensusLock = &signer.ConsensusLockConfig{MaxRound: 50}
+	c.Config.ChainConsensusLock = map[string]signer.ConsensusLockConfig{
+		"sidechain": {DisableLock: true},
+	}
+
+	opts, err = c.ConsensusLockOptions(testChainID)
+	require.NoError(t, err)
+	require.Equal(t, signer.ConsensusLockOptions{MaxRound: 50}, opts)
+
+	opts, err = c.ConsensusLockOptions("sidechain")
+	require.NoError(t, err)
+	require.Equal(t, signer.ConsensusLockOptions{DisableLock: true}, opts)
+}
+
 func TestRuntimeConfigWriteConfigFile(t *testing.T) {
 	dir := t.TempDir()
 	configFile := filepath.Join(dir, "config.yaml")
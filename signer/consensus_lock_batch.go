@@ -0,0 +1,44 @@
+package signer
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// ValidateConcurrent validates independent sign requests, e.g. for different heights, against
+// the consensus lock in parallel, like ValidateSignRequest. It only validates and never advances
+// the lock. The returned errors are in the order of reqs. Requests not yet validated when ctx
+// is done fail with the context's error.
+func (signState *SignState) ValidateConcurrent(ctx context.Context, reqs []SignRequest) []error {
+	errs := make([]error, len(reqs))
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(reqs) {
+		workers = len(reqs)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				errs[i] = signState.ValidateSignRequest(reqs[i])
+			}
+		}()
+	}
+
+	for i := range reqs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,61 @@
+package signer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cometbft/cometbft/libs/protoio"
+	cometproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConcurrent(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: lockedValue},
+	}
+
+	prevote := func(height int64, value []byte) SignRequest {
+		signBytes, err := protoio.MarshalDelimited(&cometproto.CanonicalVote{
+			Type:    cometproto.PrevoteType,
+			Height:  height,
+			Round:   1,
+			BlockID: &cometproto.CanonicalBlockID{Hash: value},
+		})
+		require.NoError(t, err)
+		return SignRequest{HRS: HRSKey{Height: height, Round: 1, Step: stepPrevote}, SignBytes: signBytes, PolRound: -1}
+	}
+
+	// Only the requests at the locked height for a different value are blocked
+	var reqs []SignRequest
+	for i := 0; i < 500; i++ {
+		height := int64(100 + i%5)
+		value := lockedValue
+		if i%2 == 1 {
+			value = differentValue
+		}
+		reqs = append(reqs, prevote(height, value))
+	}
+
+	errs := signState.ValidateConcurrent(context.Background(), reqs)
+	require.Len(t, errs, len(reqs))
+	for i, err := range errs {
+		if reqs[i].HRS.Height == 100 && i%2 == 1 {
+			var violationErr *ConsensusLockViolationError
+			require.ErrorAs(t, err, &violationErr, "request %d", i)
+		} else {
+			require.NoError(t, err, "request %d", i)
+		}
+	}
+	require.Equal(t, ConsensusLock{Height: 100, Round: 0, Value: lockedValue}, signState.ConsensusLock)
+
+	// Nothing is validated once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, err := range signState.ValidateConcurrent(ctx, reqs) {
+		require.ErrorIs(t, err, context.Canceled)
+	}
+
+	require.Empty(t, signState.ValidateConcurrent(context.Background(), nil))
+}
@@ -0,0 +1,25 @@
+package signer
+
+import (
+	"fmt"
+
+	cometproto "github.com/cometbft/cometbft/proto/tendermint/types"
+)
+
+// ValidateProposal validates a CometBFT proposal against the consensus lock. It builds
+// the canonical sign bytes for chainID itself, and passes on the proposal's POL round.
+func (signState *SignState) ValidateProposal(chainID string, p *cometproto.Proposal) error {
+	block := ProposalToBlock(chainID, p)
+	return signState.ValidateConsensusLock(block.HRSKey(), block.SignBytes, int64(p.PolRound))
+}
+
+// ValidateVote validates a CometBFT prevote or precommit against the consensus lock.
+// It builds the canonical sign bytes for chainID itself. Votes do not carry a POL round,
+// so it is treated as unknown, as when signing. Votes for nil are always allowed.
+func (signState *SignState) ValidateVote(chainID string, v *cometproto.Vote) error {
+	if v.Type != cometproto.PrevoteType && v.Type != cometproto.PrecommitType {
+		return fmt.Errorf("unsupported vote type %s", v.Type)
+	}
+	block := VoteToBlock(chainID, v)
+	return signState.ValidateConsensusLock(block.HRSKey(), block.SignBytes, block.PolRound)
+}
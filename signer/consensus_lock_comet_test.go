@@ -0,0 +1,94 @@
+package signer
+
+import (
+	"testing"
+
+	cometproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+)
+
+func testCometBlockID(hash []byte) cometproto.BlockID {
+	return cometproto.BlockID{
+		Hash:          hash,
+		PartSetHeader: cometproto.PartSetHeader{Total: 1, Hash: hash},
+	}
+}
+
+func TestValidateProposal(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: lockedValue},
+	}
+
+	proposal := func(hash []byte) *cometproto.Proposal {
+		return &cometproto.Proposal{
+			Type:     cometproto.ProposalType,
+			Height:   100,
+			Round:    1,
+			PolRound: -1,
+			BlockID:  testCometBlockID(hash),
+		}
+	}
+
+	require.NoError(t, signState.ValidateProposal(testChainID, proposal(lockedValue)))
+
+	err := signState.ValidateProposal(testChainID, proposal(differentValue))
+	var violationErr *ConsensusLockViolationError
+	require.ErrorAs(t, err, &violationErr)
+	require.Equal(t, differentValue, violationErr.RequestedValue)
+}
+
+func TestValidateVote(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 1, Value: lockedValue},
+	}
+
+	vote := func(voteType cometproto.SignedMsgType, round int32, hash []byte) *cometproto.Vote {
+		v := &cometproto.Vote{Type: voteType, Height: 100, Round: round}
+		if hash != nil {
+			v.BlockID = testCometBlockID(hash)
+		}
+		return v
+	}
+
+	testCases := []struct {
+		name      string
+		vote      *cometproto.Vote
+		violation bool
+	}{
+		{"prevote for locked value", vote(cometproto.PrevoteType, 2, lockedValue), false},
+		// Votes carry no POL round, so a prevote may unlock
+		{"prevote for different value", vote(cometproto.PrevoteType, 2, differentValue), false},
+		{"prevote for nil", vote(cometproto.PrevoteType, 2, nil), false},
+		{"precommit for locked value", vote(cometproto.PrecommitType, 1, lockedValue), false},
+		{"precommit for different value in lock round", vote(cometproto.PrecommitType, 1, differentValue), true},
+		{"precommit for different value in later round", vote(cometproto.PrecommitType, 2, differentValue), false},
+		{"precommit for nil in lock round", vote(cometproto.PrecommitType, 1, nil), false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := signState.ValidateVote(testChainID, tc.vote)
+			if tc.violation {
+				var violationErr *ConsensusLockViolationError
+				require.ErrorAs(t, err, &violationErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	// A precommit for nil does not move the lock
+	lock, err := signState.AdvanceConsensusLock(
+		HRSKey{Height: 100, Round: 3, Step: stepPrecommit},
+		VoteToBlock(testChainID, vote(cometproto.PrecommitType, 3, nil)).SignBytes)
+	require.NoError(t, err)
+	require.Equal(t, lockedValue, lock.Value)
+	require.Equal(t, int64(1), lock.Round)
+
+	require.Error(t, signState.ValidateVote(testChainID, &cometproto.Vote{Type: cometproto.ProposalType}))
+}
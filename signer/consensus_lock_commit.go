@@ -0,0 +1,53 @@
+package signer
+
+import "fmt"
+
+// ReconcileWithCommit reconciles the consensus lock with the value the chain committed at
+// height and round, e.g. as reported by the node on boot. If the lock at height is on the
+// committed value, the height is decided and the lock is cleared. So it is if a different
+// value was committed in a round after the lock round: other validators saw a newer POL for
+// it, which is normal. If a different value was committed in the lock round or before, a
+// *CommitConflictError is returned and the lock is kept: the chain decided something we were
+// locked against, which should not happen and needs investigating. Locks at other heights
+// are left alone. A pinned lock is never cleared; a *PinnedLockError is returned.
+func (signState *SignState) ReconcileWithCommit(height int64, round int32, value []byte) error {
+	signState.mu.Lock()
+	defer signState.mu.Unlock()
+
+	lock := signState.lockedLockFor(height)
+	if !lock.IsLocked() || lock.Height != height {
+		return nil
+	}
+	if int64(round) <= lock.Round && !signState.equivalentValues(lock.Value, value) {
+		return newCommitConflictError(height, round, value, lock)
+	}
+	if lock.Pinned {
+		return newPinnedLockError(lock.HRSKey(), HRSKey{Height: height, Round: int64(round)})
+	}
+
+	signState.lockedSetLock(height, ConsensusLock{})
+	signState.lockedLockChanged()
+	return nil
+}
+
+// CommitConflictError is returned when the chain committed a value other than the one we are locked on.
+type CommitConflictError struct {
+	Height    int64
+	Round     int32
+	Committed []byte
+	Lock      ConsensusLock
+}
+
+func (e *CommitConflictError) Error() string {
+	return fmt.Sprintf("chain committed %x at height %d round %d, but consensus lock at round %d is on %x",
+		e.Committed, e.Height, e.Round, e.Lock.Round, e.Lock.Value)
+}
+
+func newCommitConflictError(height int64, round int32, committed []byte, lock ConsensusLock) *CommitConflictError {
+	return &CommitConflictError{
+		Height:    height,
+		Round:     round,
+		Committed: committed,
+		Lock:      lock,
+	}
+}
@@ -0,0 +1,47 @@
+package signer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileWithCommit(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	lock := ConsensusLock{Height: 100, Round: 2, Value: lockedValue}
+
+	// The chain committed our value, so the lock is cleared
+	signState := &SignState{ConsensusLock: lock}
+	require.NoError(t, signState.ReconcileWithCommit(100, 3, lockedValue))
+	require.False(t, signState.ConsensusLock.IsLocked())
+
+	// The chain committed something else in a later round, after a newer POL we did not see
+	signState = &SignState{ConsensusLock: lock}
+	require.NoError(t, signState.ReconcileWithCommit(100, 3, differentValue))
+	require.False(t, signState.ConsensusLock.IsLocked())
+
+	// The chain committed something else in the lock round
+	signState = &SignState{ConsensusLock: lock}
+	err := signState.ReconcileWithCommit(100, 2, differentValue)
+	var conflictErr *CommitConflictError
+	require.ErrorAs(t, err, &conflictErr)
+	require.Equal(t, differentValue, conflictErr.Committed)
+	require.Equal(t, lock, conflictErr.Lock)
+	require.Equal(t, lock, signState.ConsensusLock)
+
+	// A commit at another height says nothing about the lock
+	require.NoError(t, signState.ReconcileWithCommit(99, 0, differentValue))
+	require.Equal(t, lock, signState.ConsensusLock)
+
+	// Nor is there anything to reconcile without a lock
+	require.NoError(t, (&SignState{}).ReconcileWithCommit(100, 0, differentValue))
+
+	// A pinned lock is kept
+	pinned := lock
+	pinned.Pinned = true
+	signState = &SignState{ConsensusLock: pinned}
+	var pinnedErr *PinnedLockError
+	require.ErrorAs(t, signState.ReconcileWithCommit(100, 3, lockedValue), &pinnedErr)
+	require.Equal(t, pinned, signState.ConsensusLock)
+}
@@ -0,0 +1,61 @@
+package signer
+
+// Decision is the structured outcome of evaluating a sign request against the consensus lock.
+type Decision struct {
+	// Allowed is true if the request may be signed.
+	Allowed bool
+	// Reason describes why the request was allowed or blocked.
+	Reason string
+	// AdvancesLock is true if signing the request would set or move the consensus lock.
+	AdvancesLock bool
+}
+
+// Evaluate evaluates signing signBytes at hrs against the consensus lock.
+// No POL round is assumed, so a prevote for a value other than the locked one is blocked.
+// The returned error is the reason a blocked request was refused. Evaluate is a dry run: the
+// decision is not reported, counted or recorded as an approval, and it is that of the lock
+// rules even in ShadowMode or CanaryMode.
+func (signState *SignState) Evaluate(hrs HRSKey, signBytes []byte) (Decision, error) {
+	return signState.EvaluateSignRequest(SignRequest{
+		HRS:       hrs,
+		SignBytes: signBytes,
+		PolRound:  -1,
+	})
+}
+
+// EvaluateSignRequest is like Evaluate, but for a full sign request.
+// Called on a nil SignState, it refuses with a *NilSignStateError.
+func (signState *SignState) EvaluateSignRequest(req SignRequest) (Decision, error) {
+	if signState == nil {
+		err := newNilSignStateError(req.HRS)
+		return Decision{Reason: err.Error()}, err
+	}
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+	return signState.lockedEvaluate(req)
+}
+
+// lockedEvaluate evaluates req against the consensus lock without side effects.
+// Not thread-safe (requires external lock).
+func (signState *SignState) lockedEvaluate(req SignRequest) (Decision, error) {
+	if err := signState.lockedCheckConsensusLock(req); err != nil {
+		return Decision{Reason: err.Error()}, err
+	}
+
+	lock := signState.lockedLockFor(req.HRS.Height)
+	next := signState.ConsensusLockOptions.nextConsensusLock(lock, req.HRS, req.SignBytes, req.VoteExtension)
+
+	decision := Decision{
+		Allowed:      true,
+		AdvancesLock: lockMoved(lock, next),
+	}
+	switch {
+	case !lock.IsLocked():
+		decision.Reason = "no consensus lock"
+	case req.HRS.Height != lock.Height:
+		decision.Reason = "consensus lock is for a different height"
+	default:
+		decision.Reason = "consistent with consensus lock"
+	}
+	return decision, nil
+}
@@ -28,7 +28,7 @@ func createTestSignBytesE2E(blockHash []byte, step int8) []byte {
 	case stepPrevote, stepPrecommit:
 		// Create a CanonicalVote
 		vote := &cometproto.CanonicalVote{
-			Type:   cometproto.SignedMsgType(step),
+			Type:   StepToType(step),
 			Height: 100,
 			Round:  5,
 			BlockID: &cometproto.CanonicalBlockID{
@@ -0,0 +1,195 @@
+package signer
+
+import (
+	"errors"
+	"time"
+)
+
+// LockErrorKind is a kind of error that consensus lock validation can return, with
+// the helper that recognizes it, so that clients can switch on the kind of an error.
+type LockErrorKind struct {
+	// Name identifies the kind, e.g. in logs and documentation.
+	Name string
+	// Is returns true if err, or any error it wraps, is of this kind.
+	Is func(err error) bool
+
+	example error
+}
+
+// lockErrorKinds lists every kind of error consensus lock validation can return.
+var lockErrorKinds = []LockErrorKind{
+	{
+		Name:    "consensus_lock_violation",
+		Is:      IsConsensusLockViolationError,
+		example: newConsensusLockViolationError(make([]byte, 32), make([]byte, 32), 1, 0),
+	},
+	{
+		Name:    "consensus_lock_step_violation",
+		Is:      IsConsensusLockStepViolationError,
+		example: newConsensusLockStepViolationError(HRSKey{Height: 1, Step: stepPropose}),
+	},
+	{
+		Name:    "cross_step_conflict",
+		Is:      IsCrossStepConflictError,
+		example: newCrossStepConflictError(HRSKey{Height: 1, Step: stepPrevote}, make([]byte, 32), make([]byte, 32)),
+	},
+	{
+		Name:    "double_sign",
+		Is:      IsDoubleSignError,
+		example: newDiffBlockIDsError(make([]byte, 32), make([]byte, 32)),
+	},
+	{
+		Name:    "hrs_regression",
+		Is:      IsHRSRegressionError,
+		example: newRoundRegressionError(1, 0, 1),
+	},
+	{
+		Name:    "round_ceiling",
+		Is:      IsRoundCeilingError,
+		example: newRoundCeilingError(HRSKey{Height: 1, Round: 2, Step: stepPrevote}, 1),
+	},
+	{
+		Name:    "timestamp_skew",
+		Is:      IsTimestampSkewError,
+		example: newTimestampSkewError(time.Unix(0, 0), time.Unix(60, 0), time.Second),
+	},
+	{
+		Name:    "timestamp_regression",
+		Is:      IsTimestampRegressionError,
+		example: newTimestampRegressionError(HRSKey{Height: 1, Step: stepPrevote}, time.Unix(0, 0), time.Unix(60, 0)),
+	},
+	{
+		Name:    "uninitialized_sign_state",
+		Is:      IsUninitializedSignStateError,
+		example: newUninitializedSignStateError(HRSKey{Height: 1, Step: stepPrevote}),
+	},
+	{
+		Name:    "signer_halted",
+		Is:      IsSignerHaltedError,
+		example: newSignerHaltedError(HRSKey{Height: 1, Step: stepPrevote}),
+	},
+	{
+		Name:    "parse",
+		Is:      IsParseError,
+		example: newBlockHashExtractionError(stepPrevote, newDecodeError(stepPrevote, errors.New("empty sign bytes"))),
+	},
+	{
+		Name:    "quorum_not_met",
+		Is:      IsQuorumNotMetError,
+		example: newQuorumNotMetError(HRSKey{Height: 1, Step: stepPrevote}, 1, 3, 2, nil),
+	},
+	{
+		Name:    "proposer_mismatch",
+		Is:      IsProposerMismatchError,
+		example: newProposerMismatchError(HRSKey{Height: 1, Step: stepPropose}, []byte{1}, []byte{2}),
+	},
+	{
+		Name:    "chain_id_not_allowed",
+		Is:      IsChainIDNotAllowedError,
+		example: newChainIDNotAllowedError("other-chain"),
+	},
+	{
+		Name:    "validate_timeout",
+		Is:      IsValidateTimeoutError,
+		example: newValidateTimeoutError(HRSKey{Height: 1, Step: stepPrevote}, time.Second),
+	},
+}
+
+// LockErrorKinds returns every kind of error consensus lock validation can return.
+func LockErrorKinds() []LockErrorKind {
+	return append([]LockErrorKind(nil), lockErrorKinds...)
+}
+
+// KnownLockErrors returns an example of every kind of error consensus lock validation
+// can return, in the order of LockErrorKinds, e.g. for documentation.
+func KnownLockErrors() []error {
+	known := make([]error, len(lockErrorKinds))
+	for i, kind := range lockErrorKinds {
+		known[i] = kind.example
+	}
+	return known
+}
+
+// IsCrossStepConflictError checks if the error is a prevote conflicting with the proposal of its round.
+func IsCrossStepConflictError(err error) bool {
+	var crossStepErr *CrossStepConflictError
+	return errors.As(err, &crossStepErr)
+}
+
+// IsHRSRegressionError checks if the error is a height, round or step regression, or
+// an HRS not above the highest ever signed with GlobalMonotonicHRS.
+func IsHRSRegressionError(err error) bool {
+	var (
+		heightErr        *HeightRegressionError
+		roundErr         *RoundRegressionError
+		stepErr          *StepRegressionError
+		notIncreasingErr *HRSNotIncreasingError
+	)
+	return errors.As(err, &heightErr) || errors.As(err, &roundErr) || errors.As(err, &stepErr) ||
+		errors.As(err, &notIncreasingErr)
+}
+
+// IsRoundCeilingError checks if the error is a round above MaxRound.
+func IsRoundCeilingError(err error) bool {
+	var ceilingErr *RoundCeilingError
+	return errors.As(err, &ceilingErr)
+}
+
+// IsTimestampSkewError checks if the error is a timestamp too far from the local clock.
+func IsTimestampSkewError(err error) bool {
+	var skewErr *TimestampSkewError
+	return errors.As(err, &skewErr)
+}
+
+// IsTimestampRegressionError checks if the error is a timestamp earlier than one already signed at the height.
+func IsTimestampRegressionError(err error) bool {
+	var regressionErr *TimestampRegressionError
+	return errors.As(err, &regressionErr)
+}
+
+// IsUninitializedSignStateError checks if the error is a vote refused for lack of sign state.
+func IsUninitializedSignStateError(err error) bool {
+	var uninitializedErr *UninitializedSignStateError
+	return errors.As(err, &uninitializedErr)
+}
+
+// IsSignerHaltedError checks if the error is a request refused because the signer is halted.
+func IsSignerHaltedError(err error) bool {
+	var haltedErr *SignerHaltedError
+	return errors.As(err, &haltedErr)
+}
+
+// IsParseError checks if the error is sign bytes that are too large, or that cannot be
+// decoded or yield no usable value.
+func IsParseError(err error) bool {
+	var (
+		extractionErr *BlockHashExtractionError
+		unmarshalErr  *UnmarshalError
+		suspiciousErr *SuspiciousDecodeError
+		decodeErr     *DecodeError
+		oversizedErr  *OversizedSignBytesError
+		valueTypeErr  *UnknownValueTypeError
+		lengthErr     *HashLengthError
+	)
+	return errors.As(err, &extractionErr) || errors.As(err, &unmarshalErr) || errors.As(err, &suspiciousErr) ||
+		errors.As(err, &decodeErr) || errors.As(err, &oversizedErr) || errors.As(err, &valueTypeErr) ||
+		errors.As(err, &lengthErr)
+}
+
+// IsProposerMismatchError checks if the error is a proposal for another validator than ExpectedProposerAddr.
+func IsProposerMismatchError(err error) bool {
+	var mismatchErr *ProposerMismatchError
+	return errors.As(err, &mismatchErr)
+}
+
+// IsChainIDNotAllowedError checks if the error is sign bytes of a chain not allowed by ChainIDPolicy.
+func IsChainIDNotAllowedError(err error) bool {
+	var chainIDErr *ChainIDNotAllowedError
+	return errors.As(err, &chainIDErr)
+}
+
+// IsValidateTimeoutError checks if the error is a validation that took longer than ValidateTimeout.
+func IsValidateTimeoutError(err error) bool {
+	var timeoutErr *ValidateTimeoutError
+	return errors.As(err, &timeoutErr)
+}
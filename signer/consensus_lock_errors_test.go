@@ -0,0 +1,33 @@
+package signer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKnownLockErrors(t *testing.T) {
+	kinds := LockErrorKinds()
+	known := KnownLockErrors()
+	require.Len(t, known, len(kinds))
+
+	names := make(map[string]bool)
+	for i, kind := range kinds {
+		kind, err := kind, known[i]
+		t.Run(kind.Name, func(t *testing.T) {
+			require.False(t, names[kind.Name], "duplicate kind")
+			names[kind.Name] = true
+
+			require.NotEmpty(t, err.Error())
+			require.True(t, kind.Is(err))
+			require.False(t, kind.Is(nil))
+
+			// Each error is of exactly one kind
+			for j, other := range kinds {
+				if j != i {
+					require.False(t, other.Is(err), "%s is also %s", kind.Name, other.Name)
+				}
+			}
+		})
+	}
+}
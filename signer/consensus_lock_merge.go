@@ -0,0 +1,168 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MergeConsensusLock returns the safer of two consensus locks: the one taken at
+// the later height, or at the later round of the same height. An unlocked lock
+// never wins over a locked one. Locks at the same height and round must agree on
+// the value, otherwise a *ConsensusLockConflictError is returned.
+func MergeConsensusLock(a, b ConsensusLock) (ConsensusLock, error) {
+	switch {
+	case !b.IsLocked():
+		return a, nil
+	case !a.IsLocked():
+		return b, nil
+	case a.Height != b.Height:
+		if a.Height > b.Height {
+			return a, nil
+		}
+		return b, nil
+	case a.Round != b.Round:
+		if a.Round > b.Round {
+			return a, nil
+		}
+		return b, nil
+	case !lockValuesEqual(a.Value, b.Value):
+		return ConsensusLock{}, newConsensusLockConflictError(a, b)
+	}
+
+	// Same lock. Keep the most recent metadata, and keep it pinned if either copy is.
+	merged := a
+	if b.UpdatedAt.After(a.UpdatedAt) {
+		merged = b
+	}
+	merged.Pinned = a.Pinned || b.Pinned
+	return merged, nil
+}
+
+// SelectSafestLock returns the most advanced of candidate locks, e.g. one from each
+// cosigner when recovering, as picked by MergeConsensusLock. Every pair of locks is
+// checked, so that a conflict is reported even between locks that are both superseded
+// by a later one. No locks, or only unlocked ones, select an unlocked lock.
+func SelectSafestLock(locks []ConsensusLock) (ConsensusLock, error) {
+	for i := range locks {
+		for j := i + 1; j < len(locks); j++ {
+			if _, err := MergeConsensusLock(locks[i], locks[j]); err != nil {
+				return ConsensusLock{}, err
+			}
+		}
+	}
+
+	var safest ConsensusLock
+	for _, lock := range locks {
+		// Pairwise consistency was checked above
+		safest, _ = MergeConsensusLock(safest, lock)
+	}
+	return safest, nil
+}
+
+// RestoreFromBackups replaces the consensus lock with the safest of the current
+// lock and the primary and secondary backups, as picked by MergeConsensusLock.
+// On a conflict between any of them the SignState is left unchanged.
+func (signState *SignState) RestoreFromBackups(primary, secondary ConsensusLock) error {
+	merged, err := MergeConsensusLock(primary, secondary)
+	if err != nil {
+		return err
+	}
+
+	signState.mu.Lock()
+	defer signState.mu.Unlock()
+
+	merged, err = MergeConsensusLock(signState.ConsensusLock, merged)
+	if err != nil {
+		return err
+	}
+	if lockMoved(signState.ConsensusLock, merged) {
+		signState.lockedSetLock(merged.Height, merged)
+		signState.lockedLockChanged()
+	}
+	return nil
+}
+
+// ChooseSignState loads the candidate sign state files at paths, e.g. a primary and a
+// backup found on boot, checks each for consistency (see CheckConsistency) and returns
+// the one that signed the highest HRS. Among files that signed the same HRS, the one with
+// the most advanced consensus lock, as picked by MergeConsensusLock, wins. Locks below the
+// chosen height are stale, since the lock is cleared at every new height. It returns a
+// *ConsensusLockConflictError if two files hold different values at the same height
+// and round, and an error if another file holds a more advanced lock at the chosen
+// height or above, since signing from the chosen one could then break that lock.
+func ChooseSignState(paths ...string) (*SignState, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no sign state files to choose from")
+	}
+
+	states := make([]*SignState, len(paths))
+	for i, path := range paths {
+		state, err := LoadSignState(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load sign state %s: %w", path, err)
+		}
+		if err := state.CheckConsistency(); err != nil {
+			return nil, fmt.Errorf("sign state %s: %w", path, err)
+		}
+		states[i] = state
+	}
+
+	// The states are not shared yet, so they are read without locking
+	signed := func(state *SignState) HRSKey {
+		return HRSKey{Height: state.Height, Round: state.Round, Step: state.Step}
+	}
+	for i := range states {
+		for j := i + 1; j < len(states); j++ {
+			if _, err := MergeConsensusLock(states[i].ConsensusLock, states[j].ConsensusLock); err != nil {
+				return nil, fmt.Errorf("sign states %s and %s: %w", paths[i], paths[j], err)
+			}
+		}
+	}
+
+	best := 0
+	for i := 1; i < len(states); i++ {
+		switch {
+		case signed(states[i]).GreaterThan(signed(states[best])):
+			best = i
+		case signed(states[best]).GreaterThan(signed(states[i])):
+		default:
+			// Pairwise consistency was checked above
+			merged, _ := MergeConsensusLock(states[best].ConsensusLock, states[i].ConsensusLock)
+			if lockMoved(states[best].ConsensusLock, merged) {
+				best = i
+			}
+		}
+	}
+
+	chosen := states[best]
+	for i, state := range states {
+		lock := state.ConsensusLock
+		if !lock.IsLocked() || lock.Height < chosen.Height {
+			continue
+		}
+		// Pairwise consistency was checked above
+		if merged, _ := MergeConsensusLock(chosen.ConsensusLock, lock); lockMoved(chosen.ConsensusLock, merged) {
+			return nil, fmt.Errorf("sign state %s signed the highest HRS, but %s holds a more advanced "+
+				"consensus lock at %d/%d", paths[best], paths[i], lock.Height, lock.Round)
+		}
+	}
+	return chosen, nil
+}
+
+// ConsensusLockConflictError is returned when two locks at the same height and round have different values.
+type ConsensusLockConflictError struct {
+	A ConsensusLock
+	B ConsensusLock
+}
+
+func (e *ConsensusLockConflictError) Error() string {
+	return fmt.Sprintf("conflicting consensus locks at height %d round %d: %x != %x",
+		e.A.Height, e.A.Round, e.A.Value, e.B.Value)
+}
+
+func newConsensusLockConflictError(a, b ConsensusLock) *ConsensusLockConflictError {
+	return &ConsensusLockConflictError{
+		A: a,
+		B: b,
+	}
+}
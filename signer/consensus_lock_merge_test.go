@@ -0,0 +1,172 @@
+package signer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreFromBackups(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+
+	older := ConsensusLock{Height: 100, Round: 1, Value: blockA}
+	newer := ConsensusLock{Height: 100, Round: 2, Value: blockB}
+	nextHeight := ConsensusLock{Height: 101, Round: 0, Value: blockA}
+
+	for _, tc := range []struct {
+		name      string
+		primary   ConsensusLock
+		secondary ConsensusLock
+		expected  ConsensusLock
+	}{
+		{"primary ahead by round", newer, older, newer},
+		{"secondary ahead by round", older, newer, newer},
+		{"secondary ahead by height", newer, nextHeight, nextHeight},
+		{"secondary missing", older, ConsensusLock{}, older},
+		{"primary missing", ConsensusLock{}, older, older},
+		{"identical", older, older, older},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			signState := &SignState{}
+			require.NoError(t, signState.RestoreFromBackups(tc.primary, tc.secondary))
+			require.Equal(t, tc.expected, signState.ConsensusLock)
+		})
+	}
+
+	t.Run("conflict", func(t *testing.T) {
+		conflicting := ConsensusLock{Height: 100, Round: 1, Value: blockB}
+		signState := &SignState{}
+		err := signState.RestoreFromBackups(older, conflicting)
+		var conflictErr *ConsensusLockConflictError
+		require.ErrorAs(t, err, &conflictErr)
+		require.False(t, signState.ConsensusLock.IsLocked())
+	})
+
+	t.Run("current lock is kept if safer", func(t *testing.T) {
+		signState := &SignState{ConsensusLock: nextHeight}
+		require.NoError(t, signState.RestoreFromBackups(older, newer))
+		require.Equal(t, nextHeight, signState.ConsensusLock)
+	})
+
+	t.Run("pinned copies stay pinned", func(t *testing.T) {
+		pinned := older
+		pinned.Pinned = true
+		merged, err := MergeConsensusLock(older, pinned)
+		require.NoError(t, err)
+		require.True(t, merged.Pinned)
+	})
+}
+
+func TestSelectSafestLock(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+
+	older := ConsensusLock{Height: 100, Round: 1, Value: blockA}
+	newer := ConsensusLock{Height: 100, Round: 2, Value: blockB}
+	nextHeight := ConsensusLock{Height: 101, Round: 0, Value: blockA}
+
+	// Consistent locks, in any order
+	for _, locks := range [][]ConsensusLock{
+		{older, newer, nextHeight},
+		{nextHeight, older, newer},
+		{newer, nextHeight, older},
+	} {
+		safest, err := SelectSafestLock(locks)
+		require.NoError(t, err)
+		require.Equal(t, nextHeight, safest)
+	}
+
+	// One conflicts with another, even though both are superseded
+	conflicting := ConsensusLock{Height: 100, Round: 1, Value: blockB}
+	_, err := SelectSafestLock([]ConsensusLock{older, nextHeight, conflicting})
+	var conflictErr *ConsensusLockConflictError
+	require.ErrorAs(t, err, &conflictErr)
+
+	// Nothing to select from
+	safest, err := SelectSafestLock(nil)
+	require.NoError(t, err)
+	require.False(t, safest.IsLocked())
+	safest, err = SelectSafestLock([]ConsensusLock{{}, older, {}})
+	require.NoError(t, err)
+	require.Equal(t, older, safest)
+}
+
+func TestChooseSignState(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+
+	writeHRS := func(t *testing.T, hrs HRSKey, lock ConsensusLock) string {
+		path := filepath.Join(t.TempDir(), "sign_state.json")
+		saveSignState(&SignState{
+			Height:        hrs.Height,
+			Round:         hrs.Round,
+			Step:          hrs.Step,
+			ConsensusLock: lock,
+			filePath:      path,
+		})
+		return path
+	}
+	writeState := func(t *testing.T, round int64, lock ConsensusLock) string {
+		return writeHRS(t, HRSKey{Height: 100, Round: round, Step: stepPrecommit}, lock)
+	}
+
+	t.Run("one ahead", func(t *testing.T) {
+		behind := writeState(t, 1, ConsensusLock{Height: 100, Round: 1, Value: blockA})
+		ahead := writeState(t, 2, ConsensusLock{Height: 100, Round: 2, Value: blockB})
+
+		for _, paths := range [][]string{{behind, ahead}, {ahead, behind}} {
+			chosen, err := ChooseSignState(paths...)
+			require.NoError(t, err)
+			require.Equal(t, int64(2), chosen.Round)
+			require.Equal(t, blockB, chosen.ConsensusLock.Value)
+		}
+	})
+
+	t.Run("unlocked at a new height", func(t *testing.T) {
+		locked := writeState(t, 2, ConsensusLock{Height: 100, Round: 2, Value: blockA})
+		ahead := writeHRS(t, HRSKey{Height: 101, Round: 0, Step: stepPrevote}, ConsensusLock{})
+
+		for _, paths := range [][]string{{locked, ahead}, {ahead, locked}} {
+			chosen, err := ChooseSignState(paths...)
+			require.NoError(t, err)
+			require.Equal(t, int64(101), chosen.Height)
+			require.False(t, chosen.ConsensusLock.IsLocked())
+		}
+	})
+
+	t.Run("same HRS, one locked", func(t *testing.T) {
+		unlocked := writeState(t, 2, ConsensusLock{})
+		locked := writeState(t, 2, ConsensusLock{Height: 100, Round: 2, Value: blockA})
+
+		for _, paths := range [][]string{{unlocked, locked}, {locked, unlocked}} {
+			chosen, err := ChooseSignState(paths...)
+			require.NoError(t, err)
+			require.Equal(t, blockA, chosen.ConsensusLock.Value)
+		}
+	})
+
+	t.Run("more advanced lock behind", func(t *testing.T) {
+		ahead := writeState(t, 3, ConsensusLock{Height: 100, Round: 1, Value: blockA})
+		locked := writeState(t, 2, ConsensusLock{Height: 100, Round: 2, Value: blockB})
+
+		_, err := ChooseSignState(ahead, locked)
+		require.ErrorContains(t, err, "more advanced consensus lock")
+	})
+
+	t.Run("conflict at same HRS", func(t *testing.T) {
+		a := writeState(t, 2, ConsensusLock{Height: 100, Round: 2, Value: blockA})
+		b := writeState(t, 2, ConsensusLock{Height: 100, Round: 2, Value: blockB})
+
+		_, err := ChooseSignState(a, b)
+		var conflictErr *ConsensusLockConflictError
+		require.ErrorAs(t, err, &conflictErr)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := ChooseSignState(filepath.Join(t.TempDir(), "sign_state.json"))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,139 @@
+package signer
+
+import "sort"
+
+// maxTrackedLockHeights bounds the number of per-height locks kept in MultiHeight mode.
+const maxTrackedLockHeights = blocksToCache + 1
+
+// lockedLockFor returns the consensus lock that applies at height.
+// Not thread-safe (requires external lock).
+func (signState *SignState) lockedLockFor(height int64) ConsensusLock {
+	if !signState.MultiHeight {
+		return signState.ConsensusLock
+	}
+	if lock, ok := signState.heightLocks[height]; ok {
+		return lock
+	}
+	if signState.ConsensusLock.Height == height {
+		return signState.ConsensusLock
+	}
+	return ConsensusLock{}
+}
+
+// lockedSetLock stores lock as the consensus lock for height. In MultiHeight
+// mode locks are kept per height, and ConsensusLock mirrors the lock of the
+// highest tracked height. Not thread-safe (requires external lock).
+func (signState *SignState) lockedSetLock(height int64, lock ConsensusLock) {
+	if !signState.MultiHeight {
+		signState.ConsensusLock = lock
+		return
+	}
+
+	if signState.heightLocks == nil {
+		signState.heightLocks = make(map[int64]ConsensusLock)
+	}
+	signState.heightLocks[height] = lock
+
+	var highest int64
+	for h := range signState.heightLocks {
+		if h > highest {
+			highest = h
+		}
+	}
+	for h := range signState.heightLocks {
+		if h <= highest-maxTrackedLockHeights {
+			delete(signState.heightLocks, h)
+		}
+	}
+	signState.ConsensusLock = signState.heightLocks[highest]
+}
+
+// lockedTrackSigned records hrs as the HRS last signed at its height in MultiHeight mode,
+// forgetting heights that are no longer tracked. Not thread-safe (requires external lock).
+func (signState *SignState) lockedTrackSigned(hrs HRSKey) {
+	if signState.heightSigned == nil {
+		signState.heightSigned = make(map[int64]HRSKey)
+	}
+	if hrs.GreaterThan(signState.heightSigned[hrs.Height]) {
+		signState.heightSigned[hrs.Height] = hrs
+	}
+
+	highest := max(hrs.Height, signState.Height)
+	for h := range signState.heightSigned {
+		if h <= highest-maxTrackedLockHeights {
+			delete(signState.heightSigned, h)
+		}
+	}
+}
+
+// lockedCheckSignedHRS returns an error unless hrs is above the HRS last signed. In MultiHeight
+// mode a pipelined height below the highest signed one is instead checked against the HRS last
+// signed at that height. A height without one, e.g. one not signed since MultiHeight was turned
+// on, is refused as a regression. Not thread-safe (requires external lock).
+func (signState *SignState) lockedCheckSignedHRS(hrs HRSKey) error {
+	if signState.MultiHeight && hrs.Height < signState.Height {
+		if signed, ok := signState.heightSigned[hrs.Height]; ok {
+			return errorIfNotAbove(hrs, signed)
+		}
+	}
+	return errorIfNotAbove(hrs, signState.lockedHrsKey())
+}
+
+// lockedCopyHeightLocks returns a copy of the per-height locks. Not thread-safe (requires external lock).
+func (signState *SignState) lockedCopyHeightLocks() map[int64]ConsensusLock {
+	if signState.heightLocks == nil {
+		return nil
+	}
+	locks := make(map[int64]ConsensusLock, len(signState.heightLocks))
+	for h, lock := range signState.heightLocks {
+		locks[h] = copyConsensusLock(lock)
+	}
+	return locks
+}
+
+// lockedCopyHeightSigned returns a copy of the per-height signed HRS. Not thread-safe (requires external lock).
+func (signState *SignState) lockedCopyHeightSigned() map[int64]HRSKey {
+	if signState.heightSigned == nil {
+		return nil
+	}
+	signed := make(map[int64]HRSKey, len(signState.heightSigned))
+	for h, hrs := range signState.heightSigned {
+		signed[h] = hrs
+	}
+	return signed
+}
+
+// lockedPersistedHeights returns the per-height state as persisted in HeightLocks and
+// HeightSigned, oldest first. Unlocked heights are left out, since they apply no lock
+// either way. Not thread-safe (requires external lock).
+func (signState *SignState) lockedPersistedHeights() (locks []ConsensusLock, signed []HRSKey) {
+	for _, lock := range signState.heightLocks {
+		if lock.IsLocked() {
+			locks = append(locks, lock)
+		}
+	}
+	for _, hrs := range signState.heightSigned {
+		signed = append(signed, hrs)
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Height < locks[j].Height })
+	sort.Slice(signed, func(i, j int) bool { return signed[i].Height < signed[j].Height })
+	return locks, signed
+}
+
+// lockedRestoreHeights replaces the per-height state with locks and signed, as returned by
+// lockedPersistedHeights. Not thread-safe (requires external lock).
+func (signState *SignState) lockedRestoreHeights(locks []ConsensusLock, signed []HRSKey) {
+	signState.heightLocks, signState.heightSigned = nil, nil
+	for _, lock := range locks {
+		if signState.heightLocks == nil {
+			signState.heightLocks = make(map[int64]ConsensusLock, len(locks))
+		}
+		signState.heightLocks[lock.Height] = lock
+	}
+	for _, hrs := range signed {
+		if signState.heightSigned == nil {
+			signState.heightSigned = make(map[int64]HRSKey, len(signed))
+		}
+		signState.heightSigned[hrs.Height] = hrs
+	}
+}
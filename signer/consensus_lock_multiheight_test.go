@@ -0,0 +1,96 @@
+package signer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsensusLockMultiHeight(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+
+	lockAt := func(signState *SignState, height int64, value []byte) {
+		_, err := signState.AdvanceConsensusLock(
+			HRSKey{Height: height, Round: 0, Step: stepPrecommit}, createTestSignBytes(value, stepPrecommit))
+		require.NoError(t, err)
+	}
+	prevote := func(signState *SignState, height int64, value []byte) error {
+		return signState.ValidateConsensusLock(
+			HRSKey{Height: height, Round: 1, Step: stepPrevote}, createTestSignBytes(value, stepPrevote), -1)
+	}
+
+	signState := &SignState{ConsensusLockOptions: ConsensusLockOptions{MultiHeight: true}}
+	lockAt(signState, 100, blockA)
+	lockAt(signState, 101, blockB)
+
+	// Each height keeps its own lock
+	require.NoError(t, prevote(signState, 100, blockA))
+	require.True(t, IsConsensusLockViolationError(prevote(signState, 100, blockB)))
+	require.NoError(t, prevote(signState, 101, blockB))
+	require.True(t, IsConsensusLockViolationError(prevote(signState, 101, blockA)))
+
+	// ConsensusLock mirrors the highest height
+	require.Equal(t, int64(101), signState.ConsensusLock.Height)
+	require.Equal(t, blockB, signState.ConsensusLock.Value)
+
+	// Clones keep the per-height locks
+	clone := signState.Clone()
+	require.True(t, IsConsensusLockViolationError(prevote(clone, 100, blockB)))
+
+	// Old heights are forgotten
+	for h := int64(102); h <= 100+maxTrackedLockHeights; h++ {
+		lockAt(signState, h, blockA)
+	}
+	require.NoError(t, prevote(signState, 100, blockB))
+	require.Len(t, signState.heightLocks, maxTrackedLockHeights)
+
+	// Without MultiHeight, the later lock replaces the earlier one
+	signState = &SignState{}
+	lockAt(signState, 100, blockA)
+	lockAt(signState, 101, blockB)
+	require.NoError(t, prevote(signState, 100, blockB))
+}
+
+func TestConsensusLockMultiHeightPersisted(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+	filePath := filepath.Join(t.TempDir(), "sign_state.json")
+
+	signState, err := LoadOrCreateSignState(filePath)
+	require.NoError(t, err)
+	signState.MultiHeight = true
+	save := func(height, round int64, step int8, value []byte) error {
+		return signState.Save(SignStateConsensus{
+			Height:    height,
+			Round:     round,
+			Step:      step,
+			Signature: []byte("signature"),
+			SignBytes: createTestSignBytes(value, step),
+		}, nil)
+	}
+	prevote := func(height int64, value []byte) error {
+		return signState.ValidateConsensusLock(
+			HRSKey{Height: height, Round: 2, Step: stepPrevote}, createTestSignBytes(value, stepPrevote), -1)
+	}
+
+	require.NoError(t, save(100, 0, stepPrecommit, blockA))
+	require.NoError(t, save(101, 0, stepPrecommit, blockB))
+
+	// A pipelined height is checked against its own signed HRS, not the highest one
+	require.NoError(t, save(100, 1, stepPrevote, blockA))
+	require.Equal(t, int64(101), signState.Height)
+	require.Error(t, save(100, 0, stepPrevote, blockA))
+
+	signState, err = LoadSignState(filePath)
+	require.NoError(t, err)
+	signState.MultiHeight = true
+
+	// The per-height locks and signed HRS survive a reload
+	require.True(t, IsConsensusLockViolationError(prevote(100, blockB)))
+	require.True(t, IsConsensusLockViolationError(prevote(101, blockA)))
+	var sameHRSErr *SameHRSError
+	require.ErrorAs(t, save(100, 1, stepPrevote, blockA), &sameHRSErr)
+	require.NoError(t, save(100, 2, stepPrevote, blockA))
+}
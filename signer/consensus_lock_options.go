@@ -0,0 +1,325 @@
+package signer
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/cometbft/cometbft/crypto/tmhash"
+	cometlog "github.com/cometbft/cometbft/libs/log"
+)
+
+// DefaultMaxSignBytesLen is the default limit on the size of sign bytes accepted
+// for validation. Real proposals and votes are a few hundred bytes at most.
+const DefaultMaxSignBytesLen = 1 << 20
+
+// LockLogLevel selects which consensus lock decisions are logged.
+type LockLogLevel int
+
+const (
+	// LockLogDenies logs denied requests only, so that allowed requests skip logging altogether.
+	LockLogDenies LockLogLevel = iota
+	// LockLogAllowsAndDenies also logs allowed requests, with the allowed value, e.g. for audits.
+	LockLogAllowsAndDenies
+)
+
+// ConsensusLockOptions configures optional consensus lock checks on a SignState.
+// With the zero value, only the standard Tendermint locking rules are enforced.
+// Operators set them per chain in the config (see ConsensusLockConfig); hooks such as
+// OnViolation, ValueExtractor and QuorumLockChecker are only available to Go callers.
+type ConsensusLockOptions struct {
+	// DisableLock turns off consensus lock enforcement, e.g. for a chain that is not
+	// consensus critical: every lock check is skipped while HRS regressions are still
+	// rejected. The lock is still tracked so that it is current if enforcement is turned
+	// back on.
+	DisableLock bool
+
+	// BlockEarlierRoundPropose rejects proposals at a round strictly earlier
+	// than the lock round while locked, regardless of the proposed value.
+	BlockEarlierRoundPropose bool
+
+	// Now overrides the clock used to timestamp lock updates. Defaults to time.Now.
+	Now func() time.Time
+
+	// Logger, if set, receives a line for consensus lock decisions, as selected by LogLevel.
+	Logger cometlog.Logger
+
+	// LogLevel selects which consensus lock decisions are logged to Logger. Defaults to LockLogDenies.
+	LogLevel LockLogLevel
+
+	// MaxSignBytesLen rejects larger sign bytes before they are decoded.
+	// Zero means DefaultMaxSignBytesLen.
+	MaxSignBytesLen int
+
+	// IncludeExtensionInValue locks on a hash of the block hash and the vote
+	// extension rather than the block hash alone, so that votes differing only
+	// in their extension are different values. Callers must then supply the
+	// extension with every request (see SignRequest.VoteExtension).
+	IncludeExtensionInValue bool
+
+	// TrackPartSetHeader also locks on the part set header hash of the block ID, so that
+	// votes for the same block hash with different part set headers are different values.
+	// It applies to canonical CometBFT sign bytes only.
+	TrackPartSetHeader bool
+
+	// FailClosedOnMissingState refuses to sign prevotes and precommits while the
+	// SignState is uninitialized (nothing signed yet and no lock), e.g. because
+	// the state file was missing and an empty one was created in its place.
+	FailClosedOnMissingState bool
+
+	// MultiHeight tracks a separate lock for each of the most recent heights,
+	// for setups that sign overlapping heights (pipelined consensus), so that
+	// locks at height N and N+1 do not clobber each other.
+	MultiHeight bool
+
+	// OnViolation, if set, is called once for every sign request rejected because
+	// it violates the consensus lock or would double sign (see IsDoubleSignError),
+	// e.g. to notify a slashing monitor. It is called with the SignState lock held,
+	// so it must not block or call back into the SignState.
+	OnViolation func(err error, hrs HRSKey, attempted []byte)
+
+	// MaxTimestampSkew, if non-zero, rejects sign bytes whose timestamp differs
+	// from the local clock by more than this much, which may indicate a replay
+	// or a misconfigured node. Sign bytes that cannot be decoded are rejected too.
+	MaxTimestampSkew time.Duration
+
+	// MonotonicTimestamps rejects sign bytes whose timestamp is earlier than one already
+	// approved at the same height with a *TimestampRegressionError. Sign bytes that
+	// cannot be decoded are rejected too.
+	MonotonicTimestamps bool
+
+	// GlobalMonotonicHRS rejects every request at or below the highest HRS ever signed with a
+	// *HRSNotIncreasingError, independent of the lock, so that not even a retry of the last
+	// request or a request after RollbackTo is processed. The highest HRS is persisted as
+	// HighestHRS.
+	GlobalMonotonicHRS bool
+
+	// MaxRound, if non-zero, rejects sign requests for any round above it with a
+	// *RoundCeilingError. Consensus rarely needs more than a handful of rounds, so
+	// a very high round usually means a buggy or malicious sentry.
+	MaxRound int32
+
+	// ValueExtractor extracts the locked value from sign bytes, for message schemas
+	// other than canonical CometBFT proposals and votes. Defaults to BlockHashExtractor.
+	ValueExtractor ValueExtractor
+
+	// StepValueExtractors, if set, overrides ValueExtractor for the listed steps, for
+	// schemas where e.g. precommits carry their value differently from prevotes.
+	StepValueExtractors map[int8]ValueExtractor
+
+	// ValueLength, if non-zero, is the length of the chain's block hashes. Sign bytes carrying
+	// a block value of another length are rejected with a *HashLengthError when decoded.
+	ValueLength int
+
+	// AllowUnknownValueTypes accepts values of a type other than ValueTypeBlock and
+	// ValueTypeNil from a custom ValueExtractor. They are rejected by default.
+	AllowUnknownValueTypes bool
+
+	// ValidateTimeout, if non-zero, fails validation of a sign request with a
+	// *ValidateTimeoutError when it takes longer than this, e.g. because of a
+	// pathological decode. The validation itself still runs to completion, but its
+	// decision is not recorded.
+	ValidateTimeout time.Duration
+
+	// Tracer, if set, traces every validation in a span with the request HRS and
+	// the decision as attributes.
+	Tracer Tracer
+
+	// EquivalentValues lists values that count as the same value for the lock, e.g.
+	// canonical and alternate encodings of one block. Signing any of them is allowed
+	// while locked on any of them. The set should include the canonical value.
+	EquivalentValues [][]byte
+
+	// LongLockWarnRounds, if non-zero, logs a warning once per lock when the lock blocks
+	// a value more than this many rounds after the lock round.
+	LongLockWarnRounds int64
+
+	// ReleaseSteps, if set, lists the steps that set and move the lock instead of only
+	// precommits. A step that is not listed is held to the lock like a prevote.
+	ReleaseSteps map[int8]bool
+
+	// ReapMargin is how many heights below the last signed height the reaper started
+	// with StartReaper keeps per-HRS tracking data for. Zero means the cache window.
+	ReapMargin int64
+
+	// HaltOnDoubleSign halts the signer when it is asked to sign conflicting data at an HRS it
+	// already signed: every later sign request is refused with a *SignerHaltedError, also
+	// after a restart, until an operator calls Unhalt, e.g. with horcrux state unhalt.
+	HaltOnDoubleSign bool
+
+	// ShadowMode records but does not enforce the consensus lock, e.g. to try it on a
+	// production validator: validation that would fail is logged, counted and reported
+	// to OnViolation as usual, but the request is allowed. A halted signer (see Unhalt)
+	// still refuses to sign.
+	ShadowMode bool
+
+	// CanaryMode never refuses a request, e.g. for canary validators that must not take any
+	// blocking risk, but unlike ShadowMode records everything as if enforcing: requests that
+	// would be refused are logged, counted and reported to OnViolation, but do not count as
+	// approvals, and ValidateAndAdvance does not advance the lock for them. Requests are
+	// allowed even when halted.
+	CanaryMode bool
+
+	// QuorumLockChecker, if set, is asked for the cosigners' consensus locks by
+	// ValidateConsensusLock and ValidateSignRequest whenever we are locked at the request
+	// height. The request is then refused with a *QuorumNotMetError unless at least
+	// QuorumThreshold cosigners agree on that lock. It is called without the SignState lock
+	// held, with the context passed WithContext, bounded by ValidateTimeout or a default.
+	QuorumLockChecker QuorumLockChecker
+
+	// QuorumThreshold is how many cosigners must agree on the lock for QuorumLockChecker.
+	QuorumThreshold int
+
+	// ExpectedProposerAddr, if set, is the address of the validator this signer signs for.
+	// Proposals whose request names another proposer (see SignRequest.Proposer) are rejected
+	// with a *ProposerMismatchError.
+	ExpectedProposerAddr []byte
+
+	// StepEncoding selects how steps map to signed message types when sign bytes are decoded,
+	// for the Tendermint or CometBFT version of the chain. Defaults to StepEncodingCometBFT.
+	StepEncoding StepEncoding
+
+	// AllowZeroValue lets precommits for an all-zero value set or move the lock. Such a value
+	// is almost certainly a bug rather than a real block hash, so by default it is treated
+	// like nil and leaves the lock unchanged.
+	AllowZeroValue bool
+}
+
+// clone returns a copy of opts that shares no slices or maps with it. Function hooks and
+// interfaces, such as OnViolation and ValueExtractor, are shared.
+func (opts ConsensusLockOptions) clone() ConsensusLockOptions {
+	if opts.EquivalentValues != nil {
+		values := make([][]byte, len(opts.EquivalentValues))
+		for i, value := range opts.EquivalentValues {
+			values[i] = append([]byte(nil), value...)
+		}
+		opts.EquivalentValues = values
+	}
+	opts.ExpectedProposerAddr = append([]byte(nil), opts.ExpectedProposerAddr...)
+	opts.ReleaseSteps = maps.Clone(opts.ReleaseSteps)
+	opts.StepValueExtractors = maps.Clone(opts.StepValueExtractors)
+	return opts
+}
+
+// lockValue returns the value the consensus lock tracks for a block hash and vote extension.
+func (opts ConsensusLockOptions) lockValue(blockHash, extension []byte) []byte {
+	if !opts.IncludeExtensionInValue || len(blockHash) == 0 {
+		return blockHash
+	}
+	h := sha256.New()
+	h.Write(blockHash)
+	h.Write(extension)
+	return h.Sum(nil)
+}
+
+// refusesZeroValue returns true if value is all zeros and AllowZeroValue is not set.
+func (opts ConsensusLockOptions) refusesZeroValue(value []byte) bool {
+	if opts.AllowZeroValue || len(value) == 0 {
+		return false
+	}
+	for _, b := range value {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// partSetHeaderHash returns the part set header hash of the block ID in signBytes if
+// TrackPartSetHeader is set, and nil otherwise or if there is none.
+func (opts ConsensusLockOptions) partSetHeaderHash(step int8, signBytes []byte) []byte {
+	if !opts.TrackPartSetHeader {
+		return nil
+	}
+	decoded, err := opts.decode(step, signBytes)
+	if err != nil || decoded.blockID == nil || len(decoded.blockID.PartSetHeader.Hash) == 0 {
+		return nil
+	}
+	return decoded.blockID.PartSetHeader.Hash
+}
+
+// sameLockedValue returns true if value, extracted from signBytes, is the value of lock.
+// With TrackPartSetHeader, a lock with a part set header also requires signBytes to
+// carry the same part set header hash.
+func (opts ConsensusLockOptions) sameLockedValue(lock ConsensusLock, value []byte, step int8, signBytes []byte) bool {
+	if !opts.equivalentValues(value, lock.Value) {
+		return false
+	}
+	if !opts.TrackPartSetHeader || len(lock.PartSetHeader) == 0 {
+		return true
+	}
+	return lockValuesEqual(opts.partSetHeaderHash(step, signBytes), lock.PartSetHeader)
+}
+
+// lockSemantics returns the lock semantics of step, taking ReleaseSteps into account.
+func (opts ConsensusLockOptions) lockSemantics(step int8) lockSemantics {
+	if opts.ReleaseSteps == nil {
+		return stepLockSemantics(step)
+	}
+	if opts.ReleaseSteps[step] {
+		return lockReleasing
+	}
+	if semantics := stepLockSemantics(step); semantics != lockReleasing {
+		return semantics
+	}
+	return lockConstrained
+}
+
+// equivalentValues returns true if a and b are equal, or both in EquivalentValues.
+func (opts ConsensusLockOptions) equivalentValues(a, b []byte) bool {
+	if lockValuesEqual(a, b) {
+		return true
+	}
+	if len(opts.EquivalentValues) == 0 {
+		return false
+	}
+	var aFound, bFound bool
+	for _, v := range opts.EquivalentValues {
+		aFound = aFound || lockValuesEqual(a, v)
+		bFound = bFound || lockValuesEqual(b, v)
+	}
+	return aFound && bFound
+}
+
+// checkReleasingValue returns a *BlockHashExtractionError if hrs is a releasing step whose
+// sign bytes do not carry a value the lock can be set on. Votes for nil are fine.
+func (opts ConsensusLockOptions) checkReleasingValue(hrs HRSKey, signBytes []byte) error {
+	if opts.lockSemantics(hrs.Step) != lockReleasing {
+		return nil
+	}
+	value, err := opts.extractValue(hrs.Step, signBytes)
+	if err == nil && len(value) > 0 {
+		err = opts.checkLockableValue(hrs.Step, value)
+	}
+	if err != nil {
+		return newBlockHashExtractionError(hrs.Step, err)
+	}
+	return nil
+}
+
+// checkLockableValue returns a *SuspiciousDecodeError if value is not a block hash, so that
+// a malformed value never ends up in the lock. Values from a custom ValueExtractor are not checked.
+func (opts ConsensusLockOptions) checkLockableValue(step int8, value []byte) error {
+	if _, ok := opts.valueExtractor(step).(BlockHashExtractor); !ok || len(value) == opts.blockHashLength() {
+		return nil
+	}
+	return newSuspiciousDecodeError("value",
+		fmt.Sprintf("value length %d, expected %d", len(value), opts.blockHashLength()))
+}
+
+// blockHashLength returns the length of the chain's block hashes: ValueLength if set,
+// else that of a CometBFT hash.
+func (opts ConsensusLockOptions) blockHashLength() int {
+	if opts.ValueLength > 0 {
+		return opts.ValueLength
+	}
+	return tmhash.Size
+}
+
+func (opts ConsensusLockOptions) maxSignBytesLen() int {
+	if opts.MaxSignBytesLen > 0 {
+		return opts.MaxSignBytesLen
+	}
+	return DefaultMaxSignBytesLen
+}
@@ -0,0 +1,17 @@
+package signer
+
+// LockProgressDelta returns how far lock a is ahead of lock b, as a - b for the height
+// and for the round. An unlocked lock counts as being at height 0 and round -1, before
+// any real lock, so a lock is always ahead of no lock.
+func LockProgressDelta(a, b ConsensusLock) (heightDelta int64, roundDelta int64) {
+	aHeight, aRound := lockProgress(a)
+	bHeight, bRound := lockProgress(b)
+	return aHeight - bHeight, aRound - bRound
+}
+
+func lockProgress(lock ConsensusLock) (height int64, round int64) {
+	if !lock.IsLocked() {
+		return 0, -1
+	}
+	return lock.Height, lock.Round
+}
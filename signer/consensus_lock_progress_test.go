@@ -0,0 +1,36 @@
+package signer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockProgressDelta(t *testing.T) {
+	value := []byte("block_hash_123456789012345678901234567890")[:32]
+	lockAt := func(height, round int64) ConsensusLock {
+		return ConsensusLock{Height: height, Round: round, Value: value}
+	}
+
+	testCases := []struct {
+		name        string
+		a, b        ConsensusLock
+		heightDelta int64
+		roundDelta  int64
+	}{
+		{"a ahead", lockAt(101, 3), lockAt(100, 1), 1, 2},
+		{"b ahead", lockAt(100, 0), lockAt(100, 4), 0, -4},
+		{"b unlocked", lockAt(100, 2), ConsensusLock{}, 100, 3},
+		{"a unlocked", ConsensusLock{}, lockAt(100, 0), -100, -1},
+		{"both unlocked", ConsensusLock{}, ConsensusLock{}, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			heightDelta, roundDelta := LockProgressDelta(tc.a, tc.b)
+			require.Equal(t, tc.heightDelta, heightDelta)
+			require.Equal(t, tc.roundDelta, roundDelta)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package signer
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ProposerMismatchError is returned with ExpectedProposerAddr when a proposal would be signed
+// by a validator with another address, i.e. the signer is set up with the wrong validator key.
+type ProposerMismatchError struct {
+	HRS      HRSKey
+	Expected []byte
+	Proposer []byte
+}
+
+func (e *ProposerMismatchError) Error() string {
+	return fmt.Sprintf("proposal at %d:%d would be signed by %X, expected proposer %X",
+		e.HRS.Height, e.HRS.Round, e.Proposer, e.Expected)
+}
+
+func newProposerMismatchError(hrs HRSKey, expected, proposer []byte) *ProposerMismatchError {
+	return &ProposerMismatchError{
+		HRS:      hrs,
+		Expected: expected,
+		Proposer: proposer,
+	}
+}
+
+// checkProposer returns a *ProposerMismatchError if req is a proposal whose proposer is not
+// ExpectedProposerAddr. Canonical proposal sign bytes do not name the proposer, so it is taken
+// from the request (see SignRequest.Proposer); requests that do not carry it are not checked.
+func (opts ConsensusLockOptions) checkProposer(req SignRequest) error {
+	if req.HRS.Step != stepPropose || len(opts.ExpectedProposerAddr) == 0 || req.Proposer == nil {
+		return nil
+	}
+	if !bytes.Equal(req.Proposer, opts.ExpectedProposerAddr) {
+		return newProposerMismatchError(req.HRS, opts.ExpectedProposerAddr, req.Proposer)
+	}
+	return nil
+}
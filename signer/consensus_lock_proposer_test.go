@@ -0,0 +1,56 @@
+package signer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpectedProposerAddr(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	ourAddr := []byte("our_validator_address")
+	otherAddr := []byte("other_validator_address")
+
+	signState := &SignState{ConsensusLockOptions: ConsensusLockOptions{ExpectedProposerAddr: ourAddr}}
+	propose := func(proposer []byte) error {
+		return signState.ValidateSignRequest(SignRequest{
+			HRS:       HRSKey{Height: 100, Round: 0, Step: stepPropose},
+			SignBytes: createTestSignBytes(blockHash, stepPropose),
+			PolRound:  -1,
+			Proposer:  proposer,
+		})
+	}
+
+	// Proposals signed by our validator pass
+	require.NoError(t, propose(ourAddr))
+
+	// Proposals signed by another validator are refused
+	err := propose(otherAddr)
+	require.True(t, IsProposerMismatchError(err))
+	var mismatchErr *ProposerMismatchError
+	require.ErrorAs(t, err, &mismatchErr)
+	require.Equal(t, ourAddr, mismatchErr.Expected)
+	require.Equal(t, otherAddr, mismatchErr.Proposer)
+
+	// Requests that do not name the proposer, and votes, are not checked
+	require.NoError(t, propose(nil))
+	require.NoError(t, signState.ValidateSignRequest(SignRequest{
+		HRS:       HRSKey{Height: 100, Round: 0, Step: stepPrevote},
+		SignBytes: createTestSignBytes(blockHash, stepPrevote),
+		PolRound:  -1,
+		Proposer:  otherAddr,
+	}))
+
+	// The policy takes the proposer from the context
+	policy := ConsensusLockPolicy{State: signState}
+	hrs := HRSKey{Height: 100, Round: 1, Step: stepPropose}
+	ctx := WithPolRound(context.Background(), -1)
+	require.NoError(t, policy.Check(WithProposer(ctx, ourAddr), hrs, createTestSignBytes(blockHash, stepPropose)))
+	require.True(t, IsProposerMismatchError(
+		policy.Check(WithProposer(ctx, otherAddr), hrs, createTestSignBytes(blockHash, stepPropose))))
+
+	// Without an expected proposer nothing is checked
+	signState.ExpectedProposerAddr = nil
+	require.NoError(t, propose(otherAddr))
+}
@@ -0,0 +1,129 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultQuorumCheckTimeout bounds QuorumLockChecker when ValidateTimeout is not set.
+const defaultQuorumCheckTimeout = 4 * time.Second
+
+// errLockChangedDuringQuorumCheck refuses a request whose lock became relevant while the
+// cosigners were not asked about it.
+var errLockChangedDuringQuorumCheck = errors.New("consensus lock changed during quorum check")
+
+// QuorumLockChecker asks the cosigners for their consensus lock. It returns how many of
+// the total cosigners agree on lock, the lock held by the largest group of them.
+type QuorumLockChecker func(ctx context.Context) (agree int, total int, lock ConsensusLock, err error)
+
+// quorumAnswer is what QuorumLockChecker answered.
+type quorumAnswer struct {
+	agree int
+	total int
+	lock  ConsensusLock
+	err   error
+}
+
+// askQuorum asks QuorumLockChecker for the cosigners' locks if it is set and a lock is relevant
+// to hrs, and returns nil otherwise. It must be called without the SignState lock held, since the
+// cosigners may take a while to answer. The question is bounded by ctx and by ValidateTimeout or,
+// if that is not set, defaultQuorumCheckTimeout.
+func (signState *SignState) askQuorum(ctx context.Context, hrs HRSKey) *quorumAnswer {
+	if signState.QuorumLockChecker == nil {
+		return nil
+	}
+	signState.mu.RLock()
+	relevant := signState.lockedQuorumRelevant(hrs)
+	signState.mu.RUnlock()
+	if !relevant {
+		return nil
+	}
+
+	timeout := signState.ValidateTimeout
+	if timeout <= 0 {
+		timeout = defaultQuorumCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var answer quorumAnswer
+	answer.agree, answer.total, answer.lock, answer.err = signState.QuorumLockChecker(ctx)
+	return &answer
+}
+
+// lockedQuorumRelevant reports whether the cosigners must agree on our lock before signing
+// at hrs, i.e. whether we are locked at the height of hrs. Not thread-safe (requires external lock).
+func (signState *SignState) lockedQuorumRelevant(hrs HRSKey) bool {
+	if signState.DisableLock {
+		return false
+	}
+	lock := signState.lockedLockFor(hrs.Height)
+	return lock.IsLocked() && lock.Height == hrs.Height
+}
+
+// lockedCheckQuorumLock returns a *QuorumNotMetError if a lock is relevant to hrs and fewer than
+// QuorumThreshold cosigners agree on it according to answer, as returned by askQuorum.
+// Not thread-safe (requires external lock).
+func (signState *SignState) lockedCheckQuorumLock(hrs HRSKey, answer *quorumAnswer) error {
+	if signState.QuorumLockChecker == nil || !signState.lockedQuorumRelevant(hrs) {
+		return nil
+	}
+	if answer == nil {
+		return newQuorumNotMetError(hrs, 0, 0, signState.QuorumThreshold, errLockChangedDuringQuorumCheck)
+	}
+	if answer.err != nil {
+		return newQuorumNotMetError(hrs, 0, answer.total, signState.QuorumThreshold, answer.err)
+	}
+
+	// Only the lock at the request height matters
+	agree, lock := answer.agree, signState.lockedLockFor(hrs.Height)
+	if !answer.lock.IsLocked() || answer.lock.Height != hrs.Height || !lock.SameValue(answer.lock) {
+		agree = 0
+	}
+
+	if agree < signState.QuorumThreshold {
+		return newQuorumNotMetError(hrs, agree, answer.total, signState.QuorumThreshold, nil)
+	}
+	return nil
+}
+
+// QuorumNotMetError is returned when fewer than QuorumThreshold cosigners agree on the
+// consensus lock relevant to a sign request, or their locks could not be checked.
+type QuorumNotMetError struct {
+	HRS       HRSKey
+	Agree     int
+	Total     int
+	Threshold int
+	Err       error
+}
+
+func (e *QuorumNotMetError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("cannot check cosigner quorum on consensus lock for %d/%d/%d: %v",
+			e.HRS.Height, e.HRS.Round, e.HRS.Step, e.Err)
+	}
+	return fmt.Sprintf("only %d of %d cosigners agree on consensus lock for %d/%d/%d, need %d",
+		e.Agree, e.Total, e.HRS.Height, e.HRS.Round, e.HRS.Step, e.Threshold)
+}
+
+func (e *QuorumNotMetError) Unwrap() error {
+	return e.Err
+}
+
+func newQuorumNotMetError(hrs HRSKey, agree, total, threshold int, err error) *QuorumNotMetError {
+	return &QuorumNotMetError{
+		HRS:       hrs,
+		Agree:     agree,
+		Total:     total,
+		Threshold: threshold,
+		Err:       err,
+	}
+}
+
+// IsQuorumNotMetError checks if the error is a request refused because cosigners do not agree on the lock.
+func IsQuorumNotMetError(err error) bool {
+	var quorumErr *QuorumNotMetError
+	return errors.As(err, &quorumErr)
+}
@@ -0,0 +1,72 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuorumLockChecker(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	lock := ConsensusLock{Height: 100, Round: 0, Value: lockedValue}
+	hrs := HRSKey{Height: 100, Round: 1, Step: stepPrevote}
+	signBytes := createTestSignBytes(lockedValue, stepPrevote)
+
+	quorum := func(agree int, quorumLock ConsensusLock, err error) *SignState {
+		return &SignState{
+			ConsensusLock: lock,
+			ConsensusLockOptions: ConsensusLockOptions{
+				QuorumLockChecker: func(context.Context) (int, int, ConsensusLock, error) {
+					return agree, 3, quorumLock, err
+				},
+				QuorumThreshold: 2,
+			},
+		}
+	}
+
+	// Quorum met
+	require.NoError(t, quorum(2, lock, nil).ValidateConsensusLock(hrs, signBytes, -1))
+	require.NoError(t, quorum(3, lock, nil).ValidateConsensusLock(hrs, signBytes, -1))
+
+	// Quorum not met
+	var quorumErr *QuorumNotMetError
+	err := quorum(1, lock, nil).ValidateConsensusLock(hrs, signBytes, -1)
+	require.ErrorAs(t, err, &quorumErr)
+	require.Equal(t, 1, quorumErr.Agree)
+	require.Equal(t, 3, quorumErr.Total)
+	require.Equal(t, 2, quorumErr.Threshold)
+
+	// The quorum agrees on a different lock than ours
+	otherLock := ConsensusLock{Height: 100, Round: 0, Value: differentValue}
+	require.ErrorAs(t, quorum(3, otherLock, nil).ValidateConsensusLock(hrs, signBytes, -1), &quorumErr)
+	require.Zero(t, quorumErr.Agree)
+
+	// The cosigners could not be asked
+	checkErr := errors.New("cosigners unreachable")
+	err = quorum(3, lock, checkErr).ValidateConsensusLock(hrs, signBytes, -1)
+	require.ErrorAs(t, err, &quorumErr)
+	require.ErrorIs(t, err, checkErr)
+
+	// The check is bounded by the caller's context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	signState := quorum(3, lock, nil)
+	signState.QuorumLockChecker = func(ctx context.Context) (int, int, ConsensusLock, error) {
+		_, hasDeadline := ctx.Deadline()
+		require.True(t, hasDeadline)
+		return 0, 3, ConsensusLock{}, ctx.Err()
+	}
+	err = signState.ValidateConsensusLock(hrs, signBytes, -1, WithContext(ctx))
+	require.ErrorAs(t, err, &quorumErr)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// At a new height no lock is relevant yet, so the cosigners are not asked
+	next := HRSKey{Height: 101, Round: 0, Step: stepPrevote}
+	require.NoError(t, quorum(0, ConsensusLock{}, checkErr).ValidateConsensusLock(next, signBytes, -1))
+
+	// Not checked without a checker
+	require.NoError(t, (&SignState{ConsensusLock: lock}).ValidateConsensusLock(hrs, signBytes, -1))
+}
@@ -0,0 +1,62 @@
+package signer
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultReapInterval is how often StartReaper prunes when given a non-positive interval.
+const defaultReapInterval = time.Minute
+
+// StartReaper prunes per-HRS tracking data, i.e. approval and violation counts and saved
+// signatures, every interval. Entries more than ReapMargin heights below the
+// last signed height are dropped. Tracking data is otherwise only pruned when new entries are
+// added, so it would be kept forever once requests stop. A non-positive interval means
+// defaultReapInterval. The returned stop function waits for the reaper to exit and may be
+// called more than once.
+func (signState *SignState) StartReaper(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = defaultReapInterval
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				signState.reap()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
+}
+
+// reap drops tracking data for heights more than ReapMargin below the last signed height.
+func (signState *SignState) reap() {
+	signState.mu.RLock()
+	margin := signState.ReapMargin
+	if margin <= 0 {
+		margin = blocksToCache
+	}
+	minHeight := signState.Height - margin
+	signState.mu.RUnlock()
+
+	signState.approvals.prune(minHeight)
+	signState.violations.prune(minHeight)
+	signState.lockSignatures.Range(func(key, _ any) bool {
+		if key.(HRSKey).Height < minHeight {
+			signState.lockSignatures.Delete(key)
+		}
+		return true
+	})
+}
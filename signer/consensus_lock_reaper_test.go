@@ -0,0 +1,47 @@
+package signer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignStateReaper(t *testing.T) {
+	signState := &SignState{
+		Height:               100,
+		ConsensusLockOptions: ConsensusLockOptions{ReapMargin: 2},
+	}
+	current := HRSKey{Height: 100, Round: 0, Step: stepPrevote}
+	old := HRSKey{Height: 97, Round: 0, Step: stepPrevote}
+
+	// Entries are added newest first, so that adding does not prune them itself
+	for _, hrs := range []HRSKey{current, old} {
+		signState.approvals.add(hrs, []byte{byte(hrs.Height)}, func(_, _ []byte) bool { return false })
+		signState.recordLockSignature(hrs, []byte("signature"))
+	}
+	require.Equal(t, 1, signState.ApprovalsAtHeight(old.Height))
+
+	stop := signState.StartReaper(time.Millisecond)
+	require.Eventually(t, func() bool {
+		_, ok := signState.LockSignature(old)
+		return !ok && signState.ApprovalsAtHeight(old.Height) == 0
+	}, time.Second, time.Millisecond)
+
+	// Current entries are retained
+	_, ok := signState.LockSignature(current)
+	require.True(t, ok)
+	require.Equal(t, 1, signState.ApprovalsAtHeight(current.Height))
+
+	// Stopping is idempotent
+	stop()
+	stop()
+}
+
+func TestSignStateReaperDefaultInterval(t *testing.T) {
+	signState := &SignState{}
+	require.NotPanics(t, func() {
+		stop := signState.StartReaper(0)
+		stop()
+	})
+}
@@ -0,0 +1,89 @@
+package signer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HeightReport returns a human-readable audit report of height: the requests signed in each
+// round, the final locked value, the precommit that set or last moved the lock, the request
+// that released it when moving past the height, and how many consensus lock violations were
+// blocked. It reads the signature cache, the violation counts and the transition history,
+// which only hold recent data, so older heights report no signatures and no release.
+func (signState *SignState) HeightReport(height int64) string {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+
+	var signed []SignStateConsensus
+	for hrs, ssc := range signState.cache {
+		if hrs.Height == height {
+			signed = append(signed, ssc)
+		}
+	}
+	sort.Slice(signed, func(i, j int) bool {
+		return signed[i].HRSKey().LessThan(signed[j].HRSKey())
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "height %d\n", height)
+
+	rounds := make(map[int64]struct{})
+	for _, ssc := range signed {
+		rounds[ssc.Round] = struct{}{}
+	}
+	fmt.Fprintf(&sb, "rounds signed: %d\n", len(rounds))
+	for _, ssc := range signed {
+		fmt.Fprintf(&sb, "  round %d %s: %s\n", ssc.Round, signType(ssc.Step), signState.reportValue(ssc))
+	}
+
+	lock := signState.lockedLockFor(height)
+	release, released := signState.lockedFindRelease(height)
+	switch {
+	case lock.IsLocked() && lock.Height == height:
+		released = false
+	case released:
+		lock = release.from
+	}
+	if lock.IsLocked() && lock.Height == height {
+		fmt.Fprintf(&sb, "final locked value: %X (round %d)\n", lock.Value, lock.Round)
+		fmt.Fprintf(&sb, "set by: precommit %d/%d/%d\n", lock.SetBy.Height, lock.SetBy.Round, lock.SetBy.Step)
+		if released {
+			fmt.Fprintf(&sb, "released by: %s %d/%d/%d\n",
+				signType(release.hrs.Step), release.hrs.Height, release.hrs.Round, release.hrs.Step)
+		} else {
+			sb.WriteString("released by: none\n")
+		}
+	} else {
+		sb.WriteString("final locked value: none\n")
+	}
+
+	fmt.Fprintf(&sb, "violations blocked: %d\n", signState.violations.get(height))
+	return sb.String()
+}
+
+// reportValue formats the value signed by ssc for HeightReport.
+func (signState *SignState) reportValue(ssc SignStateConsensus) string {
+	value, err := signState.extractValue(ssc.Step, ssc.SignBytes)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("undecodable (%v)", err)
+	case isNilVote(ssc.Step, value):
+		return "nil"
+	default:
+		return fmt.Sprintf("%X", value)
+	}
+}
+
+// lockedFindRelease returns the most recent recorded transition that released a lock at
+// height without replacing it by another lock at height, i.e. the request that moved past
+// the height. Not thread-safe (requires external lock).
+func (signState *SignState) lockedFindRelease(height int64) (lockTransition, bool) {
+	for i := len(signState.transitions) - 1; i >= 0; i-- {
+		t := signState.transitions[i]
+		if t.from.IsLocked() && t.from.Height == height && !(t.to.IsLocked() && t.to.Height == height) {
+			return t, true
+		}
+	}
+	return lockTransition{}, false
+}
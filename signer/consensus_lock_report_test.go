@@ -0,0 +1,76 @@
+package signer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cometbft/cometbft/libs/protoio"
+	cometproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeightReport(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+
+	signed := func(round int64, step int8, value []byte) SignStateConsensus {
+		vote := &cometproto.CanonicalVote{Type: StepToType(step), Height: 100, Round: round}
+		if value != nil {
+			vote.BlockID = &cometproto.CanonicalBlockID{Hash: value}
+		}
+		signBytes, err := protoio.MarshalDelimited(vote)
+		require.NoError(t, err)
+		return SignStateConsensus{Height: 100, Round: round, Step: step, SignBytes: signBytes}
+	}
+
+	// Round 0 precommits nil, round 1 locks on the value
+	signState := &SignState{
+		Height: 100,
+		Round:  1,
+		Step:   stepPrecommit,
+		ConsensusLock: ConsensusLock{
+			Height: 100,
+			Round:  1,
+			Value:  lockedValue,
+			SetBy:  HRSKey{Height: 100, Round: 1, Step: stepPrecommit},
+		},
+		cache: make(map[HRSKey]SignStateConsensus),
+	}
+	for _, ssc := range []SignStateConsensus{
+		signed(0, stepPrevote, nil),
+		signed(0, stepPrecommit, nil),
+		signed(1, stepPrevote, lockedValue),
+		signed(1, stepPrecommit, lockedValue),
+	} {
+		signState.cache[ssc.HRSKey()] = ssc
+	}
+
+	// A later prevote for a different value without a POL round is blocked
+	err := signState.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 2, Step: stepPrevote}, createTestSignBytes(differentValue, stepPrevote), -1)
+	require.Error(t, err)
+
+	report := signState.HeightReport(100)
+	require.Contains(t, report, "rounds signed: 2")
+	require.Contains(t, report, "round 0 precommit: nil")
+	require.Contains(t, report, fmt.Sprintf("round 1 precommit: %X", lockedValue))
+	require.Contains(t, report, fmt.Sprintf("final locked value: %X (round 1)", lockedValue))
+	require.Contains(t, report, "set by: precommit 100/1/3")
+	require.Contains(t, report, "released by: none")
+	require.Contains(t, report, "violations blocked: 1")
+
+	// Moving on to the next height releases the lock, which is still reported
+	nextHRS := HRSKey{Height: 101, Round: 0, Step: stepPrevote}
+	_, err = signState.AdvanceConsensusLock(nextHRS, createTestSignBytes(differentValue, stepPrevote))
+	require.NoError(t, err)
+	report = signState.HeightReport(100)
+	require.Contains(t, report, fmt.Sprintf("final locked value: %X (round 1)", lockedValue))
+	require.Contains(t, report, "set by: precommit 100/1/3")
+	require.Contains(t, report, "released by: prevote 101/0/2")
+
+	// Nothing is known about other heights
+	report = signState.HeightReport(99)
+	require.Contains(t, report, "rounds signed: 0")
+	require.Contains(t, report, "final locked value: none")
+	require.Contains(t, report, "violations blocked: 0")
+}
@@ -0,0 +1,55 @@
+package signer
+
+import "context"
+
+// ApproveOption configures optional behavior when a sign request is approved
+// against the consensus lock.
+type ApproveOption func(*approveConfig)
+
+type approveConfig struct {
+	ctx context.Context
+}
+
+// WithContext bounds the cosigner quorum check (see QuorumLockChecker) by ctx, e.g. the
+// deadline of the sign request being validated.
+func WithContext(ctx context.Context) ApproveOption {
+	return func(cfg *approveConfig) {
+		cfg.ctx = ctx
+	}
+}
+
+func newApproveConfig(opts []ApproveOption) approveConfig {
+	cfg := approveConfig{ctx: context.Background()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// recordLockSignature stores a copy of the signature produced for hrs, so that it can later
+// be retrieved with LockSignature, e.g. for dispute resolution, and forgets signatures for
+// heights that have fallen out of the cache window. The lock logic itself never depends on it.
+func (signState *SignState) recordLockSignature(hrs HRSKey, signature []byte) {
+	sig := make([]byte, len(signature))
+	copy(sig, signature)
+	signState.lockSignatures.Store(hrs, sig)
+
+	signState.lockSignatures.Range(func(key, _ any) bool {
+		if key.(HRSKey).Height < hrs.Height-blocksToCache {
+			signState.lockSignatures.Delete(key)
+		}
+		return true
+	})
+}
+
+// LockSignature returns the signature saved for hrs, if any.
+func (signState *SignState) LockSignature(hrs HRSKey) ([]byte, bool) {
+	v, ok := signState.lockSignatures.Load(hrs)
+	if !ok {
+		return nil, false
+	}
+	sig := v.([]byte)
+	out := make([]byte, len(sig))
+	copy(out, sig)
+	return out, true
+}
@@ -1,11 +1,20 @@
 package signer
 
 import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	cometlog "github.com/cometbft/cometbft/libs/log"
 	"github.com/cometbft/cometbft/libs/protoio"
 	cometproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
 )
 
 // createTestSignBytes creates proper Tendermint sign bytes for testing
@@ -28,7 +37,7 @@ func createTestSignBytes(blockHash []byte, step int8) []byte {
 	case stepPrevote, stepPrecommit:
 		// Create a CanonicalVote
 		vote := &cometproto.CanonicalVote{
-			Type:   cometproto.SignedMsgType(step),
+			Type:   StepToType(step),
 			Height: 100,
 			Round:  5,
 			BlockID: &cometproto.CanonicalBlockID{
@@ -203,7 +212,7 @@ func TestConsensusLockClearing(t *testing.T) {
 	}
 
 	// Test 1: Clear lock when moving to different height
-	signState.ClearConsensusLock(HRSKey{Height: 101, Round: 5, Step: stepPrevote})
+	_, _ = signState.ClearConsensusLock(HRSKey{Height: 101, Round: 5, Step: stepPrevote})
 	if signState.ConsensusLock.IsLocked() {
 		t.Error("Expected lock to be cleared when moving to different height")
 	}
@@ -216,7 +225,7 @@ func TestConsensusLockClearing(t *testing.T) {
 	}
 
 	// Test 2: Don't clear lock when moving to higher round (locks persist for all future rounds)
-	signState.ClearConsensusLock(HRSKey{Height: 100, Round: 6, Step: stepPrevote})
+	_, _ = signState.ClearConsensusLock(HRSKey{Height: 100, Round: 6, Step: stepPrevote})
 	if !signState.ConsensusLock.IsLocked() {
 		t.Error("Expected lock to remain when moving to higher round (locks persist for all future rounds)")
 	}
@@ -229,13 +238,1826 @@ func TestConsensusLockClearing(t *testing.T) {
 	}
 
 	// Test 3: Don't clear lock when moving to same or lower round
-	signState.ClearConsensusLock(HRSKey{Height: 100, Round: 5, Step: stepPrevote})
+	_, _ = signState.ClearConsensusLock(HRSKey{Height: 100, Round: 5, Step: stepPrevote})
 	if !signState.ConsensusLock.IsLocked() {
 		t.Error("Expected lock to remain when moving to same round")
 	}
 
-	signState.ClearConsensusLock(HRSKey{Height: 100, Round: 4, Step: stepPrevote})
+	_, _ = signState.ClearConsensusLock(HRSKey{Height: 100, Round: 4, Step: stepPrevote})
 	if !signState.ConsensusLock.IsLocked() {
 		t.Error("Expected lock to remain when moving to lower round")
 	}
 }
+
+func TestClearConsensusLockOutcome(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	lock := ConsensusLock{Height: 100, Round: 5, Value: lockedValue}
+
+	for _, tc := range []struct {
+		name        string
+		lock        ConsensusLock
+		hrs         HRSKey
+		expected    ClearLockOutcome
+		expectClear bool
+	}{
+		{"already empty", ConsensusLock{}, HRSKey{Height: 101, Round: 0}, LockAlreadyEmpty, false},
+		{"later height", lock, HRSKey{Height: 101, Round: 0}, LockClearedHeightChange, true},
+		{"earlier height", lock, HRSKey{Height: 99, Round: 7}, LockCleared, true},
+		{"lower round", lock, HRSKey{Height: 100, Round: 4}, LockKeptLowerRound, false},
+		{"same round", lock, HRSKey{Height: 100, Round: 5}, LockKeptSameRound, false},
+		{"later round", lock, HRSKey{Height: 100, Round: 6}, LockKeptLaterRound, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			signState := &SignState{ConsensusLock: tc.lock}
+			outcome, err := signState.ClearConsensusLock(tc.hrs)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, outcome, outcome.String())
+			if tc.expectClear || !tc.lock.IsLocked() {
+				require.False(t, signState.ConsensusLock.IsLocked())
+			} else {
+				require.Equal(t, tc.lock, signState.ConsensusLock)
+			}
+		})
+	}
+}
+
+func TestClearConsensusLockPinned(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	lock := ConsensusLock{Height: 100, Round: 5, Value: lockedValue, Pinned: true}
+	signState := &SignState{ConsensusLock: lock}
+
+	// A pinned lock survives a height change
+	outcome, err := signState.ClearConsensusLock(HRSKey{Height: 101, Round: 0, Step: stepPrevote})
+	var pinnedErr *PinnedLockError
+	require.ErrorAs(t, err, &pinnedErr)
+	require.Equal(t, LockKeptPinned, outcome)
+	require.Equal(t, HRSKey{Height: 100, Round: 5, Step: stepPrecommit}, pinnedErr.Lock)
+	require.Equal(t, lock, signState.ConsensusLock)
+
+	// Pinning does not affect same-height outcomes
+	outcome, err = signState.ClearConsensusLock(HRSKey{Height: 100, Round: 6, Step: stepPrevote})
+	require.NoError(t, err)
+	require.Equal(t, LockKeptLaterRound, outcome)
+
+	// Pinned is persisted with the lock
+	bz, err := lock.MarshalJSON()
+	require.NoError(t, err)
+	var decoded ConsensusLock
+	require.NoError(t, decoded.UnmarshalJSON(bz))
+	require.True(t, decoded.Pinned)
+
+	// Once unpinned, the lock clears as usual
+	signState.ConsensusLock.Pinned = false
+	outcome, err = signState.ClearConsensusLock(HRSKey{Height: 101, Round: 0, Step: stepPrevote})
+	require.NoError(t, err)
+	require.Equal(t, LockClearedHeightChange, outcome)
+	require.False(t, signState.ConsensusLock.IsLocked())
+}
+
+func TestConsensusLockValueComparison(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	signState := &SignState{
+		Height: 100,
+		Round:  5,
+		Step:   stepPrecommit,
+		ConsensusLock: ConsensusLock{
+			Height: 100,
+			Round:  5,
+			Value:  lockedValue,
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		value            []byte
+		expectErr        bool
+		expectSuspicious bool // Hashes of the wrong length are rejected before comparison
+	}{
+		{"equal", append([]byte(nil), lockedValue...), false, false},
+		{"unequal", []byte("different_block_hash_1234567890123456789")[:32], true, false},
+		{"shorter", lockedValue[:31], true, true},
+		{"longer", append(append([]byte(nil), lockedValue...), 0x00), true, true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, !tc.expectErr, lockValuesEqual(tc.value, lockedValue))
+
+			signBytes := createTestSignBytes(tc.value, stepPrevote)
+			err := signState.ValidateConsensusLock(HRSKey{Height: 100, Round: 5, Step: stepPrevote}, signBytes, -1)
+			switch {
+			case tc.expectSuspicious:
+				var suspiciousErr *SuspiciousDecodeError
+				require.ErrorAs(t, err, &suspiciousErr)
+			case tc.expectErr:
+				require.True(t, IsConsensusLockViolationError(err), "expected lock violation, got %v", err)
+			default:
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConsensusLockBlockEarlierRoundPropose(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234567890")[:32]
+	signState := &SignState{
+		Height: 100,
+		Round:  5,
+		Step:   stepPrecommit,
+		ConsensusLock: ConsensusLock{
+			Height: 100,
+			Round:  5,
+			Value:  lockedValue,
+		},
+	}
+
+	lockedProposal := createTestSignBytes(lockedValue, stepPropose)
+	differentProposal := createTestSignBytes(differentValue, stepPropose)
+	earlierRound := HRSKey{Height: 100, Round: 4, Step: stepPropose}
+
+	// Without the flag, earlier rounds are not constrained by the lock
+	require.NoError(t, signState.ValidateConsensusLock(earlierRound, lockedProposal, -1))
+	require.NoError(t, signState.ValidateConsensusLock(earlierRound, differentProposal, -1))
+
+	signState.BlockEarlierRoundPropose = true
+
+	err := signState.ValidateConsensusLock(earlierRound, lockedProposal, -1)
+	require.True(t, IsConsensusLockStepViolationError(err), "expected step violation, got %v", err)
+
+	err = signState.ValidateConsensusLock(earlierRound, differentProposal, -1)
+	require.True(t, IsConsensusLockStepViolationError(err), "expected step violation, got %v", err)
+
+	// Earlier round prevotes are unaffected by the flag
+	earlierPrevote := createTestSignBytes(differentValue, stepPrevote)
+	earlierPrevoteHRS := HRSKey{Height: 100, Round: 4, Step: stepPrevote}
+	require.NoError(t, signState.ValidateConsensusLock(earlierPrevoteHRS, earlierPrevote, -1))
+
+	// Proposing the locked value at or after the lock round is still allowed
+	for _, round := range []int64{5, 6} {
+		hrs := HRSKey{Height: 100, Round: round, Step: stepPropose}
+		require.NoError(t, signState.ValidateConsensusLock(hrs, lockedProposal, -1))
+	}
+}
+
+func TestConsensusLockAgeMetric(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	lockedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := lockedAt.Add(90 * time.Second)
+
+	signState := &SignState{
+		Height: 100,
+		Round:  5,
+		Step:   stepPrecommit,
+		ConsensusLock: ConsensusLock{
+			Height:    100,
+			Round:     5,
+			Value:     lockedValue,
+			UpdatedAt: lockedAt,
+		},
+	}
+	signState.Now = func() time.Time { return now }
+
+	lockedPrevote := createTestSignBytes(lockedValue, stepPrevote)
+	hrs := HRSKey{Height: 100, Round: 6, Step: stepPrevote}
+
+	require.NoError(t, signState.ValidateConsensusLock(hrs, lockedPrevote, -1))
+	require.Equal(t, float64(90), testutil.ToFloat64(consensusLockAge))
+
+	now = now.Add(30 * time.Second)
+	require.NoError(t, signState.ValidateConsensusLock(hrs, lockedPrevote, -1))
+	require.Equal(t, float64(120), testutil.ToFloat64(consensusLockAge))
+
+	signState.ConsensusLock = ConsensusLock{}
+	require.NoError(t, signState.ValidateConsensusLock(hrs, lockedPrevote, -1))
+	require.Equal(t, float64(0), testutil.ToFloat64(consensusLockAge))
+}
+
+func TestConsensusLockUpdatedAt(t *testing.T) {
+	ss, err := LoadOrCreateSignState(t.TempDir() + "/sign_state.json")
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ss.Now = func() time.Time { return now }
+
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	require.NoError(t, ss.Save(SignStateConsensus{
+		Height:    100,
+		Round:     5,
+		Step:      stepPrecommit,
+		SignBytes: createTestSignBytes(lockedValue, stepPrecommit),
+	}, nil))
+	require.Equal(t, now, ss.ConsensusLock.UpdatedAt)
+
+	// A later prevote for the same height keeps the lock and its timestamp
+	later := now.Add(time.Minute)
+	ss.Now = func() time.Time { return later }
+	require.NoError(t, ss.Save(SignStateConsensus{
+		Height:    100,
+		Round:     6,
+		Step:      stepPrevote,
+		SignBytes: createTestSignBytes(lockedValue, stepPrevote),
+	}, nil))
+	require.Equal(t, now, ss.ConsensusLock.UpdatedAt)
+}
+
+func TestWarmUpDecoderConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			WarmUpDecoder()
+		}()
+	}
+	wg.Wait()
+
+	// Calling again after warm up is a no-op
+	WarmUpDecoder()
+
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	hash, err := extractBlockHashFromSignBytes(createTestSignBytes(blockHash, stepPropose), stepPropose)
+	require.NoError(t, err)
+	require.Equal(t, blockHash, hash)
+}
+
+func TestStepRegistryCommitStep(t *testing.T) {
+	const stepCommit int8 = 4
+
+	stepRegistry[stepCommit] = stepSemantics{name: "commit", decode: decodeCanonicalVote, lock: lockReleasing}
+	defer delete(stepRegistry, stepCommit)
+
+	require.Equal(t, "commit", signType(stepCommit))
+
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	commitValue := []byte("commit_block_hash_123456789012345678901234567890")[:32]
+	commitBytes := createTestSignBytes(commitValue, stepPrecommit)
+
+	hash, err := extractBlockHashFromSignBytes(commitBytes, stepCommit)
+	require.NoError(t, err)
+	require.Equal(t, commitValue, hash)
+
+	existing := ConsensusLock{Height: 100, Round: 5, Value: lockedValue}
+
+	// Releasing steps are not constrained by the lock
+	signState := &SignState{Height: 100, Round: 5, Step: stepPrecommit, ConsensusLock: existing}
+	err = signState.ValidateConsensusLock(HRSKey{Height: 100, Round: 6, Step: stepCommit}, commitBytes, -1)
+	require.NoError(t, err)
+
+	// Releasing steps move the lock to the new value in a later round
+	lock := nextConsensusLock(existing, HRSKey{Height: 100, Round: 6, Step: stepCommit}, commitBytes)
+	require.Equal(t, int64(6), lock.Round)
+	require.Equal(t, commitValue, lock.Value)
+}
+
+func TestSameSignedValue(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	otherHash := []byte("other_hash_123456789012345678901234567890")[:32]
+
+	proposal := createTestSignBytes(blockHash, stepPropose)
+	prevote := createTestSignBytes(blockHash, stepPrevote)
+	otherPrevote := createTestSignBytes(otherHash, stepPrevote)
+
+	same, err := SameSignedValue(stepPropose, proposal, stepPrevote, prevote)
+	require.NoError(t, err)
+	require.True(t, same)
+
+	same, err = SameSignedValue(stepPrevote, prevote, stepPrevote, otherPrevote)
+	require.NoError(t, err)
+	require.False(t, same)
+
+	_, err = SameSignedValue(stepPropose, proposal, stepPrevote, []byte{0xFF})
+	var extractionErr *BlockHashExtractionError
+	require.ErrorAs(t, err, &extractionErr)
+}
+
+func TestConsensusLockDecisionLogging(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234567890")[:32]
+
+	var buf bytes.Buffer
+	signState := &SignState{
+		Height: 100,
+		Round:  5,
+		Step:   stepPrecommit,
+		ConsensusLock: ConsensusLock{
+			Height: 100,
+			Round:  5,
+			Value:  lockedValue,
+		},
+	}
+	signState.Logger = cometlog.NewTMLogger(cometlog.NewSyncWriter(&buf))
+	signState.LogLevel = LockLogAllowsAndDenies
+
+	voteSignBytes := func(hash []byte) []byte {
+		signBytes, err := protoio.MarshalDelimited(&cometproto.CanonicalVote{
+			Type:    cometproto.PrevoteType,
+			Height:  100,
+			Round:   6,
+			ChainID: "horcrux-test",
+			BlockID: &cometproto.CanonicalBlockID{Hash: hash},
+		})
+		require.NoError(t, err)
+		return signBytes
+	}
+
+	hrs := HRSKey{Height: 100, Round: 6, Step: stepPrevote}
+
+	require.NoError(t, signState.ValidateConsensusLock(hrs, voteSignBytes(lockedValue), -1))
+	logged := buf.String()
+	require.Contains(t, logged, "decision=allow")
+	require.Contains(t, logged, "signed_chain_id=horcrux-test")
+	require.Contains(t, logged, "signed_height=100")
+	require.Contains(t, logged, "signed_round=6")
+	require.Contains(t, logged, fmt.Sprintf("value=%X", lockedValue))
+
+	buf.Reset()
+	require.Error(t, signState.ValidateConsensusLock(hrs, voteSignBytes(differentValue), -1))
+	logged = buf.String()
+	require.Contains(t, logged, "decision=deny")
+	require.Contains(t, logged, "signed_chain_id=horcrux-test")
+
+	// Malformed sign bytes log the decode failure instead of the decoded fields
+	buf.Reset()
+	require.Error(t, signState.ValidateConsensusLock(hrs, []byte{0xFF}, -1))
+	logged = buf.String()
+	require.Contains(t, logged, "decode_error=")
+	require.NotContains(t, logged, "signed_chain_id")
+}
+
+func TestConsensusLockLogLevel(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234567890")[:32]
+
+	var buf bytes.Buffer
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 5, Value: lockedValue},
+	}
+	signState.Logger = cometlog.NewTMLogger(cometlog.NewSyncWriter(&buf))
+	hrs := HRSKey{Height: 100, Round: 6, Step: stepPrevote}
+
+	// By default only denials are logged
+	require.NoError(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(lockedValue, stepPrevote), -1))
+	require.Empty(t, buf.String())
+	require.Error(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(differentValue, stepPrevote), -1))
+	require.Contains(t, buf.String(), "decision=deny")
+
+	// Allows are logged with the allowed value at the verbose level
+	buf.Reset()
+	signState.LogLevel = LockLogAllowsAndDenies
+	require.NoError(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(lockedValue, stepPrevote), -1))
+	logged := buf.String()
+	require.Contains(t, logged, "decision=allow")
+	require.Contains(t, logged, fmt.Sprintf("value=%X", lockedValue))
+	require.Contains(t, logged, "height=100")
+	require.Contains(t, logged, "round=6")
+}
+
+func TestConsensusLockMaxSignBytesLen(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	signState := &SignState{
+		Height: 100,
+		Round:  5,
+		Step:   stepPrecommit,
+		ConsensusLock: ConsensusLock{
+			Height: 100,
+			Round:  5,
+			Value:  lockedValue,
+		},
+	}
+
+	hrs := HRSKey{Height: 100, Round: 6, Step: stepPrevote}
+	signBytes := createTestSignBytes(lockedValue, stepPrevote)
+
+	// Just under (at) the cap
+	signState.MaxSignBytesLen = len(signBytes)
+	require.NoError(t, signState.ValidateConsensusLock(hrs, signBytes, -1))
+
+	// Just over the cap
+	signState.MaxSignBytesLen = len(signBytes) - 1
+	err := signState.ValidateConsensusLock(hrs, signBytes, -1)
+	var oversizedErr *OversizedSignBytesError
+	require.ErrorAs(t, err, &oversizedErr)
+	require.Equal(t, len(signBytes), oversizedErr.Len)
+
+	// The default cap applies when unset, even without a lock
+	signState.MaxSignBytesLen = 0
+	signState.ConsensusLock = ConsensusLock{}
+	require.NoError(t, signState.ValidateConsensusLock(hrs, make([]byte, DefaultMaxSignBytesLen), -1))
+	err = signState.ValidateConsensusLock(hrs, make([]byte, DefaultMaxSignBytesLen+1), -1)
+	require.ErrorAs(t, err, &oversizedErr)
+}
+
+func TestConsensusLockIncludeExtensionInValue(t *testing.T) {
+	blockHash := []byte("extension_block_hash_1234567890123456789012")[:32]
+	precommit := createTestSignBytes(blockHash, stepPrecommit)
+	precommitHRS := HRSKey{Height: 100, Round: 5, Step: stepPrecommit}
+	ext1 := []byte("extension-1")
+	ext2 := []byte("extension-2")
+
+	// By default the extension is ignored
+	opts := ConsensusLockOptions{}
+	require.Equal(t, opts.lockValue(blockHash, ext1), opts.lockValue(blockHash, ext2))
+	require.Equal(t, blockHash, opts.lockValue(blockHash, ext1))
+
+	// With IncludeExtensionInValue, differing extensions are different values
+	opts.IncludeExtensionInValue = true
+	require.NotEqual(t, opts.lockValue(blockHash, ext1), opts.lockValue(blockHash, ext2))
+	require.Equal(t, opts.lockValue(blockHash, ext1), opts.lockValue(blockHash, ext1))
+
+	for _, tc := range []struct {
+		name        string
+		includeExt  bool
+		expectError bool
+	}{
+		{name: "block hash only", includeExt: false, expectError: false},
+		{name: "block hash and extension", includeExt: true, expectError: true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			signState := &SignState{
+				ConsensusLockOptions: ConsensusLockOptions{IncludeExtensionInValue: tc.includeExt},
+			}
+			signState.ConsensusLock = signState.nextConsensusLock(
+				signState.ConsensusLock, precommitHRS, precommit, ext1)
+			require.True(t, signState.ConsensusLock.IsLocked())
+
+			err := signState.ValidateSignRequest(SignRequest{
+				HRS:           HRSKey{Height: 100, Round: 6, Step: stepPrevote},
+				SignBytes:     createTestSignBytes(blockHash, stepPrevote),
+				PolRound:      -1,
+				VoteExtension: ext2,
+			})
+			if !tc.expectError {
+				require.NoError(t, err)
+				return
+			}
+			var violationErr *ConsensusLockViolationError
+			require.ErrorAs(t, err, &violationErr)
+		})
+	}
+}
+
+func TestRequireUnlocked(t *testing.T) {
+	signState := &SignState{Height: 100, Round: 5, Step: stepPrecommit}
+	require.NoError(t, signState.RequireUnlocked())
+
+	signState.ConsensusLock = ConsensusLock{
+		Height: 100,
+		Round:  5,
+		Value:  []byte("locked_block_hash_123456789012345678901234567890")[:32],
+	}
+	err := signState.RequireUnlocked()
+	var activeErr *ConsensusLockActiveError
+	require.ErrorAs(t, err, &activeErr)
+	require.Equal(t, HRSKey{Height: 100, Round: 5, Step: stepPrecommit}, activeErr.HRS)
+	require.Contains(t, err.Error(), "100:5:3")
+}
+
+func TestConsensusLockSignatures(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	signState, err := LoadOrCreateSignState(filepath.Join(t.TempDir(), "sign_state.json"))
+	require.NoError(t, err)
+	signState.ConsensusLock = ConsensusLock{Height: 100, Round: 5, Value: lockedValue}
+
+	prevoteHRS := HRSKey{Height: 100, Round: 6, Step: stepPrevote}
+	precommitHRS := HRSKey{Height: 100, Round: 6, Step: stepPrecommit}
+	save := func(hrs HRSKey, signature []byte) error {
+		return signState.Save(SignStateConsensus{
+			Height:    hrs.Height,
+			Round:     hrs.Round,
+			Step:      hrs.Step,
+			Signature: signature,
+			SignBytes: createTestSignBytes(lockedValue, hrs.Step),
+		}, nil)
+	}
+
+	// Validating a request records nothing, as nothing was signed yet
+	require.NoError(t, signState.ValidateConsensusLock(prevoteHRS, createTestSignBytes(lockedValue, stepPrevote), -1))
+	_, ok := signState.LockSignature(prevoteHRS)
+	require.False(t, ok)
+
+	// Saved signatures are recorded per HRS
+	require.NoError(t, save(prevoteHRS, []byte("prevote_signature")))
+	require.NoError(t, save(precommitHRS, []byte("precommit_signature")))
+
+	stored, ok := signState.LockSignature(prevoteHRS)
+	require.True(t, ok)
+	require.Equal(t, []byte("prevote_signature"), stored)
+	stored, ok = signState.LockSignature(precommitHRS)
+	require.True(t, ok)
+	require.Equal(t, []byte("precommit_signature"), stored)
+
+	// Refused saves record nothing
+	require.Error(t, save(prevoteHRS, []byte("regressed_signature")))
+	stored, ok = signState.LockSignature(prevoteHRS)
+	require.True(t, ok)
+	require.Equal(t, []byte("prevote_signature"), stored)
+
+	// Signatures for old heights are forgotten
+	newHRS := HRSKey{Height: 100 + blocksToCache + 1, Round: 0, Step: stepPrevote}
+	require.NoError(t, save(newHRS, []byte("new_signature")))
+	_, ok = signState.LockSignature(prevoteHRS)
+	require.False(t, ok)
+	_, ok = signState.LockSignature(newHRS)
+	require.True(t, ok)
+}
+
+func TestPreferredValue(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	otherValue := []byte("other_block_hash_1234567890123456789012345678")[:32]
+	anotherValue := []byte("another_block_hash_12345678901234567890123456")[:32]
+
+	signState := &SignState{}
+	_, ok := signState.PreferredValue([][]byte{lockedValue, otherValue})
+	require.False(t, ok, "no preference without a lock")
+
+	signState.ConsensusLock = ConsensusLock{Height: 100, Round: 5, Value: lockedValue}
+
+	value, ok := signState.PreferredValue([][]byte{otherValue, lockedValue, anotherValue})
+	require.True(t, ok)
+	require.Equal(t, lockedValue, value)
+
+	_, ok = signState.PreferredValue([][]byte{otherValue, anotherValue})
+	require.False(t, ok)
+
+	_, ok = signState.PreferredValue(nil)
+	require.False(t, ok)
+}
+
+func TestSuspiciousDecode(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 5, Value: lockedValue},
+	}
+
+	negativeHeight, err := protoio.MarshalDelimited(&cometproto.CanonicalVote{
+		Type:    cometproto.PrevoteType,
+		Height:  -100,
+		Round:   5,
+		BlockID: &cometproto.CanonicalBlockID{Hash: lockedValue},
+	})
+	require.NoError(t, err)
+
+	shortHash, err := protoio.MarshalDelimited(&cometproto.CanonicalVote{
+		Type:    cometproto.PrevoteType,
+		Height:  100,
+		Round:   5,
+		BlockID: &cometproto.CanonicalBlockID{Hash: lockedValue[:7]},
+	})
+	require.NoError(t, err)
+
+	var suspiciousErr *SuspiciousDecodeError
+
+	_, err = extractBlockHashFromSignBytes(negativeHeight, stepPrevote)
+	require.ErrorAs(t, err, &suspiciousErr)
+	require.Equal(t, "height", suspiciousErr.Field)
+
+	_, err = extractBlockHashFromSignBytes(shortHash, stepPrevote)
+	require.ErrorAs(t, err, &suspiciousErr)
+	require.Equal(t, "block_id.hash", suspiciousErr.Field)
+
+	// A suspicious decode is never treated as the locked value
+	err = signState.ValidateConsensusLock(HRSKey{Height: 100, Round: 6, Step: stepPrevote}, shortHash, -1)
+	require.ErrorAs(t, err, &suspiciousErr)
+
+	// Nor does it move the lock
+	lock := nextConsensusLock(
+		signState.ConsensusLock, HRSKey{Height: 100, Round: 6, Step: stepPrecommit}, shortHash)
+	require.Equal(t, signState.ConsensusLock, lock)
+}
+
+func TestFailClosedOnMissingState(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	prevoteHRS := HRSKey{Height: 100, Round: 0, Step: stepPrevote}
+	prevote := createTestSignBytes(blockHash, stepPrevote)
+
+	// Without the flag an uninitialized state allows signing
+	signState := &SignState{}
+	require.NoError(t, signState.ValidateConsensusLock(prevoteHRS, prevote, -1))
+
+	// With the flag it blocks votes
+	signState.FailClosedOnMissingState = true
+	err := signState.ValidateConsensusLock(prevoteHRS, prevote, -1)
+	var uninitializedErr *UninitializedSignStateError
+	require.ErrorAs(t, err, &uninitializedErr)
+	require.Equal(t, prevoteHRS, uninitializedErr.HRS)
+
+	precommitHRS := HRSKey{Height: 100, Round: 0, Step: stepPrecommit}
+	err = signState.ValidateConsensusLock(precommitHRS, createTestSignBytes(blockHash, stepPrecommit), -1)
+	require.ErrorAs(t, err, &uninitializedErr)
+
+	// Once state exists, signing is allowed again
+	signState.Height, signState.Round, signState.Step = 99, 0, stepPrecommit
+	require.NoError(t, signState.ValidateConsensusLock(prevoteHRS, prevote, -1))
+}
+
+func TestEvaluate(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	var reported []error
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 5, Value: lockedValue},
+		ConsensusLockOptions: ConsensusLockOptions{
+			OnViolation: func(err error, _ HRSKey, _ []byte) {
+				reported = append(reported, err)
+			},
+		},
+	}
+	violationsBefore := testutil.ToFloat64(consensusLockViolations)
+
+	// A precommit for a new value in a later round is allowed and moves the lock
+	decision, err := signState.Evaluate(
+		HRSKey{Height: 100, Round: 6, Step: stepPrecommit}, createTestSignBytes(differentValue, stepPrecommit))
+	require.NoError(t, err)
+	require.True(t, decision.Allowed)
+	require.True(t, decision.AdvancesLock)
+	require.Equal(t, "consistent with consensus lock", decision.Reason)
+
+	// A precommit for the locked value in the lock round leaves the lock in place
+	decision, err = signState.Evaluate(
+		HRSKey{Height: 100, Round: 5, Step: stepPrecommit}, createTestSignBytes(lockedValue, stepPrecommit))
+	require.NoError(t, err)
+	require.True(t, decision.Allowed)
+	require.False(t, decision.AdvancesLock)
+
+	// A prevote for a different value is blocked
+	decision, err = signState.Evaluate(
+		HRSKey{Height: 100, Round: 6, Step: stepPrevote}, createTestSignBytes(differentValue, stepPrevote))
+	require.True(t, IsConsensusLockViolationError(err))
+	require.False(t, decision.Allowed)
+	require.False(t, decision.AdvancesLock)
+	require.Equal(t, err.Error(), decision.Reason)
+
+	// Nothing is reported or recorded
+	require.Empty(t, reported)
+	require.Equal(t, violationsBefore, testutil.ToFloat64(consensusLockViolations))
+	require.Zero(t, signState.ApprovalsAtHeight(100))
+
+	// Shadow mode does not change the decision
+	signState.ShadowMode = true
+	decision, err = signState.Evaluate(
+		HRSKey{Height: 100, Round: 6, Step: stepPrevote}, createTestSignBytes(differentValue, stepPrevote))
+	require.True(t, IsConsensusLockViolationError(err))
+	require.False(t, decision.Allowed)
+}
+
+func TestConsensusLockSetBy(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+	signState := &SignState{}
+
+	lockA := HRSKey{Height: 100, Round: 5, Step: stepPrecommit}
+	lock, err := signState.AdvanceConsensusLock(lockA, createTestSignBytes(blockA, stepPrecommit))
+	require.NoError(t, err)
+	require.True(t, lock.IsLocked())
+	require.Equal(t, lockA, lock.SetBy)
+
+	// Prevotes and repeated precommits for the locked value leave SetBy untouched
+	lock, err = signState.AdvanceConsensusLock(
+		HRSKey{Height: 100, Round: 6, Step: stepPrevote}, createTestSignBytes(blockA, stepPrevote))
+	require.NoError(t, err)
+	require.Equal(t, lockA, lock.SetBy)
+	lock, err = signState.AdvanceConsensusLock(
+		HRSKey{Height: 100, Round: 6, Step: stepPrecommit}, createTestSignBytes(blockA, stepPrecommit))
+	require.NoError(t, err)
+	require.Equal(t, lockA, lock.SetBy)
+
+	// Moving the lock records the precommit that moved it
+	lockB := HRSKey{Height: 100, Round: 7, Step: stepPrecommit}
+	lock, err = signState.AdvanceConsensusLock(lockB, createTestSignBytes(blockB, stepPrecommit))
+	require.NoError(t, err)
+	require.Equal(t, blockB, lock.Value)
+	require.Equal(t, lockB, lock.SetBy)
+
+	// SetBy is serialized with the lock
+	bz, err := lock.MarshalJSON()
+	require.NoError(t, err)
+	var decoded ConsensusLock
+	require.NoError(t, decoded.UnmarshalJSON(bz))
+	require.Equal(t, lockB, decoded.SetBy)
+}
+
+func TestBlockedSteps(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+
+	signState := &SignState{}
+	require.Nil(t, signState.BlockedSteps(differentValue, 5), "nothing is blocked without a lock")
+
+	signState.ConsensusLock = ConsensusLock{Height: 100, Round: 2, Value: lockedValue}
+
+	require.Equal(t, []HRSKey{
+		{Height: 100, Round: 2, Step: stepPropose},
+		{Height: 100, Round: 2, Step: stepPrevote},
+		{Height: 100, Round: 3, Step: stepPropose},
+		{Height: 100, Round: 3, Step: stepPrevote},
+	}, signState.BlockedSteps(differentValue, 3))
+	require.Empty(t, signState.BlockedSteps(lockedValue, 3))
+
+	// Each reported HRS is indeed rejected, and the others are allowed
+	blocked := signState.BlockedSteps(differentValue, 3)
+	for round := int64(0); round <= 3; round++ {
+		for _, step := range []int8{stepPropose, stepPrevote} {
+			hrs := HRSKey{Height: 100, Round: round, Step: step}
+			err := signState.ValidateConsensusLock(hrs, createTestSignBytes(differentValue, step), -1)
+			require.Equal(t, slices.Contains(blocked, hrs), err != nil, "%v: %v", hrs, err)
+		}
+	}
+
+	// Earlier-round proposals are blocked for any value when configured
+	signState.BlockEarlierRoundPropose = true
+	require.Equal(t, []HRSKey{
+		{Height: 100, Round: 0, Step: stepPropose},
+		{Height: 100, Round: 1, Step: stepPropose},
+	}, signState.BlockedSteps(lockedValue, 2))
+}
+
+func TestConstrainedHRS(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+
+	signState := &SignState{}
+	require.Nil(t, signState.ConstrainedHRS(8), "nothing is constrained without a lock")
+
+	signState.ConsensusLock = ConsensusLock{Height: 100, Round: 5, Value: lockedValue}
+	require.Equal(t, []HRSKey{
+		{Height: 100, Round: 5, Step: stepPropose},
+		{Height: 100, Round: 5, Step: stepPrevote},
+		{Height: 100, Round: 6, Step: stepPropose},
+		{Height: 100, Round: 6, Step: stepPrevote},
+		{Height: 100, Round: 7, Step: stepPropose},
+		{Height: 100, Round: 7, Step: stepPrevote},
+		{Height: 100, Round: 8, Step: stepPropose},
+		{Height: 100, Round: 8, Step: stepPrevote},
+	}, signState.ConstrainedHRS(8))
+
+	// Nothing is constrained below the lock round
+	require.Empty(t, signState.ConstrainedHRS(4))
+}
+
+func TestConsensusLockOnViolation(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+
+	type violation struct {
+		err       error
+		hrs       HRSKey
+		attempted []byte
+	}
+	var violations []violation
+
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 5, Value: lockedValue},
+		ConsensusLockOptions: ConsensusLockOptions{
+			OnViolation: func(err error, hrs HRSKey, attempted []byte) {
+				violations = append(violations, violation{err: err, hrs: hrs, attempted: attempted})
+			},
+		},
+	}
+
+	// Allowed requests are not reported
+	hrs := HRSKey{Height: 100, Round: 6, Step: stepPrevote}
+	require.NoError(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(lockedValue, stepPrevote), -1))
+	require.Empty(t, violations)
+
+	// Lock violations are reported once
+	attempted := createTestSignBytes(differentValue, stepPrevote)
+	err := signState.ValidateConsensusLock(hrs, attempted, -1)
+	require.True(t, IsConsensusLockViolationError(err))
+	require.Len(t, violations, 1)
+	require.Equal(t, err, violations[0].err)
+	require.Equal(t, hrs, violations[0].hrs)
+	require.Equal(t, attempted, violations[0].attempted)
+
+	// Other rejections are not reported
+	signState.MaxSignBytesLen = 1
+	require.Error(t, signState.ValidateConsensusLock(hrs, attempted, -1))
+	require.Len(t, violations, 1)
+	signState.MaxSignBytesLen = 0
+
+	// Double signs are reported once
+	signState.ConsensusLock = ConsensusLock{}
+	signState.Height, signState.Round, signState.Step = 100, 6, stepPrevote
+	signState.SignBytes = createTestSignBytes(lockedValue, stepPrevote)
+	signState.Signature = []byte("signature")
+	_, err = signState.existingSignatureOrErrorIfRegression(
+		HRSTKey{Height: 100, Round: 6, Step: stepPrevote}, attempted)
+	require.True(t, IsDoubleSignError(err))
+	require.Len(t, violations, 2)
+	require.Equal(t, err, violations[1].err)
+}
+
+func TestConsensusLockPrecommitReleaseRound(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	lock := ConsensusLock{Height: 100, Round: 5, Value: lockedValue}
+
+	sameRound := HRSKey{Height: 100, Round: 5, Step: stepPrecommit}
+	laterRound := HRSKey{Height: 100, Round: 6, Step: stepPrecommit}
+	differentPrecommit := createTestSignBytes(differentValue, stepPrecommit)
+
+	// Same round, same value: allowed, lock unchanged
+	signState := &SignState{ConsensusLock: lock}
+	require.NoError(t, signState.ValidateConsensusLock(sameRound, createTestSignBytes(lockedValue, stepPrecommit), -2))
+
+	// Same round, different value: equivocation, never a release
+	err := signState.ValidateConsensusLock(sameRound, differentPrecommit, -2)
+	require.True(t, IsConsensusLockViolationError(err))
+	next, err := signState.AdvanceConsensusLock(sameRound, differentPrecommit)
+	require.True(t, IsConsensusLockViolationError(err))
+	require.Equal(t, lock, next)
+	require.Equal(t, lock, signState.ConsensusLock)
+
+	// Later round, different value: releases the lock and locks on the new value
+	require.NoError(t, signState.ValidateConsensusLock(laterRound, differentPrecommit, -2))
+	next, err = signState.AdvanceConsensusLock(laterRound, differentPrecommit)
+	require.NoError(t, err)
+	require.Equal(t, int64(6), next.Round)
+	require.Equal(t, differentValue, next.Value)
+}
+
+func TestConsensusLockTimestampSkew(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	hrs := HRSKey{Height: 100, Round: 0, Step: stepPrevote}
+
+	prevoteAt := func(timestamp time.Time) []byte {
+		signBytes, err := protoio.MarshalDelimited(&cometproto.CanonicalVote{
+			Type:      cometproto.PrevoteType,
+			Height:    100,
+			Round:     0,
+			BlockID:   &cometproto.CanonicalBlockID{Hash: blockHash},
+			Timestamp: timestamp,
+		})
+		require.NoError(t, err)
+		return signBytes
+	}
+
+	// Disabled by default
+	signState := &SignState{ConsensusLockOptions: ConsensusLockOptions{Now: func() time.Time { return now }}}
+	require.NoError(t, signState.ValidateConsensusLock(hrs, prevoteAt(now.Add(-time.Hour)), -1))
+
+	signState.MaxTimestampSkew = 5 * time.Second
+
+	// Within tolerance, in either direction
+	require.NoError(t, signState.ValidateConsensusLock(hrs, prevoteAt(now.Add(-4*time.Second)), -1))
+	require.NoError(t, signState.ValidateConsensusLock(hrs, prevoteAt(now.Add(5*time.Second)), -1))
+
+	// Out of tolerance
+	skewed := now.Add(-time.Hour)
+	err := signState.ValidateConsensusLock(hrs, prevoteAt(skewed), -1)
+	var skewErr *TimestampSkewError
+	require.ErrorAs(t, err, &skewErr)
+	require.True(t, skewed.Equal(skewErr.Timestamp))
+	require.Equal(t, 5*time.Second, skewErr.Tolerance)
+
+	err = signState.ValidateConsensusLock(hrs, prevoteAt(now.Add(6*time.Second)), -1)
+	require.ErrorAs(t, err, &skewErr)
+}
+
+func TestConsensusLockMonotonicTimestamps(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	voteAt := func(height, round int64, step int8, timestamp time.Time) (HRSKey, []byte) {
+		signBytes, err := protoio.MarshalDelimited(&cometproto.CanonicalVote{
+			Type:      StepToType(step),
+			Height:    height,
+			Round:     round,
+			BlockID:   &cometproto.CanonicalBlockID{Hash: blockHash},
+			Timestamp: timestamp,
+		})
+		require.NoError(t, err)
+		return HRSKey{Height: height, Round: round, Step: step}, signBytes
+	}
+
+	// In order, including equal timestamps
+	signState := &SignState{ConsensusLockOptions: ConsensusLockOptions{MonotonicTimestamps: true}}
+	for i, step := range []int8{stepPrevote, stepPrecommit, stepPrevote} {
+		hrs, signBytes := voteAt(100, int64(i/2), step, start.Add(time.Duration(i/2)*time.Second))
+		require.NoError(t, signState.ValidateConsensusLock(hrs, signBytes, -1))
+	}
+
+	// Out of order within the height
+	hrs, signBytes := voteAt(100, 1, stepPrecommit, start)
+	err := signState.ValidateConsensusLock(hrs, signBytes, -1)
+	var regressionErr *TimestampRegressionError
+	require.ErrorAs(t, err, &regressionErr)
+	require.True(t, start.Equal(regressionErr.Timestamp))
+	require.True(t, start.Add(time.Second).Equal(regressionErr.Last))
+
+	// A new height starts over
+	hrs, signBytes = voteAt(101, 0, stepPrevote, start.Add(-time.Hour))
+	require.NoError(t, signState.ValidateConsensusLock(hrs, signBytes, -1))
+
+	// Disabled by default
+	signState = &SignState{}
+	for _, timestamp := range []time.Time{start.Add(time.Second), start} {
+		hrs, signBytes := voteAt(100, 0, stepPrevote, timestamp)
+		require.NoError(t, signState.ValidateConsensusLock(hrs, signBytes, -1))
+	}
+}
+
+func TestApprovalsAtHeight(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: lockedValue},
+	}
+
+	approve := func(height, round int64, step int8, value []byte) error {
+		return signState.ValidateConsensusLock(
+			HRSKey{Height: height, Round: round, Step: step}, createTestSignBytes(value, step), -1)
+	}
+
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			errs <- approve(100, 1, stepPrevote, lockedValue)
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		require.NoError(t, <-errs)
+	}
+	require.NoError(t, approve(101, 0, stepPropose, differentValue))
+	require.NoError(t, approve(101, 0, stepPrevote, differentValue))
+
+	// Rejected requests are not counted
+	require.Error(t, approve(100, 1, stepPrevote, differentValue))
+
+	require.Equal(t, 10, signState.ApprovalsAtHeight(100))
+	require.Equal(t, 2, signState.ApprovalsAtHeight(101))
+	require.Zero(t, signState.ApprovalsAtHeight(102))
+
+	// Old heights are pruned
+	require.NoError(t, approve(101+blocksToCache+1, 0, stepPropose, differentValue))
+	require.Zero(t, signState.ApprovalsAtHeight(100))
+	require.Zero(t, signState.ApprovalsAtHeight(101))
+}
+
+func TestPeekNextLock(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	lock := ConsensusLock{Height: 100, Round: 5, Value: lockedValue}
+	signState := &SignState{Height: 100, Round: 5, Step: stepPrecommit, ConsensusLock: lock}
+
+	// A later-round precommit for a different value would move the lock
+	hrs := HRSKey{Height: 100, Round: 6, Step: stepPrecommit}
+	precommit := createTestSignBytes(differentValue, stepPrecommit)
+	next, err := signState.PeekNextLock(hrs, precommit)
+	require.NoError(t, err)
+	require.Equal(t, int64(6), next.Round)
+	require.Equal(t, differentValue, next.Value)
+	require.Equal(t, hrs, next.SetBy)
+
+	// ... but the SignState is unchanged
+	require.Equal(t, lock, signState.ConsensusLock)
+	require.Equal(t, int64(5), signState.Round)
+
+	// Peeking matches advancing
+	advanced, err := signState.AdvanceConsensusLock(hrs, precommit)
+	require.NoError(t, err)
+	require.Equal(t, next.Round, advanced.Round)
+	require.Equal(t, next.Value, advanced.Value)
+
+	// Violations are reported without changes
+	_, err = signState.PeekNextLock(hrs, createTestSignBytes(lockedValue, stepPrecommit))
+	require.True(t, IsConsensusLockViolationError(err))
+	require.Equal(t, advanced, signState.ConsensusLock)
+}
+
+func TestNilSignState(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	hrs := HRSKey{Height: 100, Round: 0, Step: stepPrevote}
+	signBytes := createTestSignBytes(blockHash, stepPrevote)
+
+	var signState *SignState
+	var nilErr *NilSignStateError
+
+	require.NotPanics(t, func() {
+		err := signState.ValidateConsensusLock(hrs, signBytes, -1)
+		require.ErrorAs(t, err, &nilErr)
+		require.Equal(t, hrs, nilErr.HRS)
+
+		decision, err := signState.Evaluate(hrs, signBytes)
+		require.ErrorAs(t, err, &nilErr)
+		require.False(t, decision.Allowed)
+	})
+}
+
+func TestDuplicateSignRequests(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	otherHash := []byte("other_block_hash_123456789012345678901234")[:32]
+	signState := &SignState{}
+	before := testutil.ToFloat64(duplicateSignRequests)
+
+	precommit := HRSKey{Height: 100, Round: 0, Step: stepPrecommit}
+	require.NoError(t, signState.ValidateConsensusLock(precommit, createTestSignBytes(blockHash, stepPrecommit), -1))
+	require.NoError(t, signState.ValidateConsensusLock(precommit, createTestSignBytes(blockHash, stepPrecommit), -1))
+	require.Equal(t, before+1, testutil.ToFloat64(duplicateSignRequests))
+
+	// A different value at the same HRS is not a duplicate
+	require.NoError(t, signState.ValidateConsensusLock(precommit, createTestSignBytes(otherHash, stepPrecommit), -1))
+	require.Equal(t, before+1, testutil.ToFloat64(duplicateSignRequests))
+}
+
+// appIDExtractor extracts a fixed application value regardless of the sign bytes.
+type appIDExtractor struct {
+	value []byte
+}
+
+func (e appIDExtractor) Extract(_ int8, _ []byte) ([]byte, ValueType, error) {
+	return e.value, "app", nil
+}
+
+func TestCustomValueExtractor(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+	appValue := []byte("application_value")
+
+	precommit := HRSKey{Height: 100, Round: 0, Step: stepPrecommit}
+	prevote := HRSKey{Height: 100, Round: 1, Step: stepPrevote}
+
+	// The default extractor locks on the block hash
+	signState := &SignState{}
+	lock, err := signState.AdvanceConsensusLock(precommit, createTestSignBytes(blockA, stepPrecommit))
+	require.NoError(t, err)
+	require.Equal(t, blockA, lock.Value)
+	err = signState.ValidateConsensusLock(prevote, createTestSignBytes(blockB, stepPrevote), -1)
+	var violationErr *ConsensusLockViolationError
+	require.ErrorAs(t, err, &violationErr)
+
+	// A custom extractor locks on its own value, so both blocks carry the same value
+	signState = &SignState{ConsensusLockOptions: ConsensusLockOptions{
+		ValueExtractor:         appIDExtractor{value: appValue},
+		AllowUnknownValueTypes: true,
+	}}
+	lock, err = signState.AdvanceConsensusLock(precommit, createTestSignBytes(blockA, stepPrecommit))
+	require.NoError(t, err)
+	require.Equal(t, appValue, lock.Value)
+	require.NoError(t, signState.ValidateConsensusLock(prevote, createTestSignBytes(blockB, stepPrevote), -1))
+}
+
+func TestStepValueExtractors(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	var prevoteCalls, precommitCalls, defaultCalls int
+	signState := &SignState{ConsensusLockOptions: ConsensusLockOptions{
+		ValueExtractor: countingExtractor{calls: &defaultCalls},
+		StepValueExtractors: map[int8]ValueExtractor{
+			stepPrevote:   countingExtractor{calls: &prevoteCalls},
+			stepPrecommit: countingExtractor{calls: &precommitCalls},
+		},
+	}}
+
+	// The precommit extractor sets the lock
+	lock, err := signState.AdvanceConsensusLock(
+		HRSKey{Height: 100, Round: 0, Step: stepPrecommit}, createTestSignBytes(blockHash, stepPrecommit))
+	require.NoError(t, err)
+	require.Equal(t, blockHash, lock.Value)
+	require.Positive(t, precommitCalls)
+	require.Zero(t, prevoteCalls)
+
+	// The prevote extractor checks prevotes against it
+	precommitsBefore := precommitCalls
+	require.NoError(t, signState.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 1, Step: stepPrevote}, createTestSignBytes(blockHash, stepPrevote), -1))
+	require.Positive(t, prevoteCalls)
+	require.Equal(t, precommitsBefore, precommitCalls)
+
+	// Steps without a registered extractor use ValueExtractor
+	require.Zero(t, defaultCalls)
+	require.NoError(t, signState.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 2, Step: stepPropose}, createTestSignBytes(blockHash, stepPropose), -1))
+	require.Positive(t, defaultCalls)
+}
+
+func TestBlockHashExtractor(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+
+	value, valueType, err := BlockHashExtractor{}.Extract(stepPrevote, createTestSignBytes(blockHash, stepPrevote))
+	require.NoError(t, err)
+	require.Equal(t, blockHash, value)
+	require.Equal(t, ValueTypeBlock, valueType)
+}
+
+// countingExtractor counts how often sign bytes are decoded.
+type countingExtractor struct {
+	calls *int
+}
+
+func (e countingExtractor) Extract(step int8, signBytes []byte) ([]byte, ValueType, error) {
+	*e.calls++
+	return BlockHashExtractor{}.Extract(step, signBytes)
+}
+
+func TestValidateConsensusLockUnlockedSkipsDecode(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	var calls int
+	signState := &SignState{ConsensusLockOptions: ConsensusLockOptions{
+		ValueExtractor: countingExtractor{calls: &calls},
+	}}
+
+	for _, step := range []int8{stepPropose, stepPrevote, stepPrecommit} {
+		hrs := HRSKey{Height: 100, Round: 0, Step: step}
+		require.NoError(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(blockHash, step), -1))
+	}
+	require.Zero(t, calls)
+
+	// Once locked, the value must be decoded
+	signState.ConsensusLock = ConsensusLock{Height: 100, Round: 0, Value: blockHash}
+	hrs := HRSKey{Height: 100, Round: 1, Step: stepPrevote}
+	require.NoError(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(blockHash, stepPrevote), -1))
+	require.Equal(t, 1, calls)
+}
+
+func BenchmarkValidateConsensusLockUnlocked(b *testing.B) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	var calls int
+	signState := &SignState{ConsensusLockOptions: ConsensusLockOptions{
+		ValueExtractor: countingExtractor{calls: &calls},
+	}}
+	hrs := HRSKey{Height: 100, Round: 0, Step: stepPrevote}
+	signBytes := createTestSignBytes(blockHash, stepPrevote)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := signState.ValidateConsensusLock(hrs, signBytes, -1); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	if calls != 0 {
+		b.Fatalf("decoded sign bytes %d times while unlocked", calls)
+	}
+}
+
+func TestDisableLock(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 1, Value: lockedValue},
+		cache:         make(map[HRSKey]SignStateConsensus),
+	}
+	require.False(t, signState.DisableLock)
+
+	prevote := HRSKey{Height: 100, Round: 2, Step: stepPrevote}
+	signBytes := createTestSignBytes(differentValue, stepPrevote)
+	var violationErr *ConsensusLockViolationError
+	require.ErrorAs(t, signState.ValidateConsensusLock(prevote, signBytes, -1), &violationErr)
+
+	// With the lock disabled the would-be violation is allowed
+	signState.DisableLock = true
+	require.NoError(t, signState.ValidateConsensusLock(prevote, signBytes, -1))
+
+	// HRS monotonicity still applies
+	_, _, err := signState.blockDoubleSign(SignStateConsensus{
+		Height: 100, Round: 2, Step: stepPrevote, SignBytes: signBytes,
+	})
+	require.NoError(t, err)
+	var roundErr *RoundRegressionError
+	require.ErrorAs(t, signState.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 1, Step: stepPrevote}, signBytes, -1), &roundErr)
+
+	signState.DisableLock = false
+	require.ErrorAs(t, signState.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 3, Step: stepPrevote}, signBytes, -1), &violationErr)
+}
+
+// recordingSpan records the attributes of a span and whether it ended.
+type recordingSpan struct {
+	name  string
+	attrs map[string]interface{}
+	ended bool
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...SpanAttribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordingSpan) End() {
+	s.ended = true
+}
+
+// recordingTracer records every span it starts.
+type recordingTracer struct {
+	spans []*recordingSpan
+}
+
+func (t *recordingTracer) StartSpan(name string) Span {
+	span := &recordingSpan{name: name, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+func TestValidateConsensusLockTracing(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	tracer := &recordingTracer{}
+	signState := &SignState{
+		ConsensusLock:        ConsensusLock{Height: 100, Round: 0, Value: lockedValue},
+		ConsensusLockOptions: ConsensusLockOptions{Tracer: tracer},
+	}
+
+	hrs := HRSKey{Height: 100, Round: 1, Step: stepPrevote}
+	require.NoError(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(lockedValue, stepPrevote), -1))
+	require.Error(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(differentValue, stepPrevote), -1))
+
+	require.Len(t, tracer.spans, 2)
+	for i, decision := range []string{"allow", "deny"} {
+		span := tracer.spans[i]
+		require.Equal(t, "horcrux.ValidateConsensusLock", span.name)
+		require.True(t, span.ended)
+		require.Equal(t, map[string]interface{}{
+			"height":   int64(100),
+			"round":    int64(1),
+			"step":     stepPrevote,
+			"decision": decision,
+		}, span.attrs)
+	}
+}
+
+func TestMalformedValueNeverLocked(t *testing.T) {
+	shortHash := []byte("short_hash")
+	hrs := HRSKey{Height: 100, Round: 5, Step: stepPrecommit}
+	signBytes := createTestSignBytes(shortHash, stepPrecommit)
+
+	signState := &SignState{}
+	lock, err := signState.AdvanceConsensusLock(hrs, signBytes)
+	var extractionErr *BlockHashExtractionError
+	require.ErrorAs(t, err, &extractionErr)
+	var suspiciousErr *SuspiciousDecodeError
+	require.ErrorAs(t, err, &suspiciousErr)
+	require.False(t, lock.IsLocked())
+
+	_, err = signState.PeekNextLock(hrs, signBytes)
+	require.ErrorAs(t, err, &extractionErr)
+
+	// Signing the precommit does not set the lock either
+	signState.cache = make(map[HRSKey]SignStateConsensus)
+	_, _, err = signState.blockDoubleSign(SignStateConsensus{
+		Height: hrs.Height, Round: hrs.Round, Step: hrs.Step, SignBytes: signBytes,
+	})
+	require.NoError(t, err)
+	require.False(t, signState.ConsensusLock.IsLocked())
+
+	// Nor does a malformed value from a buggy decoder
+	require.Error(t, ConsensusLockOptions{}.checkLockableValue(stepPrecommit, shortHash))
+}
+
+func TestReleaseSteps(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+	prevoteAt := func(round int64) HRSKey {
+		return HRSKey{Height: 100, Round: round, Step: stepPrevote}
+	}
+
+	// By default prevotes never set the lock
+	signState := &SignState{}
+	lock, err := signState.AdvanceConsensusLock(prevoteAt(0), createTestSignBytes(blockA, stepPrevote))
+	require.NoError(t, err)
+	require.False(t, lock.IsLocked())
+
+	signState = &SignState{ConsensusLockOptions: ConsensusLockOptions{
+		ReleaseSteps: map[int8]bool{stepPrevote: true, stepPrecommit: true},
+	}}
+	lock, err = signState.AdvanceConsensusLock(prevoteAt(0), createTestSignBytes(blockA, stepPrevote))
+	require.NoError(t, err)
+	require.Equal(t, blockA, lock.Value)
+	require.Equal(t, int64(0), lock.Round)
+
+	// A prevote in a later round moves the lock, like a precommit would
+	require.NoError(t, signState.ValidateConsensusLock(prevoteAt(1), createTestSignBytes(blockB, stepPrevote), -1))
+	lock, err = signState.AdvanceConsensusLock(prevoteAt(1), createTestSignBytes(blockB, stepPrevote))
+	require.NoError(t, err)
+	require.Equal(t, blockB, lock.Value)
+	require.Equal(t, int64(1), lock.Round)
+
+	// A different prevote in the lock round is equivocation
+	var violationErr *ConsensusLockViolationError
+	require.ErrorAs(t, signState.ValidateConsensusLock(
+		prevoteAt(1), createTestSignBytes(blockA, stepPrevote), -1), &violationErr)
+
+	// Proposals are still held to the lock
+	require.ErrorAs(t, signState.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 2, Step: stepPropose}, createTestSignBytes(blockA, stepPropose), -1), &violationErr)
+}
+
+func TestLongLockWarning(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+
+	var buf bytes.Buffer
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: lockedValue},
+	}
+	signState.Logger = cometlog.NewTMLogger(cometlog.NewSyncWriter(&buf))
+	signState.LongLockWarnRounds = 10
+
+	blockAt := func(round int64) {
+		hrs := HRSKey{Height: 100, Round: round, Step: stepPrevote}
+		require.Error(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(differentValue, stepPrevote), -1))
+	}
+	const warning = "Consensus lock held for many rounds without release"
+
+	// Under the threshold
+	blockAt(5)
+	blockAt(10)
+	require.NotContains(t, buf.String(), warning)
+
+	// Over the threshold, warned once
+	blockAt(11)
+	blockAt(12)
+	blockAt(20)
+	require.Equal(t, 1, strings.Count(buf.String(), warning))
+	require.Contains(t, buf.String(), "round_gap=11")
+}
+
+func TestConsensusLockValueLength(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	shortValue := []byte("short_block_hash_1234")[:20]
+	signState := &SignState{
+		ConsensusLock:        ConsensusLock{Height: 100, Round: 0, Value: lockedValue},
+		ConsensusLockOptions: ConsensusLockOptions{ValueLength: 32},
+	}
+	hrs := HRSKey{Height: 100, Round: 1, Step: stepPrevote}
+
+	// Correctly sized
+	require.NoError(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(lockedValue, stepPrevote), -1))
+
+	// Incorrectly sized
+	err := signState.ValidateConsensusLock(hrs, createTestSignBytes(shortValue, stepPrevote), -1)
+	var lengthErr *HashLengthError
+	require.ErrorAs(t, err, &lengthErr)
+	require.Equal(t, 20, lengthErr.Length)
+	require.Equal(t, 32, lengthErr.Expected)
+	require.True(t, IsParseError(err))
+
+	// Also when setting the lock
+	_, err = (&SignState{ConsensusLockOptions: ConsensusLockOptions{ValueLength: 32}}).ValidateAndAdvance(
+		HRSKey{Height: 100, Round: 0, Step: stepPrecommit}, createTestSignBytes(shortValue, stepPrecommit))
+	require.ErrorAs(t, err, &lengthErr)
+
+	// Votes for nil have no hash to check
+	require.NoError(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(nil, stepPrevote), -1))
+
+	// A chain with shorter hashes accepts them
+	shortOpts := ConsensusLockOptions{ValueLength: 20}
+	value, err := shortOpts.extractValue(stepPrevote, createTestSignBytes(shortValue, stepPrevote))
+	require.NoError(t, err)
+	require.Equal(t, shortValue, value)
+	_, err = shortOpts.extractValue(stepPrevote, createTestSignBytes(lockedValue, stepPrevote))
+	require.ErrorAs(t, err, &lengthErr)
+
+	// Without ValueLength, anything but a CometBFT hash is suspicious
+	_, err = ConsensusLockOptions{}.extractValue(stepPrevote, createTestSignBytes(shortValue, stepPrevote))
+	var suspiciousErr *SuspiciousDecodeError
+	require.ErrorAs(t, err, &suspiciousErr)
+}
+
+func TestValueType(t *testing.T) {
+	require.True(t, ValueTypeBlock.Valid())
+	require.True(t, ValueTypeNil.Valid())
+	require.False(t, ValueType("blok").Valid())
+	require.False(t, ValueType("").Valid())
+
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	precommit := HRSKey{Height: 100, Round: 0, Step: stepPrecommit}
+	signBytes := createTestSignBytes(blockHash, stepPrecommit)
+	extractor := appIDExtractor{value: []byte("application_value")}
+
+	// Unknown value types are rejected by default
+	signState := &SignState{ConsensusLockOptions: ConsensusLockOptions{ValueExtractor: extractor}}
+	lock, err := signState.AdvanceConsensusLock(precommit, signBytes)
+	var typeErr *UnknownValueTypeError
+	require.ErrorAs(t, err, &typeErr)
+	require.Equal(t, ValueType("app"), typeErr.Type)
+	require.False(t, lock.IsLocked())
+
+	signState.AllowUnknownValueTypes = true
+	lock, err = signState.AdvanceConsensusLock(precommit, signBytes)
+	require.NoError(t, err)
+	require.Equal(t, extractor.value, lock.Value)
+}
+
+func TestCanonicalValue(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	precommit := HRSKey{Height: 100, Round: 0, Step: stepPrecommit}
+	signBytes := createTestSignBytes(blockHash, stepPrecommit)
+
+	for _, includeExtension := range []bool{false, true} {
+		signState := &SignState{ConsensusLockOptions: ConsensusLockOptions{IncludeExtensionInValue: includeExtension}}
+
+		value, err := signState.CanonicalValue(stepPrecommit, signBytes)
+		require.NoError(t, err)
+		require.Len(t, value, 32)
+
+		lock, err := signState.AdvanceConsensusLock(precommit, signBytes)
+		require.NoError(t, err)
+		require.Equal(t, lock.Value, value)
+	}
+
+	_, err := (&SignState{}).CanonicalValue(stepPrecommit, []byte{0xFF})
+	var extractionErr *BlockHashExtractionError
+	require.ErrorAs(t, err, &extractionErr)
+}
+
+func TestEquivalentValues(t *testing.T) {
+	canonical := []byte("canonical_block_hash_123456789012345678901234")[:32]
+	alternate := []byte("alternate_block_hash_123456789012345678901234")[:32]
+	different := []byte("different_block_hash_123456789012345678901234")[:32]
+
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: canonical},
+		ConsensusLockOptions: ConsensusLockOptions{
+			EquivalentValues: [][]byte{canonical, alternate},
+		},
+	}
+
+	prevote := HRSKey{Height: 100, Round: 1, Step: stepPrevote}
+	require.NoError(t, signState.ValidateConsensusLock(prevote, createTestSignBytes(alternate, stepPrevote), -1))
+	require.NoError(t, signState.ValidateConsensusLock(prevote, createTestSignBytes(canonical, stepPrevote), -1))
+
+	var violationErr *ConsensusLockViolationError
+	require.ErrorAs(t, signState.ValidateConsensusLock(
+		prevote, createTestSignBytes(different, stepPrevote), -1), &violationErr)
+
+	// A precommit for an equivalent value in the lock round is not equivocation
+	precommit := HRSKey{Height: 100, Round: 0, Step: stepPrecommit}
+	require.NoError(t, signState.ValidateConsensusLock(precommit, createTestSignBytes(alternate, stepPrecommit), -1))
+	require.ErrorAs(t, signState.ValidateConsensusLock(
+		precommit, createTestSignBytes(different, stepPrecommit), -1), &violationErr)
+}
+
+func TestValidateAndAdvanceConcurrent(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+	valueAt := func(round int64) []byte {
+		if round%2 == 0 {
+			return blockA
+		}
+		return blockB
+	}
+
+	signState := &SignState{}
+	const rounds = 50
+
+	type result struct {
+		round int64
+		lock  ConsensusLock
+		err   error
+	}
+	results := make(chan result, rounds)
+	var wg sync.WaitGroup
+	for round := int64(0); round < rounds; round++ {
+		wg.Add(1)
+		go func(round int64) {
+			defer wg.Done()
+			hrs := HRSKey{Height: 100, Round: round, Step: stepPrecommit}
+			lock, err := signState.ValidateAndAdvance(hrs, createTestSignBytes(valueAt(round), stepPrecommit))
+			results <- result{round: round, lock: lock, err: err}
+		}(round)
+	}
+	wg.Wait()
+	close(results)
+
+	for r := range results {
+		require.NoError(t, r.err)
+		// The lock never goes back below a round that was just applied, and its
+		// value always belongs to its round
+		require.GreaterOrEqual(t, r.lock.Round, r.round)
+		require.Equal(t, valueAt(r.lock.Round), r.lock.Value)
+	}
+	require.Equal(t, int64(rounds-1), signState.ConsensusLock.Round)
+}
+
+func TestValidateAndAdvance(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+	signState := &SignState{}
+
+	// Prevotes are validated but never advance the lock
+	lock, err := signState.ValidateAndAdvance(
+		HRSKey{Height: 100, Round: 0, Step: stepPrevote}, createTestSignBytes(blockA, stepPrevote))
+	require.NoError(t, err)
+	require.False(t, lock.IsLocked())
+
+	lock, err = signState.ValidateAndAdvance(
+		HRSKey{Height: 100, Round: 0, Step: stepPrecommit}, createTestSignBytes(blockA, stepPrecommit))
+	require.NoError(t, err)
+	require.Equal(t, blockA, lock.Value)
+
+	// A rejected precommit leaves the lock unchanged
+	lock, err = signState.ValidateAndAdvance(
+		HRSKey{Height: 100, Round: 0, Step: stepPrecommit}, createTestSignBytes(blockB, stepPrecommit))
+	var violationErr *ConsensusLockViolationError
+	require.ErrorAs(t, err, &violationErr)
+	require.Equal(t, blockA, lock.Value)
+}
+
+func TestConsensusLockZeroValue(t *testing.T) {
+	zeroValue := make([]byte, 32)
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	hrs := HRSKey{Height: 100, Round: 0, Step: stepPrecommit}
+
+	// A precommit for the zero hash is treated like nil and sets no lock
+	signState := &SignState{}
+	lock, err := signState.ValidateAndAdvance(hrs, createTestSignBytes(zeroValue, stepPrecommit))
+	require.NoError(t, err)
+	require.False(t, lock.IsLocked())
+
+	// Nor does it move an existing lock
+	signState = &SignState{ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: blockA}}
+	lock, err = signState.ValidateAndAdvance(
+		HRSKey{Height: 100, Round: 1, Step: stepPrecommit}, createTestSignBytes(zeroValue, stepPrecommit))
+	require.NoError(t, err)
+	require.Equal(t, blockA, lock.Value)
+	require.Equal(t, int64(0), lock.Round)
+
+	// Unless allowed
+	signState = &SignState{ConsensusLockOptions: ConsensusLockOptions{AllowZeroValue: true}}
+	lock, err = signState.ValidateAndAdvance(hrs, createTestSignBytes(zeroValue, stepPrecommit))
+	require.NoError(t, err)
+	require.True(t, lock.IsLocked())
+	require.Equal(t, zeroValue, lock.Value)
+}
+
+func TestDecodeErrorMetric(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: lockedValue},
+	}
+	hrs := HRSKey{Height: 100, Round: 1, Step: stepPrevote}
+
+	decodeErrors := testutil.ToFloat64(signBytesDecodeErrors)
+	violations := testutil.ToFloat64(consensusLockViolations)
+
+	// Malformed sign bytes are a decode error, not a violation
+	err := signState.ValidateConsensusLock(hrs, []byte{0xFF}, -1)
+	var decodeErr *DecodeError
+	require.ErrorAs(t, err, &decodeErr)
+	require.Equal(t, decodeErrors+1, testutil.ToFloat64(signBytesDecodeErrors))
+	require.Equal(t, violations, testutil.ToFloat64(consensusLockViolations))
+
+	// And the other way around
+	require.Error(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(differentValue, stepPrevote), -1))
+	require.Equal(t, decodeErrors+1, testutil.ToFloat64(signBytesDecodeErrors))
+	require.Equal(t, violations+1, testutil.ToFloat64(consensusLockViolations))
+}
+
+func TestLastSignedValue(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+
+	signState := &SignState{}
+	value, ok := signState.LastSignedValue()
+	require.False(t, ok)
+	require.Nil(t, value)
+
+	_, err := signState.AdvanceConsensusLock(
+		HRSKey{Height: 100, Round: 0, Step: stepPrecommit}, createTestSignBytes(blockHash, stepPrecommit))
+	require.NoError(t, err)
+	value, ok = signState.LastSignedValue()
+	require.True(t, ok)
+	require.Equal(t, blockHash, value)
+
+	// The returned value is a copy
+	value[0] ^= 1
+	require.Equal(t, blockHash, signState.ConsensusLock.Value)
+}
+
+// slowExtractor extracts block hashes after a delay.
+type slowExtractor struct {
+	delay time.Duration
+}
+
+func (e slowExtractor) Extract(step int8, signBytes []byte) ([]byte, ValueType, error) {
+	time.Sleep(e.delay)
+	return BlockHashExtractor{}.Extract(step, signBytes)
+}
+
+func TestValidateTimeout(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	newSignState := func(timeout time.Duration) *SignState {
+		return &SignState{
+			ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: lockedValue},
+			ConsensusLockOptions: ConsensusLockOptions{
+				ValueExtractor:  slowExtractor{delay: 200 * time.Millisecond},
+				ValidateTimeout: timeout,
+			},
+		}
+	}
+	hrs := HRSKey{Height: 100, Round: 1, Step: stepPrevote}
+	signBytes := createTestSignBytes(lockedValue, stepPrevote)
+
+	err := newSignState(10*time.Millisecond).ValidateConsensusLock(hrs, signBytes, -1)
+	var timeoutErr *ValidateTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, hrs, timeoutErr.HRS)
+
+	// Validation within the timeout is unaffected
+	require.NoError(t, newSignState(5*time.Second).ValidateConsensusLock(hrs, signBytes, -1))
+
+	// A validation that timed out records nothing once it completes
+	var mu sync.Mutex
+	var reported []error
+	signState := newSignState(10 * time.Millisecond)
+	signState.OnViolation = func(err error, _ HRSKey, _ []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	require.ErrorAs(t, signState.ValidateConsensusLock(hrs, signBytes, -1), &timeoutErr)
+	require.ErrorAs(t, signState.ValidateConsensusLock(
+		hrs, createTestSignBytes(differentValue, stepPrevote), -1), &timeoutErr)
+	time.Sleep(300 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Empty(t, reported)
+	require.Zero(t, signState.ApprovalsAtHeight(100))
+}
+
+func TestMaxRound(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	signState := &SignState{
+		ConsensusLockOptions: ConsensusLockOptions{MaxRound: 10},
+	}
+	signBytes := createTestSignBytes(blockHash, stepPrevote)
+
+	require.NoError(t, signState.ValidateConsensusLock(HRSKey{Height: 100, Round: 10, Step: stepPrevote}, signBytes, -1))
+
+	hrs := HRSKey{Height: 100, Round: 11, Step: stepPrevote}
+	err := signState.ValidateConsensusLock(hrs, signBytes, -1)
+	var ceilingErr *RoundCeilingError
+	require.ErrorAs(t, err, &ceilingErr)
+	require.Equal(t, hrs, ceilingErr.HRS)
+	require.Equal(t, int32(10), ceilingErr.MaxRound)
+
+	// No ceiling by default
+	signState.MaxRound = 0
+	require.NoError(t, signState.ValidateConsensusLock(hrs, signBytes, -1))
+}
+
+func TestConsensusLockSameValue(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+
+	lock := ConsensusLock{Height: 100, Round: 2, Value: lockedValue}
+
+	require.True(t, lock.SameValue(ConsensusLock{Height: 100, Round: 5, Value: bytes.Clone(lockedValue)}))
+	require.False(t, lock.SameValue(ConsensusLock{Height: 100, Round: 2, Value: differentValue}))
+	require.False(t, lock.SameValue(ConsensusLock{Height: 101, Round: 2, Value: lockedValue}))
+	require.False(t, lock.SameValue(ConsensusLock{}))
+	require.False(t, ConsensusLock{}.SameValue(ConsensusLock{}))
+}
+
+func TestConsensusLockShadowMode(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+
+	var reported []error
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: lockedValue},
+		ConsensusLockOptions: ConsensusLockOptions{
+			ShadowMode: true,
+			OnViolation: func(err error, _ HRSKey, _ []byte) {
+				reported = append(reported, err)
+			},
+		},
+	}
+	hrs := HRSKey{Height: 100, Round: 1, Step: stepPrevote}
+	attempted := createTestSignBytes(differentValue, stepPrevote)
+
+	// A would-be violation is allowed but reported
+	violationsBefore := testutil.ToFloat64(consensusLockViolations)
+	require.NoError(t, signState.ValidateConsensusLock(hrs, attempted, -1))
+	require.Len(t, reported, 1)
+	require.True(t, IsConsensusLockViolationError(reported[0]))
+	require.Equal(t, violationsBefore+1, testutil.ToFloat64(consensusLockViolations))
+
+	// Checking it again before signing does not report it again
+	_, err := signState.existingSignatureOrErrorIfRegression(HRSTKey{Height: 100, Round: 1, Step: stepPrevote}, attempted)
+	require.NoError(t, err)
+	require.Len(t, reported, 1)
+	require.Equal(t, violationsBefore+1, testutil.ToFloat64(consensusLockViolations))
+
+	// Allowed requests are not reported
+	require.NoError(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(lockedValue, stepPrevote), -1))
+	require.Len(t, reported, 1)
+
+	// Without shadow mode the violation is enforced
+	signState.ShadowMode = false
+	require.True(t, IsConsensusLockViolationError(signState.ValidateConsensusLock(hrs, attempted, -1)))
+	require.Len(t, reported, 2)
+}
+
+func TestConsensusLockCanaryMode(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	lock := ConsensusLock{Height: 100, Round: 0, Value: lockedValue}
+
+	var reported []error
+	signState := &SignState{
+		ConsensusLock: lock,
+		ConsensusLockOptions: ConsensusLockOptions{
+			CanaryMode: true,
+			OnViolation: func(err error, _ HRSKey, _ []byte) {
+				reported = append(reported, err)
+			},
+		},
+	}
+	hrs := HRSKey{Height: 100, Round: 1, Step: stepPrevote}
+
+	// A would-be violation is allowed, but recorded as if refused
+	violationsBefore := testutil.ToFloat64(consensusLockViolations)
+	overridesBefore := testutil.ToFloat64(canaryOverrides)
+	require.NoError(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(differentValue, stepPrevote), -1))
+	require.Len(t, reported, 1)
+	require.True(t, IsConsensusLockViolationError(reported[0]))
+	require.Equal(t, violationsBefore+1, testutil.ToFloat64(consensusLockViolations))
+	require.Equal(t, overridesBefore+1, testutil.ToFloat64(canaryOverrides))
+	require.Zero(t, signState.ApprovalsAtHeight(100))
+	require.Contains(t, signState.HeightReport(100), "violations blocked: 1")
+
+	// Allowed requests are approvals as usual
+	require.NoError(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(lockedValue, stepPrevote), -1))
+	require.Equal(t, 1, signState.ApprovalsAtHeight(100))
+	require.Equal(t, overridesBefore+1, testutil.ToFloat64(canaryOverrides))
+
+	// A would-be violating precommit does not move the lock, an allowed one does.
+	// Each would-be violation is counted once per request.
+	precommit := HRSKey{Height: 100, Round: 0, Step: stepPrecommit}
+	next, err := signState.ValidateAndAdvance(precommit, createTestSignBytes(differentValue, stepPrecommit))
+	require.NoError(t, err)
+	require.Equal(t, lock, next)
+	require.Equal(t, overridesBefore+2, testutil.ToFloat64(canaryOverrides))
+	precommit.Round = 1
+	next, err = signState.ValidateAndAdvance(precommit, createTestSignBytes(differentValue, stepPrecommit))
+	require.NoError(t, err)
+	require.Equal(t, differentValue, next.Value)
+	require.Equal(t, int64(1), next.Round)
+	require.Equal(t, overridesBefore+2, testutil.ToFloat64(canaryOverrides))
+
+	// Even a halted signer allows
+	signState.Halted = true
+	require.NoError(t, signState.ValidateConsensusLock(hrs, createTestSignBytes(lockedValue, stepPrevote), -1))
+	require.Equal(t, overridesBefore+3, testutil.ToFloat64(canaryOverrides))
+}
+
+// createTestVoteSignBytes creates vote sign bytes for a block ID with a part set header.
+func createTestVoteSignBytes(blockHash, partSetHeaderHash []byte, step int8) []byte {
+	signBytes, _ := protoio.MarshalDelimited(&cometproto.CanonicalVote{
+		Type:   StepToType(step),
+		Height: 100,
+		Round:  5,
+		BlockID: &cometproto.CanonicalBlockID{
+			Hash:          blockHash,
+			PartSetHeader: cometproto.CanonicalPartSetHeader{Total: 1, Hash: partSetHeaderHash},
+		},
+	})
+	return signBytes
+}
+
+func TestConsensusLockPartSetHeader(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	partsA := []byte("part_set_header_a_1234567890123456789012")[:32]
+	partsB := []byte("part_set_header_b_1234567890123456789012")[:32]
+
+	precommit := HRSKey{Height: 100, Round: 0, Step: stepPrecommit}
+	prevote := HRSKey{Height: 100, Round: 1, Step: stepPrevote}
+
+	t.Run("enabled", func(t *testing.T) {
+		signState := &SignState{ConsensusLockOptions: ConsensusLockOptions{TrackPartSetHeader: true}}
+		lock, err := signState.AdvanceConsensusLock(precommit, createTestVoteSignBytes(blockHash, partsA, stepPrecommit))
+		require.NoError(t, err)
+		require.Equal(t, blockHash, lock.Value)
+		require.Equal(t, partsA, lock.PartSetHeader)
+
+		require.NoError(t, signState.ValidateConsensusLock(
+			prevote, createTestVoteSignBytes(blockHash, partsA, stepPrevote), -1))
+		err = signState.ValidateConsensusLock(prevote, createTestVoteSignBytes(blockHash, partsB, stepPrevote), -1)
+		require.True(t, IsConsensusLockViolationError(err))
+
+		// A later precommit for the same block with other parts moves the lock
+		lock, err = signState.AdvanceConsensusLock(
+			HRSKey{Height: 100, Round: 2, Step: stepPrecommit}, createTestVoteSignBytes(blockHash, partsB, stepPrecommit))
+		require.NoError(t, err)
+		require.Equal(t, int64(2), lock.Round)
+		require.Equal(t, partsB, lock.PartSetHeader)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		signState := &SignState{}
+		lock, err := signState.AdvanceConsensusLock(precommit, createTestVoteSignBytes(blockHash, partsA, stepPrecommit))
+		require.NoError(t, err)
+		require.Nil(t, lock.PartSetHeader)
+
+		require.NoError(t, signState.ValidateConsensusLock(
+			prevote, createTestVoteSignBytes(blockHash, partsB, stepPrevote), -1))
+	})
+}
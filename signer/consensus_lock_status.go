@@ -0,0 +1,73 @@
+package signer
+
+import (
+	cometprotoprivval "github.com/cometbft/cometbft/proto/tendermint/privval"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LockErrorToStatus maps an error returned by consensus lock validation to a gRPC status.
+// Lock violations, double signs and requests refused by the state of the signer map to
+// codes.FailedPrecondition, sign bytes that cannot be parsed map to codes.InvalidArgument,
+// a cosigner quorum that does not agree on the lock maps to codes.Unavailable, a validation
+// that timed out maps to codes.DeadlineExceeded and nil maps to OK. Errors that already carry a
+// gRPC status keep it; anything else is codes.Internal. The message is the error text.
+func LockErrorToStatus(err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+	return status.New(lockErrorCode(err), err.Error())
+}
+
+// LockErrorToRemoteSignerError maps an error returned by consensus lock validation to
+// the error returned to the node over the remote signer protocol. For the kinds of
+// LockErrorKinds, the code is the numeric gRPC code LockErrorToStatus would use, so lock
+// violations are distinguishable from unparsable requests. Any other error keeps code 0,
+// as the remote signer protocol always used. nil maps to nil.
+func LockErrorToRemoteSignerError(err error) *cometprotoprivval.RemoteSignerError {
+	if err == nil {
+		return nil
+	}
+	var code codes.Code
+	if isLockError(err) {
+		code = lockErrorCode(err)
+	}
+	return &cometprotoprivval.RemoteSignerError{
+		Code:        int32(code),
+		Description: err.Error(),
+	}
+}
+
+// isLockError returns true if err is of any of the kinds of LockErrorKinds.
+func isLockError(err error) bool {
+	for _, kind := range lockErrorKinds {
+		if kind.Is(err) {
+			return true
+		}
+	}
+	return false
+}
+
+func lockErrorCode(err error) codes.Code {
+	switch {
+	case IsConsensusLockViolationError(err), IsConsensusLockStepViolationError(err), IsCrossStepConflictError(err),
+		IsDoubleSignError(err), IsSignerHaltedError(err), IsHRSRegressionError(err):
+		return codes.FailedPrecondition
+	case IsTimestampSkewError(err), IsTimestampRegressionError(err), IsUninitializedSignStateError(err),
+		IsProposerMismatchError(err):
+		return codes.FailedPrecondition
+	case IsParseError(err), IsRoundCeilingError(err):
+		return codes.InvalidArgument
+	case IsChainIDNotAllowedError(err):
+		return codes.PermissionDenied
+	case IsQuorumNotMetError(err):
+		return codes.Unavailable
+	case IsValidateTimeoutError(err):
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,102 @@
+package signer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLockErrorToStatus(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: lockedValue},
+	}
+	violationErr := signState.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 1, Step: stepPrevote}, createTestSignBytes(differentValue, stepPrevote), -1)
+	require.Error(t, violationErr)
+
+	testCases := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"nil", nil, codes.OK},
+		{"lock violation", violationErr, codes.FailedPrecondition},
+		{"double sign", newDiffBlockIDsError(lockedValue, differentValue), codes.FailedPrecondition},
+		{"height regression", newHeightRegressionError(99, 100), codes.FailedPrecondition},
+		{"oversized", newOversizedSignBytesError(2048, 1024), codes.InvalidArgument},
+		{
+			"unparsable",
+			newBlockHashExtractionError(stepPrevote, newUnmarshalError("signBytes", "vote", errors.New("bad"))),
+			codes.InvalidArgument,
+		},
+		{"quorum not met", newQuorumNotMetError(HRSKey{Height: 100}, 1, 3, 2, nil), codes.Unavailable},
+		{"timestamp skew", newTimestampSkewError(time.Unix(0, 0), time.Unix(60, 0), time.Second), codes.FailedPrecondition},
+		{
+			"timestamp regression",
+			newTimestampRegressionError(HRSKey{Height: 100}, time.Unix(0, 0), time.Unix(60, 0)),
+			codes.FailedPrecondition,
+		},
+		{"uninitialized", newUninitializedSignStateError(HRSKey{Height: 100}), codes.FailedPrecondition},
+		{"validate timeout", newValidateTimeoutError(HRSKey{Height: 100}, time.Second), codes.DeadlineExceeded},
+		{"existing status", status.Error(codes.Unavailable, "down"), codes.Unavailable},
+		{"other", errors.New("boom"), codes.Internal},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := LockErrorToStatus(tc.err)
+			require.Equal(t, tc.code, s.Code())
+			switch {
+			case tc.err == nil:
+				require.Empty(t, s.Message())
+			case tc.code != codes.Unavailable:
+				require.Equal(t, tc.err.Error(), s.Message())
+			}
+		})
+	}
+}
+
+func TestLockErrorToRemoteSignerError(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: lockedValue},
+	}
+	violationErr := signState.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 1, Step: stepPrevote}, createTestSignBytes(differentValue, stepPrevote), -1)
+	require.Error(t, violationErr)
+
+	require.Nil(t, LockErrorToRemoteSignerError(nil))
+
+	violation := LockErrorToRemoteSignerError(violationErr)
+	require.Equal(t, int32(codes.FailedPrecondition), violation.Code)
+	require.Equal(t, violationErr.Error(), violation.Description)
+
+	// A violation is distinguishable from an unparsable request and an internal failure
+	oversized := LockErrorToRemoteSignerError(newOversizedSignBytesError(2048, 1024))
+	require.Equal(t, int32(codes.InvalidArgument), oversized.Code)
+	require.NotEqual(t, violation.Code, oversized.Code)
+
+	// Every kind of lock error has a specific code
+	for _, known := range KnownLockErrors() {
+		code := LockErrorToRemoteSignerError(known).Code
+		require.NotEqual(t, int32(codes.OK), code, known.Error())
+		require.NotEqual(t, int32(codes.Internal), code, known.Error())
+	}
+
+	// Errors that are not lock errors keep code 0
+	other := LockErrorToRemoteSignerError(errors.New("boom"))
+	require.Equal(t, int32(0), other.Code)
+	require.Equal(t, "boom", other.Description)
+	unavailable := LockErrorToRemoteSignerError(status.Error(codes.Unavailable, "down"))
+	require.Equal(t, int32(0), unavailable.Code)
+}
@@ -0,0 +1,194 @@
+package signer
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LockStore persists the consensus lock.
+type LockStore interface {
+	// SaveLock durably stores lock, replacing any previously stored lock.
+	SaveLock(lock ConsensusLock) error
+	// LoadLock returns the most recently stored lock.
+	LoadLock() (ConsensusLock, error)
+}
+
+// debouncedLockSaver coalesces consensus lock changes and saves only the latest
+// one to a LockStore at most once per interval.
+type debouncedLockSaver struct {
+	store LockStore
+
+	// saveMu serializes saves, so that an older lock never overwrites a newer one.
+	saveMu sync.Mutex
+
+	// mu protects the fields below. It is never held while saving, so that
+	// update, which is called on the signing path, never waits on the store.
+	mu      sync.Mutex
+	pending ConsensusLock
+	updates uint64 // incremented by every update
+	dirty   bool
+	err     error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newDebouncedLockSaver(store LockStore, interval time.Duration, lock ConsensusLock) *debouncedLockSaver {
+	s := &debouncedLockSaver{
+		store: store,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	s.update(lock)
+	go s.run(interval)
+	return s
+}
+
+func (s *debouncedLockSaver) run(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = s.flush()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// update records lock as the latest lock to be saved.
+func (s *debouncedLockSaver) update(lock ConsensusLock) {
+	if lock.Value != nil {
+		lock.Value = append([]byte{}, lock.Value...)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = lock
+	s.updates++
+	s.dirty = true
+}
+
+// flush saves the latest lock if it has not been saved yet. A failed save is
+// retried on the next flush.
+func (s *debouncedLockSaver) flush() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	if !s.dirty {
+		err := s.err
+		s.mu.Unlock()
+		return err
+	}
+	lock, updates := s.pending, s.updates
+	s.mu.Unlock()
+
+	err := s.store.SaveLock(lock)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	// A lock updated while saving is still to be saved
+	if err == nil && s.updates == updates {
+		s.dirty = false
+	}
+	return err
+}
+
+// close stops the periodic flushes and saves the latest lock.
+func (s *debouncedLockSaver) close() error {
+	close(s.stop)
+	<-s.done
+	return s.flush()
+}
+
+// EnableDebouncedSave persists the consensus lock to store asynchronously,
+// coalescing changes so that at most one save happens per interval. Only the
+// latest lock is ever needed for crash recovery, and Close always flushes it.
+func (signState *SignState) EnableDebouncedSave(store LockStore, interval time.Duration) {
+	signState.mu.Lock()
+	previous := signState.lockSaver
+	signState.lockSaver = newDebouncedLockSaver(store, interval, signState.ConsensusLock)
+	signState.mu.Unlock()
+
+	if previous != nil {
+		_ = previous.close()
+	}
+}
+
+// Close stops debounced saving, if enabled, after flushing the latest consensus lock.
+func (signState *SignState) Close() error {
+	signState.mu.Lock()
+	saver := signState.lockSaver
+	signState.lockSaver = nil
+	signState.mu.Unlock()
+
+	if saver == nil {
+		return nil
+	}
+	return saver.close()
+}
+
+// FlushOnShutdown stops debounced saving, if enabled, then saves the current consensus lock
+// to store and loads it back to verify that it was stored intact. It returns a
+// *LockVerificationError if the stored lock differs, so that a signer never shuts down
+// believing its lock is durable when it is not.
+func (signState *SignState) FlushOnShutdown(store LockStore) error {
+	// Any error is superseded by saving the current lock below
+	_ = signState.Close()
+
+	signState.mu.RLock()
+	lock := signState.ConsensusLock
+	signState.mu.RUnlock()
+
+	if err := store.SaveLock(lock); err != nil {
+		return fmt.Errorf("failed to save consensus lock: %w", err)
+	}
+	loaded, err := store.LoadLock()
+	if err != nil {
+		return fmt.Errorf("failed to load saved consensus lock: %w", err)
+	}
+	if !sameStoredLock(lock, loaded) {
+		return newLockVerificationError(lock, loaded)
+	}
+	return nil
+}
+
+// sameStoredLock returns true if b is a faithful copy of a.
+func sameStoredLock(a, b ConsensusLock) bool {
+	return a.IsLocked() == b.IsLocked() &&
+		a.Height == b.Height &&
+		a.Round == b.Round &&
+		bytes.Equal(a.Value, b.Value) &&
+		a.UpdatedAt.Equal(b.UpdatedAt) &&
+		a.SetBy == b.SetBy &&
+		a.Pinned == b.Pinned &&
+		bytes.Equal(a.PartSetHeader, b.PartSetHeader)
+}
+
+// LockVerificationError is returned when a saved consensus lock does not load back intact.
+type LockVerificationError struct {
+	Saved  ConsensusLock
+	Loaded ConsensusLock
+}
+
+func (e *LockVerificationError) Error() string {
+	return fmt.Sprintf("saved consensus lock %X at %d/%d but loaded %X at %d/%d",
+		e.Saved.Value, e.Saved.Height, e.Saved.Round, e.Loaded.Value, e.Loaded.Height, e.Loaded.Round)
+}
+
+func newLockVerificationError(saved, loaded ConsensusLock) *LockVerificationError {
+	return &LockVerificationError{Saved: saved, Loaded: loaded}
+}
+
+// lockedLockChanged hands a changed consensus lock to the debounced saver, if enabled.
+// Not thread-safe (requires external lock).
+func (signState *SignState) lockedLockChanged() {
+	if signState.lockSaver != nil {
+		signState.lockSaver.update(signState.ConsensusLock)
+	}
+}
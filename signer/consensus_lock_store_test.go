@@ -0,0 +1,208 @@
+package signer
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memLockStore struct {
+	mu    sync.Mutex
+	lock  ConsensusLock
+	saves int
+	err   error
+}
+
+func (s *memLockStore) SaveLock(lock ConsensusLock) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.lock = lock
+	s.saves++
+	return nil
+}
+
+func (s *memLockStore) LoadLock() (ConsensusLock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lock, nil
+}
+
+func (s *memLockStore) Saves() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves
+}
+
+func advanceRounds(t *testing.T, signState *SignState, height int64, rounds int) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+	for round := int64(0); round < int64(rounds); round++ {
+		value := blockA
+		if round%2 == 1 {
+			value = blockB
+		}
+		_, err := signState.AdvanceConsensusLock(
+			HRSKey{Height: height, Round: round, Step: stepPrecommit}, createTestSignBytes(value, stepPrecommit))
+		require.NoError(t, err)
+	}
+}
+
+func TestDebouncedSaveFlushesOnClose(t *testing.T) {
+	store := &memLockStore{}
+	signState := &SignState{}
+
+	// An interval this long never fires, so only Close saves
+	signState.EnableDebouncedSave(store, time.Hour)
+	advanceRounds(t, signState, 100, 50)
+	require.Zero(t, store.Saves())
+
+	require.NoError(t, signState.Close())
+	require.Equal(t, 1, store.Saves())
+
+	saved, err := store.LoadLock()
+	require.NoError(t, err)
+	require.Equal(t, signState.ConsensusLock, saved)
+	require.Equal(t, int64(49), saved.Round)
+
+	// Closing again is a no-op
+	require.NoError(t, signState.Close())
+	require.Equal(t, 1, store.Saves())
+}
+
+func TestDebouncedSaveCoalesces(t *testing.T) {
+	store := &memLockStore{}
+	signState := &SignState{}
+
+	signState.EnableDebouncedSave(store, 5*time.Millisecond)
+	advanceRounds(t, signState, 100, 200)
+
+	require.Eventually(t, func() bool {
+		saved, _ := store.LoadLock()
+		return saved.Round == 199
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, signState.Close())
+	require.Less(t, store.Saves(), 200)
+	saved, err := store.LoadLock()
+	require.NoError(t, err)
+	require.Equal(t, signState.ConsensusLock, saved)
+}
+
+func TestDebouncedSaveRetriesFailedFlush(t *testing.T) {
+	errSave := errors.New("disk full")
+	store := &memLockStore{err: errSave}
+	signState := &SignState{}
+
+	signState.EnableDebouncedSave(store, time.Hour)
+	advanceRounds(t, signState, 100, 3)
+	require.ErrorIs(t, signState.Close(), errSave)
+
+	// Re-enabling saves the current lock once the store recovers
+	store.mu.Lock()
+	store.err = nil
+	store.mu.Unlock()
+	signState.EnableDebouncedSave(store, time.Hour)
+	require.NoError(t, signState.Close())
+	saved, err := store.LoadLock()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), saved.Round)
+}
+
+// corruptingLockStore flips a bit of every lock value it saves.
+type corruptingLockStore struct {
+	memLockStore
+}
+
+func (s *corruptingLockStore) SaveLock(lock ConsensusLock) error {
+	lock.Value = append([]byte{}, lock.Value...)
+	if len(lock.Value) > 0 {
+		lock.Value[0] ^= 1
+	}
+	return s.memLockStore.SaveLock(lock)
+}
+
+func TestFlushOnShutdown(t *testing.T) {
+	store := &memLockStore{}
+	signState := &SignState{}
+	advanceRounds(t, signState, 100, 3)
+
+	require.NoError(t, signState.FlushOnShutdown(store))
+	saved, err := store.LoadLock()
+	require.NoError(t, err)
+	require.Equal(t, signState.ConsensusLock, saved)
+
+	// A store that corrupts the lock on write is caught
+	err = signState.FlushOnShutdown(&corruptingLockStore{})
+	var verificationErr *LockVerificationError
+	require.ErrorAs(t, err, &verificationErr)
+	require.Equal(t, signState.ConsensusLock, verificationErr.Saved)
+
+	// A store that fails to write is reported
+	errSave := errors.New("disk full")
+	require.ErrorIs(t, signState.FlushOnShutdown(&memLockStore{err: errSave}), errSave)
+}
+
+func TestFlushOnShutdownStopsDebouncedSave(t *testing.T) {
+	store := &memLockStore{}
+	signState := &SignState{}
+	signState.EnableDebouncedSave(store, time.Hour)
+	advanceRounds(t, signState, 100, 3)
+
+	require.NoError(t, signState.FlushOnShutdown(store))
+	saves := store.Saves()
+
+	// Later lock changes are no longer saved in the background
+	advanceRounds(t, signState, 101, 1)
+	require.NoError(t, signState.Close())
+	require.Equal(t, saves, store.Saves())
+}
+
+// blockingLockStore blocks every save until release is closed.
+type blockingLockStore struct {
+	memLockStore
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingLockStore) SaveLock(lock ConsensusLock) error {
+	select {
+	case s.saving <- struct{}{}:
+	default:
+	}
+	<-s.release
+	return s.memLockStore.SaveLock(lock)
+}
+
+func TestDebouncedSaveDoesNotBlockSigning(t *testing.T) {
+	store := &blockingLockStore{saving: make(chan struct{}, 1), release: make(chan struct{})}
+	signState := &SignState{}
+	signState.EnableDebouncedSave(store, time.Millisecond)
+
+	// Wait for a flush to block in the store
+	<-store.saving
+
+	// Lock changes are still taken in the meantime
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		advanceRounds(t, signState, 100, 3)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock change blocked on a flush in progress")
+	}
+
+	// The lock changed during the flush is saved by a later one
+	close(store.release)
+	require.NoError(t, signState.Close())
+	saved, err := store.LoadLock()
+	require.NoError(t, err)
+	require.Equal(t, signState.ConsensusLock, saved)
+}
@@ -0,0 +1,88 @@
+package signer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// timestampTracker remembers the latest timestamp signed at the current height. Only one
+// height is kept, as a timestamp is only compared with those of its own height.
+type timestampTracker struct {
+	mu     sync.Mutex
+	height int64
+	last   time.Time
+}
+
+// lastAt returns the latest timestamp signed at height, if any.
+func (t *timestampTracker) lastAt(height int64) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.height != height || t.last.IsZero() {
+		return time.Time{}, false
+	}
+	return t.last, true
+}
+
+// record remembers timestamp as signed at height, forgetting earlier heights.
+func (t *timestampTracker) record(height int64, timestamp time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case height > t.height:
+		t.height, t.last = height, timestamp
+	case height == t.height && timestamp.After(t.last):
+		t.last = timestamp
+	}
+}
+
+// clone returns a copy of t.
+func (t *timestampTracker) clone() timestampTracker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return timestampTracker{height: t.height, last: t.last}
+}
+
+// TimestampRegressionError is returned with MonotonicTimestamps when sign bytes carry a
+// timestamp earlier than one already signed at the same height.
+type TimestampRegressionError struct {
+	HRS       HRSKey
+	Timestamp time.Time
+	Last      time.Time
+}
+
+func (e *TimestampRegressionError) Error() string {
+	return fmt.Sprintf("timestamp %s at %d:%d:%d is earlier than %s already signed at the same height",
+		e.Timestamp.Format(time.RFC3339Nano), e.HRS.Height, e.HRS.Round, e.HRS.Step, e.Last.Format(time.RFC3339Nano))
+}
+
+func newTimestampRegressionError(hrs HRSKey, timestamp, last time.Time) *TimestampRegressionError {
+	return &TimestampRegressionError{
+		HRS:       hrs,
+		Timestamp: timestamp,
+		Last:      last,
+	}
+}
+
+// checkTimestampRegression returns a *TimestampRegressionError if the timestamp in signBytes
+// is earlier than the latest one approved at the same height.
+func (signState *SignState) checkTimestampRegression(hrs HRSKey, signBytes []byte) error {
+	decoded, err := signState.decode(hrs.Step, signBytes)
+	if err != nil {
+		return err
+	}
+	if last, ok := signState.timestamps.lastAt(hrs.Height); ok && decoded.timestamp.Before(last) {
+		return newTimestampRegressionError(hrs, decoded.timestamp, last)
+	}
+	return nil
+}
+
+// recordTimestamp remembers the timestamp of approved sign bytes for MonotonicTimestamps.
+func (signState *SignState) recordTimestamp(hrs HRSKey, signBytes []byte) {
+	if !signState.MonotonicTimestamps {
+		return
+	}
+	if decoded, err := signState.decode(hrs.Step, signBytes); err == nil {
+		signState.timestamps.record(hrs.Height, decoded.timestamp)
+	}
+}
@@ -0,0 +1,48 @@
+package signer
+
+// Tracer starts spans around consensus lock validation. It is deliberately small so
+// that it can be backed by any tracing library, e.g. with an OpenTelemetry adapter:
+//
+//	func (t otelTracer) StartSpan(name string) signer.Span {
+//		_, span := t.tracer.Start(context.Background(), name)
+//		return otelSpan{span}
+//	}
+type Tracer interface {
+	StartSpan(name string) Span
+}
+
+// Span is a single traced operation started by a Tracer.
+type Span interface {
+	// SetAttributes records attributes on the span.
+	SetAttributes(attrs ...SpanAttribute)
+	// End completes the span.
+	End()
+}
+
+// SpanAttribute is a key-value pair recorded on a Span.
+type SpanAttribute struct {
+	Key   string
+	Value interface{}
+}
+
+// validateConsensusLockSpan is the name of the span around ValidateSignRequest.
+const validateConsensusLockSpan = "horcrux.ValidateConsensusLock"
+
+// traceValidation runs validate in a span recording the request HRS and the decision.
+func (signState *SignState) traceValidation(hrs HRSKey, validate func() error) error {
+	span := signState.Tracer.StartSpan(validateConsensusLockSpan)
+	defer span.End()
+
+	err := validate()
+	decision := "allow"
+	if err != nil {
+		decision = "deny"
+	}
+	span.SetAttributes(
+		SpanAttribute{Key: "height", Value: hrs.Height},
+		SpanAttribute{Key: "round", Value: hrs.Round},
+		SpanAttribute{Key: "step", Value: hrs.Step},
+		SpanAttribute{Key: "decision", Value: decision},
+	)
+	return err
+}
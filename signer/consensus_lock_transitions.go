@@ -0,0 +1,81 @@
+package signer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// maxRecordedTransitions is how many of the most recent lock transitions are kept for ExportTransitionsCSV.
+const maxRecordedTransitions = 256
+
+// lockTransition is a change of the consensus lock made by signing at hrs.
+type lockTransition struct {
+	time time.Time
+	hrs  HRSKey
+	from ConsensusLock
+	to   ConsensusLock
+}
+
+// lockedRecordTransition records a change of the lock from from to to by signing at hrs,
+// forgetting the oldest transitions beyond maxRecordedTransitions. Not thread-safe
+// (requires external lock).
+func (signState *SignState) lockedRecordTransition(hrs HRSKey, from, to ConsensusLock) {
+	signState.transitions = append(signState.transitions, lockTransition{
+		time: signState.now(),
+		hrs:  hrs,
+		from: from,
+		to:   to,
+	})
+	if excess := len(signState.transitions) - maxRecordedTransitions; excess > 0 {
+		signState.transitions = append([]lockTransition(nil), signState.transitions[excess:]...)
+	}
+}
+
+// lockedCopyTransitions returns a deep copy of the recorded transitions.
+// Not thread-safe (requires external lock).
+func (signState *SignState) lockedCopyTransitions() []lockTransition {
+	if signState.transitions == nil {
+		return nil
+	}
+	transitions := make([]lockTransition, len(signState.transitions))
+	for i, t := range signState.transitions {
+		t.from, t.to = copyConsensusLock(t.from), copyConsensusLock(t.to)
+		transitions[i] = t
+	}
+	return transitions
+}
+
+// ExportTransitionsCSV writes the lock transitions recorded by s as CSV, one row per transition
+// with the columns timestamp, height, round, step, old_value_hex and new_value_hex, oldest first.
+// The HRS is that of the request whose signing set, moved or cleared the lock, and an empty
+// value means unlocked. Only transitions made by signing since s was loaded are recorded, up to
+// the most recent 256. Without any, only the header is written. The history is not part of the
+// JSON sign state file, so it is lost on restart, but Restore and UnmarshalBinary return it to
+// that of the snapshot along with the lock.
+func ExportTransitionsCSV(s *SignState, w io.Writer) error {
+	s.mu.RLock()
+	transitions := append([]lockTransition(nil), s.transitions...)
+	s.mu.RUnlock()
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"timestamp", "height", "round", "step", "old_value_hex", "new_value_hex"}); err != nil {
+		return err
+	}
+	for _, t := range transitions {
+		if err := cw.Write([]string{
+			t.time.UTC().Format(time.RFC3339Nano),
+			strconv.FormatInt(t.hrs.Height, 10),
+			strconv.FormatInt(t.hrs.Round, 10),
+			strconv.Itoa(int(t.hrs.Step)),
+			fmt.Sprintf("%X", t.from.Value),
+			fmt.Sprintf("%X", t.to.Value),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,37 @@
+package signer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportTransitionsCSV(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	signState := &SignState{ConsensusLockOptions: ConsensusLockOptions{Now: func() time.Time { return now }}}
+
+	const header = "timestamp,height,round,step,old_value_hex,new_value_hex\n"
+
+	// No transitions yet
+	var buf bytes.Buffer
+	require.NoError(t, ExportTransitionsCSV(signState, &buf))
+	require.Equal(t, header, buf.String())
+
+	// A precommit sets the lock, a prevote for the same value changes nothing
+	_, err := signState.ValidateAndAdvance(
+		HRSKey{Height: 100, Round: 5, Step: stepPrecommit}, createTestSignBytes(blockHash, stepPrecommit))
+	require.NoError(t, err)
+	_, err = signState.ValidateAndAdvance(
+		HRSKey{Height: 100, Round: 6, Step: stepPrevote}, createTestSignBytes(blockHash, stepPrevote))
+	require.NoError(t, err)
+
+	buf.Reset()
+	require.NoError(t, ExportTransitionsCSV(signState, &buf))
+	lines := strings.SplitAfter(buf.String(), "\n")
+	require.Equal(t, []string{header, fmt.Sprintf("2024-01-02T03:04:05Z,100,5,3,,%X\n", blockHash), ""}, lines)
+}
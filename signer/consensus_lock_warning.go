@@ -0,0 +1,59 @@
+package signer
+
+import (
+	"errors"
+	"sync"
+)
+
+// longLockWarning remembers which lock a long lock warning was last logged for, so that
+// it is logged once per lock rather than once per blocked request.
+type longLockWarning struct {
+	mu     sync.Mutex
+	warned HRSKey
+}
+
+// once returns true the first time it is called for lock.
+func (w *longLockWarning) once(lock HRSKey) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.warned == lock {
+		return false
+	}
+	w.warned = lock
+	return true
+}
+
+// clone returns a copy of w.
+func (w *longLockWarning) clone() longLockWarning {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return longLockWarning{warned: w.warned}
+}
+
+// warnLongLock logs a warning, once per lock, if err blocked a value at a round more than
+// LongLockWarnRounds after the lock round. A lock held for that long without a precommit
+// moving it suggests consensus is struggling to make progress.
+func (signState *SignState) warnLongLock(err error, hrs HRSKey) {
+	if signState.Logger == nil || signState.LongLockWarnRounds <= 0 {
+		return
+	}
+	var violationErr *ConsensusLockViolationError
+	if !errors.As(err, &violationErr) {
+		return
+	}
+	gap := hrs.Round - violationErr.Round
+	if gap <= signState.LongLockWarnRounds {
+		return
+	}
+	lock := HRSKey{Height: violationErr.Height, Round: violationErr.Round, Step: stepPrecommit}
+	if !signState.longLockWarning.once(lock) {
+		return
+	}
+	signState.Logger.Error(
+		"Consensus lock held for many rounds without release",
+		"lock_height", violationErr.Height,
+		"lock_round", violationErr.Round,
+		"round", hrs.Round,
+		"round_gap", gap,
+	)
+}
@@ -0,0 +1,86 @@
+package signer
+
+import "fmt"
+
+// SignRequest is a single request to sign at an HRS, as seen by the consensus lock.
+type SignRequest struct {
+	HRS           HRSKey
+	SignBytes     []byte
+	PolRound      int64  // -2 if the POL round is unknown
+	VoteExtension []byte // Only needed when locking on extension data
+	Proposer      []byte // Address of the signing validator, checked for proposals with ExpectedProposerAddr
+}
+
+// ProgressionError reports the first sign request in a sequence that violates a signing rule.
+type ProgressionError struct {
+	Index int
+	HRS   HRSKey
+	Err   error
+}
+
+func (e *ProgressionError) Error() string {
+	return fmt.Sprintf("sign request %d at %d:%d:%d rejected: %v",
+		e.Index, e.HRS.Height, e.HRS.Round, e.HRS.Step, e.Err)
+}
+
+func (e *ProgressionError) Unwrap() error {
+	return e.Err
+}
+
+func newProgressionError(index int, hrs HRSKey, err error) *ProgressionError {
+	return &ProgressionError{
+		Index: index,
+		HRS:   hrs,
+		Err:   err,
+	}
+}
+
+// ValidateProgression checks that a sequence of sign requests respects both HRS
+// monotonicity and the consensus lock rules, starting from initial. Each request
+// is validated and then applied, advancing the HRS and the lock, on a copy of
+// initial. The first violation is returned as a *ProgressionError. The progression is
+// hypothetical, so requests are checked without side effects: nothing is reported to
+// OnViolation, logged or counted.
+func ValidateProgression(initial *SignState, seq []SignRequest) error {
+	if initial == nil {
+		initial = &SignState{}
+	}
+	state := initial.Clone()
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for i, req := range seq {
+		if err := state.lockedApplyRequest(req); err != nil {
+			return newProgressionError(i, req.HRS, err)
+		}
+	}
+
+	return nil
+}
+
+// lockedApplyRequest validates req and advances the signed HRS, the cache and the consensus
+// lock as if it had been signed. Unlike Save, nothing is encoded or recorded.
+// Not thread-safe (requires external lock).
+func (signState *SignState) lockedApplyRequest(req SignRequest) error {
+	if err := signState.lockedCheckConsensusLock(req); err != nil {
+		return err
+	}
+	if err := signState.lockedCheckSignedHRS(req.HRS); err != nil {
+		return err
+	}
+
+	ssc := SignStateConsensus{
+		Height:        req.HRS.Height,
+		Round:         req.HRS.Round,
+		Step:          req.HRS.Step,
+		SignBytes:     req.SignBytes,
+		VoteExtension: req.VoteExtension,
+	}
+	nextLock, moved := signState.lockedNextLock(req.HRS, req.SignBytes, req.VoteExtension)
+	signState.lockedCacheSigned(ssc)
+	signState.lockedSetSigned(ssc)
+	if moved {
+		signState.lockedSetLock(req.HRS.Height, nextLock)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package signer
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func fullRound(height, round int64, blockHash []byte) []SignRequest {
+	return []SignRequest{
+		{
+			HRS:       HRSKey{Height: height, Round: round, Step: stepPropose},
+			SignBytes: createTestSignBytes(blockHash, stepPropose),
+			PolRound:  -1,
+		},
+		{
+			HRS:       HRSKey{Height: height, Round: round, Step: stepPrevote},
+			SignBytes: createTestSignBytes(blockHash, stepPrevote),
+			PolRound:  -1,
+		},
+		{
+			HRS:       HRSKey{Height: height, Round: round, Step: stepPrecommit},
+			SignBytes: createTestSignBytes(blockHash, stepPrecommit),
+			PolRound:  -1,
+		},
+	}
+}
+
+func TestValidateProgression(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+
+	var seq []SignRequest
+	seq = append(seq, fullRound(100, 0, blockA)...)
+	seq = append(seq, fullRound(100, 1, blockA)...)
+	seq = append(seq, fullRound(101, 0, blockB)...)
+
+	initial := &SignState{}
+	require.NoError(t, ValidateProgression(initial, seq))
+
+	// The initial state is left untouched
+	require.Equal(t, int64(0), initial.Height)
+	require.False(t, initial.ConsensusLock.IsLocked())
+
+	// A nil initial state starts from scratch
+	require.NoError(t, ValidateProgression(nil, seq))
+}
+
+func TestValidateProgressionHRSRegression(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+
+	var seq []SignRequest
+	seq = append(seq, fullRound(100, 1, blockA)...)
+	seq = append(seq, fullRound(100, 0, blockA)...)
+
+	err := ValidateProgression(&SignState{}, seq)
+	var progressionErr *ProgressionError
+	require.ErrorAs(t, err, &progressionErr)
+	require.Equal(t, 3, progressionErr.Index)
+	require.Equal(t, HRSKey{Height: 100, Round: 0, Step: stepPropose}, progressionErr.HRS)
+
+	var regressionErr *RoundRegressionError
+	require.ErrorAs(t, err, &regressionErr)
+}
+
+func TestValidateProgressionLockViolation(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+
+	var seq []SignRequest
+	seq = append(seq, fullRound(100, 0, blockA)...)
+	// Locked on A, so prevoting B in the next round without a POL is a violation
+	seq = append(seq, fullRound(100, 1, blockB)...)
+
+	var reported []error
+	initial := &SignState{ConsensusLockOptions: ConsensusLockOptions{
+		OnViolation: func(err error, _ HRSKey, _ []byte) {
+			reported = append(reported, err)
+		},
+	}}
+	violationsBefore := testutil.ToFloat64(consensusLockViolations)
+
+	err := ValidateProgression(initial, seq)
+	var progressionErr *ProgressionError
+	require.ErrorAs(t, err, &progressionErr)
+	require.Equal(t, 3, progressionErr.Index)
+	require.True(t, IsConsensusLockViolationError(err))
+
+	// The hypothetical violation is not reported
+	require.Empty(t, reported)
+	require.Equal(t, violationsBefore, testutil.ToFloat64(consensusLockViolations))
+	require.Zero(t, initial.ApprovalsAtHeight(100))
+}
+
+func TestValidateProgressionCrossStepConflict(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+
+	proposalA := SignRequest{
+		HRS:       HRSKey{Height: 100, Round: 0, Step: stepPropose},
+		SignBytes: createTestSignBytes(blockA, stepPropose),
+		PolRound:  -1,
+	}
+	prevote := func(round int64, hash []byte) SignRequest {
+		return SignRequest{
+			HRS:       HRSKey{Height: 100, Round: round, Step: stepPrevote},
+			SignBytes: createTestSignBytes(hash, stepPrevote),
+			PolRound:  -1,
+		}
+	}
+
+	// Proposing A then prevoting B in the same round is a conflict
+	err := ValidateProgression(nil, []SignRequest{proposalA, prevote(0, blockB)})
+	var conflictErr *CrossStepConflictError
+	require.ErrorAs(t, err, &conflictErr)
+	require.Equal(t, blockA, conflictErr.Proposed)
+	require.Equal(t, blockB, conflictErr.Prevoted)
+
+	// Prevoting the proposed block is fine
+	require.NoError(t, ValidateProgression(nil, []SignRequest{proposalA, prevote(0, blockA)}))
+
+	// A proposal in another round does not constrain the prevote
+	require.NoError(t, ValidateProgression(nil, []SignRequest{proposalA, prevote(1, blockB)}))
+}
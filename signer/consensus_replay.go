@@ -0,0 +1,117 @@
+package signer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ReplayFixture is a sequence of captured sign requests, e.g. from a testnet,
+// that can be replayed through the consensus lock rules.
+//
+// On disk a fixture is a sequence of records, each encoded as
+//
+//	varint height | varint round | byte step | varint POL round | uvarint len | sign bytes
+//
+// where varints use encoding/binary's zig-zag encoding.
+type ReplayFixture struct {
+	Requests []SignRequest
+}
+
+// LoadReplayFixture reads a replay fixture from the file at path.
+func LoadReplayFixture(path string) (ReplayFixture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ReplayFixture{}, err
+	}
+	defer f.Close()
+	return ReadReplayFixture(f)
+}
+
+// ReadReplayFixture reads a replay fixture from r.
+func ReadReplayFixture(r io.Reader) (ReplayFixture, error) {
+	br := bufio.NewReader(r)
+	var fixture ReplayFixture
+	for {
+		req, err := readReplayRecord(br)
+		if errors.Is(err, io.EOF) {
+			return fixture, nil
+		}
+		if err != nil {
+			return ReplayFixture{}, fmt.Errorf("replay record %d: %w", len(fixture.Requests), err)
+		}
+		fixture.Requests = append(fixture.Requests, req)
+	}
+}
+
+func readReplayRecord(r *bufio.Reader) (SignRequest, error) {
+	height, err := binary.ReadVarint(r)
+	if err != nil {
+		// A clean EOF is only valid between records.
+		return SignRequest{}, err
+	}
+	round, err := binary.ReadVarint(r)
+	if err != nil {
+		return SignRequest{}, unexpectedEOF(err)
+	}
+	step, err := r.ReadByte()
+	if err != nil {
+		return SignRequest{}, unexpectedEOF(err)
+	}
+	polRound, err := binary.ReadVarint(r)
+	if err != nil {
+		return SignRequest{}, unexpectedEOF(err)
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return SignRequest{}, unexpectedEOF(err)
+	}
+	if n > DefaultMaxSignBytesLen {
+		return SignRequest{}, newOversizedSignBytesError(int(n), DefaultMaxSignBytesLen)
+	}
+	signBytes := make([]byte, n)
+	if _, err := io.ReadFull(r, signBytes); err != nil {
+		return SignRequest{}, unexpectedEOF(err)
+	}
+	return SignRequest{
+		HRS:       HRSKey{Height: height, Round: round, Step: int8(step)},
+		SignBytes: signBytes,
+		PolRound:  polRound,
+	}, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// AppendReplayRecord appends the fixture encoding of req to dst.
+func AppendReplayRecord(dst []byte, req SignRequest) []byte {
+	dst = binary.AppendVarint(dst, req.HRS.Height)
+	dst = binary.AppendVarint(dst, req.HRS.Round)
+	dst = append(dst, byte(req.HRS.Step))
+	dst = binary.AppendVarint(dst, req.PolRound)
+	dst = binary.AppendUvarint(dst, uint64(len(req.SignBytes)))
+	return append(dst, req.SignBytes...)
+}
+
+// MarshalBinary encodes the fixture in its on-disk format.
+func (f ReplayFixture) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, req := range f.Requests {
+		buf.Write(AppendReplayRecord(nil, req))
+	}
+	return buf.Bytes(), nil
+}
+
+// Replay feeds the fixture through ValidateProgression starting from initial,
+// returning the first violation as a *ProgressionError.
+func (f ReplayFixture) Replay(initial *SignState) error {
+	return ValidateProgression(initial, f.Requests)
+}
@@ -0,0 +1,49 @@
+package signer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/strangelove-ventures/horcrux/v3/signer/testdata"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayFixture(t *testing.T) {
+	fixture, err := ReadReplayFixture(bytes.NewReader(testdata.ConsensusReplay))
+	require.NoError(t, err)
+	require.Len(t, fixture.Requests, 11)
+
+	first := fixture.Requests[0]
+	require.Equal(t, HRSKey{Height: 1000, Round: 0, Step: stepPropose}, first.HRS)
+	require.Equal(t, int64(-1), first.PolRound)
+	decoded, err := decodeCanonical(first.SignBytes, first.HRS.Step)
+	require.NoError(t, err)
+	require.Equal(t, "horcrux-testnet-1", decoded.chainID)
+	require.Equal(t, first.HRS.Height, decoded.height)
+
+	// The captured sequence is valid
+	require.NoError(t, fixture.Replay(nil))
+
+	// Encoding round-trips
+	bz, err := fixture.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, testdata.ConsensusReplay, bz)
+
+	// A prevote for a different value after the final lock, without a POL, is reported
+	last := fixture.Requests[len(fixture.Requests)-1]
+	otherHash := []byte("other_hash_123456789012345678901234567890")[:32]
+	fixture.Requests = append(fixture.Requests, SignRequest{
+		HRS:       HRSKey{Height: last.HRS.Height, Round: last.HRS.Round + 1, Step: stepPrevote},
+		SignBytes: createTestSignBytes(otherHash, stepPrevote),
+		PolRound:  -1,
+	})
+	err = fixture.Replay(nil)
+	var progressionErr *ProgressionError
+	require.ErrorAs(t, err, &progressionErr)
+	require.Equal(t, len(fixture.Requests)-1, progressionErr.Index)
+	require.True(t, IsConsensusLockViolationError(err))
+
+	// Truncated fixtures are rejected
+	_, err = ReadReplayFixture(bytes.NewReader(testdata.ConsensusReplay[:len(testdata.ConsensusReplay)-1]))
+	require.Error(t, err)
+}
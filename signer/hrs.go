@@ -1,6 +1,7 @@
 package signer
 
 import (
+	cometproto "github.com/cometbft/cometbft/proto/tendermint/types"
 	"github.com/strangelove-ventures/horcrux/v3/signer/proto"
 )
 
@@ -33,6 +34,75 @@ func (hrs HRSKey) LessThan(other HRSKey) bool {
 	return hrs != other && !hrs.GreaterThan(other)
 }
 
+// Bit widths of the fields of a packed HRSKey, from most to least significant.
+const (
+	packedHeightBits = 40
+	packedRoundBits  = 20
+	packedStepBits   = 4
+
+	maxPackedHeight = 1<<packedHeightBits - 1
+	maxPackedRound  = 1<<packedRoundBits - 1
+	maxPackedStep   = 1<<packedStepBits - 1
+)
+
+// Pack packs the HRSKey into a single uint64, e.g. for compact map keys. The height takes
+// the top 40 bits, the round the next 20 bits and the step the low 4 bits, so packed keys
+// order like GreaterThan. Fields out of range saturate: negative fields pack as zero and
+// larger ones as the field maximum, so such keys do not round trip through UnpackHRS and
+// may collide. Use Packable to check a key from an untrusted source first.
+func (hrs HRSKey) Pack() uint64 {
+	return clampPacked(hrs.Height, maxPackedHeight)<<(packedRoundBits+packedStepBits) |
+		clampPacked(hrs.Round, maxPackedRound)<<packedStepBits |
+		clampPacked(int64(hrs.Step), maxPackedStep)
+}
+
+// Packable returns true if every field of the HRSKey fits Pack, i.e. it round trips through UnpackHRS.
+func (hrs HRSKey) Packable() bool {
+	return hrs.Height >= 0 && hrs.Height <= maxPackedHeight &&
+		hrs.Round >= 0 && hrs.Round <= maxPackedRound &&
+		hrs.Step >= 0 && hrs.Step <= maxPackedStep
+}
+
+// UnpackHRS returns the HRSKey packed by Pack.
+func UnpackHRS(packed uint64) HRSKey {
+	return HRSKey{
+		Height: int64(packed >> (packedRoundBits + packedStepBits)),
+		Round:  int64((packed >> packedStepBits) & maxPackedRound),
+		Step:   int8(packed & maxPackedStep),
+	}
+}
+
+// clampPacked returns v limited to [0, maxValue].
+func clampPacked(v, maxValue int64) uint64 {
+	switch {
+	case v < 0:
+		return 0
+	case v > maxValue:
+		return uint64(maxValue)
+	default:
+		return uint64(v)
+	}
+}
+
+// HRSFromProposal returns the HRSKey of a canonical proposal.
+func HRSFromProposal(p *cometproto.CanonicalProposal) HRSKey {
+	return HRSKey{
+		Height: p.Height,
+		Round:  p.Round,
+		Step:   stepPropose,
+	}
+}
+
+// HRSFromVote returns the HRSKey of a canonical prevote or precommit.
+// It panics if the vote type is unknown.
+func HRSFromVote(v *cometproto.CanonicalVote) HRSKey {
+	return HRSKey{
+		Height: v.Height,
+		Round:  v.Round,
+		Step:   CanonicalVoteToStep(v),
+	}
+}
+
 // HRSTKey represents the HRS metadata key with a timestamp.
 type HRSTKey struct {
 	Height    int64
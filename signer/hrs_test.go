@@ -0,0 +1,91 @@
+package signer
+
+import (
+	"testing"
+
+	cometproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHRSFromProposal(t *testing.T) {
+	proposal := &cometproto.CanonicalProposal{
+		Type:   cometproto.ProposalType,
+		Height: 100,
+		Round:  5,
+	}
+	require.Equal(t, HRSKey{Height: 100, Round: 5, Step: stepPropose}, HRSFromProposal(proposal))
+}
+
+func TestHRSFromVote(t *testing.T) {
+	testCases := []struct {
+		msgType cometproto.SignedMsgType
+		step    int8
+	}{
+		{cometproto.PrevoteType, stepPrevote},
+		{cometproto.PrecommitType, stepPrecommit},
+	}
+
+	for _, tc := range testCases {
+		vote := &cometproto.CanonicalVote{
+			Type:   tc.msgType,
+			Height: 100,
+			Round:  5,
+		}
+		require.Equal(t, HRSKey{Height: 100, Round: 5, Step: tc.step}, HRSFromVote(vote))
+	}
+
+	require.Panics(t, func() {
+		HRSFromVote(&cometproto.CanonicalVote{Type: cometproto.ProposalType})
+	})
+}
+
+func TestHRSKeyPack(t *testing.T) {
+	for _, hrs := range []HRSKey{
+		{},
+		{Height: 1, Round: 0, Step: stepPropose},
+		{Height: 100, Round: 5, Step: stepPrevote},
+		{Height: 12345678, Round: 1, Step: stepPrecommit},
+		{Height: 100, Round: maxPackedRound, Step: stepPrecommit},
+		{Height: maxPackedHeight, Round: maxPackedRound, Step: maxPackedStep},
+	} {
+		require.True(t, hrs.Packable(), "%+v", hrs)
+		require.Equal(t, hrs, UnpackHRS(hrs.Pack()), "%+v", hrs)
+	}
+
+	// Packed keys order like HRSKeys
+	keys := []HRSKey{
+		{Height: 100, Round: 0, Step: stepPrecommit},
+		{Height: 100, Round: 1, Step: stepPropose},
+		{Height: 100, Round: maxPackedRound, Step: stepPropose},
+		{Height: 101, Round: 0, Step: stepPropose},
+	}
+	for i := 1; i < len(keys); i++ {
+		require.Less(t, keys[i-1].Pack(), keys[i].Pack())
+	}
+
+	// Out of range fields saturate
+	for _, tc := range []struct {
+		hrs      HRSKey
+		unpacked HRSKey
+	}{
+		{
+			hrs:      HRSKey{Height: 100, Round: maxPackedRound + 1, Step: stepPrevote},
+			unpacked: HRSKey{Height: 100, Round: maxPackedRound, Step: stepPrevote},
+		},
+		{
+			hrs:      HRSKey{Height: 100, Round: -1, Step: stepPrevote},
+			unpacked: HRSKey{Height: 100, Round: 0, Step: stepPrevote},
+		},
+		{
+			hrs:      HRSKey{Height: maxPackedHeight + 1, Round: 0, Step: stepPropose},
+			unpacked: HRSKey{Height: maxPackedHeight, Round: 0, Step: stepPropose},
+		},
+		{
+			hrs:      HRSKey{Height: 100, Round: 0, Step: -1},
+			unpacked: HRSKey{Height: 100, Round: 0, Step: 0},
+		},
+	} {
+		require.False(t, tc.hrs.Packable(), "%+v", tc.hrs)
+		require.Equal(t, tc.unpacked, UnpackHRS(tc.hrs.Pack()), "%+v", tc.hrs)
+	}
+}
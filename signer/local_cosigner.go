@@ -204,7 +204,7 @@ func (cosigner *LocalCosigner) VerifySignature(chainID string, payload, signatur
 // Sign the sign request using the cosigner's shard
 // Return the signed bytes or an error
 // Implements Cosigner interface
-func (cosigner *LocalCosigner) sign(req CosignerSignRequest) (CosignerSignResponse, error) {
+func (cosigner *LocalCosigner) sign(ctx context.Context, req CosignerSignRequest) (CosignerSignResponse, error) {
 	chainID := req.ChainID
 
 	res := CosignerSignResponse{}
@@ -220,8 +220,12 @@ func (cosigner *LocalCosigner) sign(req CosignerSignRequest) (CosignerSignRespon
 	}
 
 	// Check for consensus lock violations before proceeding
-	// Use POL round validation
-	if err := ccs.lastSignState.ValidateConsensusLock(hrst.HRSKey(), req.SignBytes, req.PolRound); err != nil {
+	// Use POL round validation, and our address as the proposer of proposals
+	policyCtx := WithPolRound(ctx, req.PolRound)
+	if hrst.Step == stepPropose {
+		policyCtx = WithProposer(policyCtx, cometcryptoed25519.PubKey(ccs.signer.PubKey()).Address())
+	}
+	if err := signingPolicy(ccs.lastSignState).Check(policyCtx, hrst.HRSKey(), req.SignBytes); err != nil {
 		// Log the specific consensus lock violation with context
 		cosigner.logger.Error("Consensus lock violation in local cosigner",
 			"chain_id", chainID,
@@ -358,6 +362,13 @@ func (cosigner *LocalCosigner) LoadSignStateIfNecessary(chainID string) error {
 		return err
 	}
 
+	opts, err := cosigner.config.ConsensusLockOptions(chainID)
+	if err != nil {
+		return err
+	}
+	opts.Logger = cosigner.logger
+	signState.ConsensusLockOptions = opts
+
 	var signer ThresholdSigner
 
 	signer, err = NewThresholdSignerSoft(cosigner.config, cosigner.GetID(), chainID)
@@ -530,7 +541,7 @@ func (cosigner *LocalCosigner) setNonce(uuid uuid.UUID, nonce CosignerNonce) err
 }
 
 func (cosigner *LocalCosigner) SetNoncesAndSign(
-	_ context.Context,
+	ctx context.Context,
 	req CosignerSetNoncesAndSignRequest) (*CosignerSignResponse, error) {
 	chainID := req.ChainID
 
@@ -576,6 +587,6 @@ func (cosigner *LocalCosigner) SetNoncesAndSign(
 		cosignerReq.VoteExtUUID = req.VoteExtensionNonces.UUID
 	}
 
-	res, err := cosigner.sign(cosignerReq)
+	res, err := cosigner.sign(ctx, cosignerReq)
 	return &res, err
 }
@@ -17,6 +17,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto/ecies"
 	"github.com/ethereum/go-ethereum/crypto/secp256k1"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	tsed25519 "gitlab.com/unit410/threshold-ed25519/pkg"
 )
@@ -231,3 +232,114 @@ func testLocalCosignerSign(t *testing.T, threshold, total uint8, security []Cosi
 
 	require.True(t, pubKey.VerifySignature(signBytes, combinedSig))
 }
+
+// signWithTestCosigners has the first threshold cosigners sign signBytes for testChainID,
+// exchanging nonces like the leader does. It returns the first error of a cosigner.
+func signWithTestCosigners(t *testing.T, cosigners []*LocalCosigner, threshold int, signBytes []byte) error {
+	ctx := context.Background()
+
+	u, err := uuid.NewRandom()
+	require.NoError(t, err)
+
+	nonces := make([][]CosignerNonce, threshold)
+	for i, cosigner := range cosigners[:threshold] {
+		res, err := cosigner.GetNonces(ctx, []uuid.UUID{u})
+		require.NoError(t, err)
+		nonces[i] = res[0].Nonces
+	}
+
+	for i, cosigner := range cosigners[:threshold] {
+		var cosignerNonces []CosignerNonce
+		for j, nonce := range nonces {
+			if i == j {
+				continue
+			}
+			for _, n := range nonce {
+				if n.DestinationID == cosigner.GetID() {
+					cosignerNonces = append(cosignerNonces, n)
+				}
+			}
+		}
+
+		if _, err := cosigner.SetNoncesAndSign(ctx, CosignerSetNoncesAndSignRequest{
+			Nonces: &CosignerUUIDNonces{
+				UUID:   u,
+				Nonces: cosignerNonces,
+			},
+			ChainID:   testChainID,
+			SignBytes: signBytes,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testCosignerSignStates loads the sign state of testChainID of each cosigner.
+func testCosignerSignStates(t *testing.T, cosigners []*LocalCosigner) []*SignState {
+	signStates := make([]*SignState, len(cosigners))
+	for i, cosigner := range cosigners {
+		require.NoError(t, cosigner.LoadSignStateIfNecessary(testChainID))
+		t.Cleanup(cosigner.waitForSignStatesToFlushToDisk)
+		ccs, err := cosigner.getChainState(testChainID)
+		require.NoError(t, err)
+		signStates[i] = ccs.lastSignState
+	}
+	return signStates
+}
+
+func TestLocalCosignerApprovalsAtHeight(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	cosigners, _ := getTestLocalCosigners(t, 2, 3)
+	signStates := testCosignerSignStates(t, cosigners)
+
+	// Each signed request is approved once, although it is checked again before signing
+	require.NoError(t, signWithTestCosigners(t, cosigners, 2, createTestSignBytes(blockHash, stepPrevote)))
+	require.Equal(t, 1, signStates[0].ApprovalsAtHeight(100))
+	require.Equal(t, 1, signStates[1].ApprovalsAtHeight(100))
+	require.Zero(t, signStates[2].ApprovalsAtHeight(100))
+
+	require.NoError(t, signWithTestCosigners(t, cosigners, 2, createTestSignBytes(blockHash, stepPrecommit)))
+	require.Equal(t, 2, signStates[0].ApprovalsAtHeight(100))
+	require.Equal(t, 2, signStates[1].ApprovalsAtHeight(100))
+}
+
+func TestLocalCosignerDuplicateSignRequests(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	cosigners, _ := getTestLocalCosigners(t, 2, 3)
+	testCosignerSignStates(t, cosigners)
+	signBytes := createTestSignBytes(blockHash, stepPrevote)
+
+	// Checking a request again before signing does not make it a duplicate
+	duplicatesBefore := testutil.ToFloat64(duplicateSignRequests)
+	require.NoError(t, signWithTestCosigners(t, cosigners, 2, signBytes))
+	require.Equal(t, duplicatesBefore, testutil.ToFloat64(duplicateSignRequests))
+
+	// Repeating it does, once per cosigner
+	require.NoError(t, signWithTestCosigners(t, cosigners, 2, signBytes))
+	require.Equal(t, duplicatesBefore+2, testutil.ToFloat64(duplicateSignRequests))
+}
+
+func TestLocalCosignerShadowModeReportsOnce(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	cosigners, _ := getTestLocalCosigners(t, 2, 3)
+	signStates := testCosignerSignStates(t, cosigners)
+
+	var reported []error
+	for _, signState := range signStates {
+		signState.ConsensusLock = ConsensusLock{Height: 100, Round: 0, Value: lockedValue}
+		signState.ShadowMode = true
+		signState.OnViolation = func(err error, _ HRSKey, _ []byte) {
+			reported = append(reported, err)
+		}
+	}
+
+	// A would-be violation is signed, and reported and counted once per cosigner
+	violationsBefore := testutil.ToFloat64(consensusLockViolations)
+	require.NoError(t, signWithTestCosigners(t, cosigners, 2, createTestSignBytes(differentValue, stepPrevote)))
+	require.Len(t, reported, 2)
+	require.True(t, IsConsensusLockViolationError(reported[0]))
+	require.Equal(t, violationsBefore+2, testutil.ToFloat64(consensusLockViolations))
+	require.Equal(t, 1, signStates[0].violations.get(100))
+}
@@ -279,6 +279,32 @@ var (
 		Help: "Total Times Cosigners doesn't reach threshold",
 	})
 
+	consensusLockAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "horcrux_consensus_lock_age_seconds",
+		Help: "Seconds since the consensus lock was last updated, observed on each lock decision (0 when unlocked)",
+	})
+	consensusLockViolations = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "horcrux_consensus_lock_violations_total",
+		Help: "Sign requests rejected because they violate the consensus lock or would double sign",
+	})
+	canaryOverrides = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "horcrux_consensus_lock_canary_overrides_total",
+		Help: "Sign requests allowed in canary mode that enforcing the consensus lock would have refused",
+	})
+	signBytesDecodeErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "horcrux_sign_bytes_decode_errors_total",
+		Help: "Sign requests rejected because their sign bytes could not be decoded",
+	})
+	duplicateSignRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "horcrux_duplicate_sign_requests_total",
+		Help: "Approved sign requests identical in HRS and value to the immediately prior approved request",
+	})
+	signBytesDecodeSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "horcrux_sign_bytes_decode_seconds",
+		Help:    "Seconds taken to decode canonical proposal and vote sign bytes",
+		Buckets: prometheus.ExponentialBuckets(1e-6, 4, 10),
+	})
+
 	timedSignBlockThresholdLag = promauto.NewSummary(prometheus.SummaryOpts{
 		Name:       "signer_sign_block_threshold_lag_seconds",
 		Help:       "Seconds taken to get threshold of cosigners available",
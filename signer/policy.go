@@ -0,0 +1,131 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Policy decides whether a sign request may be signed.
+type Policy interface {
+	// Check returns an error if signing signBytes at hrs must be refused.
+	Check(ctx context.Context, hrs HRSKey, signBytes []byte) error
+}
+
+// PolicyFunc adapts a function to a Policy.
+type PolicyFunc func(ctx context.Context, hrs HRSKey, signBytes []byte) error
+
+// Check calls f.
+func (f PolicyFunc) Check(ctx context.Context, hrs HRSKey, signBytes []byte) error {
+	return f(ctx, hrs, signBytes)
+}
+
+// PolicyChain runs policies in order and stops at the first that refuses.
+type PolicyChain []Policy
+
+// Check runs each policy in order, returning the first error.
+func (c PolicyChain) Check(ctx context.Context, hrs HRSKey, signBytes []byte) error {
+	for _, policy := range c {
+		if err := policy.Check(ctx, hrs, signBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type polRoundKey struct{}
+
+// WithPolRound returns a context carrying the POL round of the request, for use by ConsensusLockPolicy.
+func WithPolRound(ctx context.Context, polRound int64) context.Context {
+	return context.WithValue(ctx, polRoundKey{}, polRound)
+}
+
+// polRoundFromContext returns the POL round set with WithPolRound, or -2 if it is unknown.
+func polRoundFromContext(ctx context.Context) int64 {
+	if polRound, ok := ctx.Value(polRoundKey{}).(int64); ok {
+		return polRound
+	}
+	return -2
+}
+
+type proposerKey struct{}
+
+// WithProposer returns a context carrying the address of the validator signing the request,
+// for use by ConsensusLockPolicy.
+func WithProposer(ctx context.Context, address []byte) context.Context {
+	return context.WithValue(ctx, proposerKey{}, address)
+}
+
+// proposerFromContext returns the address set with WithProposer, or nil if it is unknown.
+func proposerFromContext(ctx context.Context) []byte {
+	address, _ := ctx.Value(proposerKey{}).([]byte)
+	return address
+}
+
+// ConsensusLockPolicy enforces the consensus lock of a SignState. The POL round and
+// proposer of a request are taken from the context (see WithPolRound and WithProposer).
+type ConsensusLockPolicy struct {
+	State *SignState
+}
+
+// Check validates the request against the consensus lock.
+func (p ConsensusLockPolicy) Check(ctx context.Context, hrs HRSKey, signBytes []byte) error {
+	return p.State.ValidateSignRequest(SignRequest{
+		HRS:       hrs,
+		SignBytes: signBytes,
+		PolRound:  polRoundFromContext(ctx),
+		Proposer:  proposerFromContext(ctx),
+	}, WithContext(ctx))
+}
+
+// HRSMonotonicityPolicy refuses requests that regress the HRS of a SignState.
+type HRSMonotonicityPolicy struct {
+	State *SignState
+}
+
+// Check returns an error if hrs is below the last signed HRS. The same HRS passes, as it
+// may be answered with the existing signature.
+func (p HRSMonotonicityPolicy) Check(_ context.Context, hrs HRSKey, _ []byte) error {
+	p.State.mu.RLock()
+	defer p.State.mu.RUnlock()
+	return p.State.errorIfRegression(hrs)
+}
+
+// signingPolicy returns the policies a sign request for the chain of signState must pass
+// before it is signed. HRS regressions are not among them: they are checked after the
+// signature cache, so that a repeated request is answered with the existing signature.
+func signingPolicy(signState *SignState) PolicyChain {
+	return PolicyChain{
+		ConsensusLockPolicy{State: signState},
+	}
+}
+
+// ChainIDPolicy refuses requests whose sign bytes are for a chain not in Allowed.
+type ChainIDPolicy struct {
+	Allowed []string
+}
+
+// ChainIDNotAllowedError is returned by ChainIDPolicy for sign bytes of a chain not in its allow-list.
+type ChainIDNotAllowedError struct {
+	ChainID string
+}
+
+func (e *ChainIDNotAllowedError) Error() string {
+	return fmt.Sprintf("chain ID %q is not allowed", e.ChainID)
+}
+
+func newChainIDNotAllowedError(chainID string) *ChainIDNotAllowedError {
+	return &ChainIDNotAllowedError{ChainID: chainID}
+}
+
+// Check decodes the chain ID from signBytes and checks it against the allow-list.
+func (p ChainIDPolicy) Check(_ context.Context, hrs HRSKey, signBytes []byte) error {
+	decoded, err := decodeCanonical(signBytes, hrs.Step)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(p.Allowed, decoded.chainID) {
+		return newChainIDNotAllowedError(decoded.chainID)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package signer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cometbft/cometbft/libs/protoio"
+	cometproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyChain(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 5, Value: lockedValue},
+	}
+
+	prevote := func(chainID string, hash []byte) []byte {
+		signBytes, err := protoio.MarshalDelimited(&cometproto.CanonicalVote{
+			Type:    cometproto.PrevoteType,
+			Height:  100,
+			Round:   6,
+			ChainID: chainID,
+			BlockID: &cometproto.CanonicalBlockID{Hash: hash},
+		})
+		require.NoError(t, err)
+		return signBytes
+	}
+
+	lockPolicyRan := false
+	chain := PolicyChain{
+		ChainIDPolicy{Allowed: []string{"horcrux-test"}},
+		HRSMonotonicityPolicy{State: signState},
+		PolicyFunc(func(ctx context.Context, hrs HRSKey, signBytes []byte) error {
+			lockPolicyRan = true
+			return ConsensusLockPolicy{State: signState}.Check(ctx, hrs, signBytes)
+		}),
+	}
+
+	ctx := WithPolRound(context.Background(), -1)
+	hrs := HRSKey{Height: 100, Round: 6, Step: stepPrevote}
+
+	// The chain ID policy rejects before the lock policy runs
+	err := chain.Check(ctx, hrs, prevote("other-chain", differentValue))
+	require.True(t, IsChainIDNotAllowedError(err))
+	require.False(t, lockPolicyRan)
+
+	// An allowed chain reaches the lock policy, which rejects a different value
+	err = chain.Check(ctx, hrs, prevote("horcrux-test", differentValue))
+	require.True(t, IsConsensusLockViolationError(err))
+	require.True(t, lockPolicyRan)
+
+	// Without a POL round in the context, the lock policy treats it as unknown
+	require.NoError(t, chain.Check(context.Background(), hrs, prevote("horcrux-test", differentValue)))
+
+	// All policies pass for the locked value
+	require.NoError(t, chain.Check(ctx, hrs, prevote("horcrux-test", lockedValue)))
+
+	// HRS regressions are refused
+	signState.Height, signState.Round, signState.Step = 100, 7, stepPrevote
+	var regressionErr *RoundRegressionError
+	require.ErrorAs(t, chain.Check(ctx, hrs, prevote("horcrux-test", lockedValue)), &regressionErr)
+
+	// The same HRS passes without inspecting what was signed at it
+	signState.Height, signState.Round, signState.Step = 100, 6, stepPrevote
+	signState.SignBytes, signState.Signature = prevote("horcrux-test", lockedValue), nil
+	require.NotPanics(t, func() {
+		require.NoError(t, HRSMonotonicityPolicy{State: signState}.Check(ctx, hrs, nil))
+	})
+}
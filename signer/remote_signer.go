@@ -278,13 +278,7 @@ func (rs *ReconnRemoteSigner) handlePingRequest() cometprotoprivval.Message {
 }
 
 func getRemoteSignerError(err error) *cometprotoprivval.RemoteSignerError {
-	if err == nil {
-		return nil
-	}
-	return &cometprotoprivval.RemoteSignerError{
-		Code:        0,
-		Description: err.Error(),
-	}
+	return LockErrorToRemoteSignerError(err)
 }
 
 func StartRemoteSigners(
@@ -2,11 +2,13 @@ package signer
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
 	cometbytes "github.com/cometbft/cometbft/libs/bytes"
 	cometjson "github.com/cometbft/cometbft/libs/json"
@@ -25,39 +27,34 @@ const (
 	blocksToCache      = 3
 )
 
+// Exported step constants, for callers building HRSKeys.
+const (
+	StepPropose   = stepPropose
+	StepPrevote   = stepPrevote
+	StepPrecommit = stepPrecommit
+)
+
 func signType(step int8) string {
-	switch step {
-	case stepPropose:
-		return "proposal"
-	case stepPrevote:
-		return "prevote"
-	case stepPrecommit:
-		return "precommit"
-	default:
-		return "unknown"
-	}
+	return StepEncodingCometBFT.StepName(step)
 }
 
 func CanonicalVoteToStep(vote *cometproto.CanonicalVote) int8 {
-	switch vote.Type {
-	case cometproto.PrevoteType:
-		return stepPrevote
-	case cometproto.PrecommitType:
-		return stepPrecommit
-	default:
-		panic("Unknown vote type")
-	}
+	return voteTypeToStep(vote.Type)
 }
 
 func VoteToStep(vote *cometproto.Vote) int8 {
-	switch vote.Type {
-	case cometproto.PrevoteType:
-		return stepPrevote
-	case cometproto.PrecommitType:
-		return stepPrecommit
-	default:
-		panic("Unknown vote type")
+	return voteTypeToStep(vote.Type)
+}
+
+// voteTypeToStep returns the step registered for the vote type t. It panics if t is not a
+// vote type, or no step is registered for it.
+func voteTypeToStep(t cometproto.SignedMsgType) int8 {
+	if comet.IsVoteTypeValid(t) {
+		if step, ok := msgTypeToStep(t); ok {
+			return step
+		}
 	}
+	panic("Unknown vote type")
 }
 
 func VoteToBlock(chainID string, vote *cometproto.Vote) Block {
@@ -88,23 +85,23 @@ func ProposalToBlock(chainID string, proposal *cometproto.Proposal) Block {
 }
 
 func StepToType(step int8) cometproto.SignedMsgType {
-	switch step {
-	case stepPropose:
-		return cometproto.ProposalType
-	case stepPrevote:
-		return cometproto.PrevoteType
-	case stepPrecommit:
-		return cometproto.PrecommitType
-	default:
-		panic("Unknown step")
+	if semantics, ok := stepRegistry[step]; ok && semantics.msgType != cometproto.UnknownType {
+		return semantics.msgType
 	}
+	panic("Unknown step")
 }
 
 // ConsensusLock represents a Tendermint consensus lock on a specific value
 type ConsensusLock struct {
-	Height int64  `json:"height"`
-	Round  int64  `json:"round"`           // The round where we locked on this value (lockedRound)
-	Value  []byte `json:"value,omitempty"` // The value we're locked on (lockedValue)
+	Height    int64     `json:"height"`
+	Round     int64     `json:"round"`            // The round where we locked on this value (lockedRound)
+	Value     []byte    `json:"value,omitempty"`  // The value we're locked on (lockedValue)
+	UpdatedAt time.Time `json:"updated_at"`       // When the lock was last set or moved
+	SetBy     HRSKey    `json:"set_by"`           // The precommit that last set or moved the lock
+	Pinned    bool      `json:"pinned,omitempty"` // Manual safeguard: ClearConsensusLock refuses to clear the lock
+
+	// PartSetHeader is the part set header hash of the locked block, tracked with TrackPartSetHeader
+	PartSetHeader []byte `json:"part_set_header,omitempty"`
 }
 
 // MarshalJSON implements custom JSON marshaling for ConsensusLock
@@ -135,6 +132,22 @@ func (lock *ConsensusLock) IsLocked() bool {
 	return lock.Height >= 0 && lock.Round >= 0 && lock.Value != nil
 }
 
+// HRSKey returns the height, round and step at which the lock was taken.
+// Locks are only ever taken by precommits.
+func (lock *ConsensusLock) HRSKey() HRSKey {
+	return HRSKey{Height: lock.Height, Round: lock.Round, Step: stepPrecommit}
+}
+
+// SameValue returns true if both locks are on the same value at the same height,
+// regardless of the round they were taken in. The values are compared in constant
+// time. An unlocked lock has no value, so it never shares one.
+func (lock ConsensusLock) SameValue(other ConsensusLock) bool {
+	if !lock.IsLocked() || !other.IsLocked() {
+		return false
+	}
+	return lock.Height == other.Height && lockValuesEqual(lock.Value, other.Value)
+}
+
 // SignState stores signing information for high level watermark management.
 type SignState struct {
 	Height                 int64               `json:"height"`
@@ -148,20 +161,86 @@ type SignState struct {
 	// Consensus lock tracking to prevent amnesia faults
 	ConsensusLock ConsensusLock `json:"consensus_lock,omitzero"`
 
+	// Halted refuses every sign request after a double sign was detected, until Unhalt is called
+	Halted bool `json:"halted,omitempty"`
+
+	// HighestHRS is the highest HRS ever signed, tracked with GlobalMonotonicHRS. Unlike the
+	// signed HRS it never goes backwards, e.g. on RollbackTo. It is zero, and not written,
+	// when not tracked: cometjson omits zero structs for omitempty, encoding/json for omitzero.
+	HighestHRS HRSKey `json:"highest_hrs,omitempty,omitzero"`
+
+	// HeightLocks and HeightSigned are the locked heights and the HRS last signed at each
+	// height tracked in MultiHeight mode, oldest first, as persisted. They are only set while
+	// encoding and decoding; in memory the per-height state is kept in heightLocks and
+	// heightSigned. Both are empty, and not written, outside of MultiHeight mode.
+	HeightLocks  []ConsensusLock `json:"height_locks,omitempty"`
+	HeightSigned []HRSKey        `json:"height_signed,omitempty"`
+
+	// Optional consensus lock checks. Not persisted.
+	ConsensusLockOptions `json:"-"`
+
+	// lastRound is the highest round signed at lastRoundHeight, used to reject
+	// round regressions within a height. A zero lastRoundHeight means untracked.
+	lastRoundHeight int64
+	lastRound       int64
+
+	// heightLocks holds the lock of each recent height in MultiHeight mode, and heightSigned
+	// the HRS last signed at each. Persisted through HeightLocks and HeightSigned.
+	heightLocks  map[int64]ConsensusLock
+	heightSigned map[int64]HRSKey
+
+	// The trackers below are updated while validating sign requests, which only holds mu
+	// read locked, so each guards itself with its own mutex instead.
+
+	// approvals counts approved requests per recent height. Not persisted.
+	approvals approvalCounter
+
+	// violations counts blocked consensus lock violations per recent height. Not persisted.
+	violations approvalCounter
+
+	// timestamps holds the latest timestamp approved at the current height for MonotonicTimestamps. Not persisted.
+	timestamps timestampTracker
+
+	// longLockWarning rate limits the warning for locks held for many rounds. Not persisted.
+	longLockWarning longLockWarning
+
+	// transitions holds the most recent lock transitions made by signing. It is carried by
+	// Clone and encoded by MarshalBinary and Snapshot, but not persisted to the JSON file.
+	transitions []lockTransition
+
+	// lockSaver persists lock changes when debounced saving is enabled.
+	lockSaver *debouncedLockSaver
+
+	// lockSignatures holds the signatures saved for each HRSKey. Not persisted.
+	lockSignatures sync.Map
+
 	filePath string
 
 	// mu protects the cache and is used for signaling with cond.
-	mu    sync.RWMutex
+	mu    signStateMutex
 	cache map[HRSKey]SignStateConsensus
 	cond  *cond.Cond
 }
 
 func (signState *SignState) existingSignatureOrErrorIfRegression(hrst HRSTKey, signBytes []byte) ([]byte, error) {
+	signature, err := signState.existingSignatureOrError(hrst, signBytes)
+	if haltErr := signState.haltOnDoubleSign(err, hrst.HRSKey()); haltErr != nil {
+		err = errors.Join(err, haltErr)
+	}
+	return signature, err
+}
+
+func (signState *SignState) existingSignatureOrError(hrst HRSTKey, signBytes []byte) ([]byte, error) {
 	signState.mu.RLock()
 	defer signState.mu.RUnlock()
 
-	// First check for consensus lock violations
-	if err := signState.ValidateConsensusLock(hrst.HRSKey(), signBytes, -2); err != nil {
+	// First check for consensus lock violations. The request was validated, and the decision
+	// recorded, before signing started, so it is only checked again here, without side effects.
+	if err := signState.lockedPermitConsensusLock(SignRequest{
+		HRS:       hrst.HRSKey(),
+		SignBytes: signBytes,
+		PolRound:  -2,
+	}); err != nil {
 		return nil, err
 	}
 
@@ -180,6 +259,7 @@ func (signState *SignState) existingSignatureOrErrorIfRegression(hrst HRSTKey, s
 	if bytes.Equal(signBytes, signState.SignBytes) {
 		return signState.Signature, nil
 	} else if err := signState.OnlyDifferByTimestamp(signBytes); err != nil {
+		signState.reportViolation(err, hrst.HRSKey(), signBytes)
 		return nil, err
 	}
 
@@ -203,6 +283,10 @@ type SignStateConsensus struct {
 	VoteExtensionSignature []byte
 	SignBytes              cometbytes.HexBytes
 	ConsensusLock          ConsensusLock
+
+	// VoteExtension is the vote extension signed alongside a precommit, if any.
+	// Only used when locking on extension data (IncludeExtensionInValue).
+	VoteExtension []byte `json:",omitempty"`
 }
 
 func (signState SignStateConsensus) HRSKey() HRSKey {
@@ -244,6 +328,16 @@ func newConflictingDataError(existingSignBytes, newSignBytes []byte) *Conflictin
 	}
 }
 
+// IsDoubleSignError checks if the error reports a request to sign conflicting data at an already signed HRS.
+func IsDoubleSignError(err error) bool {
+	var (
+		conflictingErr   *ConflictingDataError
+		diffBlockIDsErr  *DiffBlockIDsError
+		alreadySignedErr *AlreadySignedVoteError
+	)
+	return errors.As(err, &conflictingErr) || errors.As(err, &diffBlockIDsErr) || errors.As(err, &alreadySignedErr)
+}
+
 // GetFromCache will return the latest signed block within the SignState
 // and the relevant SignStateConsensus from the cache, if present.
 func (signState *SignState) GetFromCache(hrs HRSKey) (HRSKey, *SignStateConsensus) {
@@ -256,37 +350,208 @@ func (signState *SignState) GetFromCache(hrs HRSKey) (HRSKey, *SignStateConsensu
 	return latestBlock, nil
 }
 
+// AlreadySigned returns true if hrs is at or before the last signed HRS, meaning
+// it has already been handled and signing it again would need an existing signature.
+func (signState *SignState) AlreadySigned(hrs HRSKey) bool {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+	return !hrs.GreaterThan(signState.lockedHrsKey())
+}
+
 // blockDoubleSign will prevent double signing by checking the HRS against the current SignState.
 // It must only return nil error if the HRS is greater than the current SignState
 // so that we only sign atomically and incrementally.
-// Returns a copy of the SignState in the case of a successful update that will be persisted to disk.
-func (signState *SignState) blockDoubleSign(ssc SignStateConsensus) (*SignState, error) {
+// Returns a copy of the SignState in the case of a successful update that will be persisted to disk,
+// and its encoding. The update is only applied once it was encoded, so that an encoding error,
+// such as a *SerializationMismatchError, leaves the SignState unchanged.
+func (signState *SignState) blockDoubleSign(ssc SignStateConsensus) (*SignState, []byte, error) {
 	signState.mu.Lock()
 	defer signState.mu.Unlock()
-	if err := signState.lockedGetErrorIfLessOrEqual(ssc.Height, ssc.Round, ssc.Step); err != nil {
-		return nil, err
+	if err := signState.lockedCheckSignedHRS(ssc.HRSKey()); err != nil {
+		return nil, nil, err
+	}
+
+	// Encode the state we are moving to before committing to it
+	nextLock, moved := signState.lockedNextLock(ssc.HRSKey(), ssc.SignBytes, ssc.VoteExtension)
+	signStateCopy := signState.lockedCopy()
+	signStateCopy.lockedSetSigned(ssc)
+	if moved {
+		signStateCopy.lockedSetLock(ssc.Height, nextLock)
+	}
+	jsonBytes, err := encodeSignState(signStateCopy)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// HRS is greater than existing state, move forward with caching and saving.
-	signState.cache[ssc.HRSKey()] = ssc
+	signState.lockedCacheSigned(ssc)
+	signState.lockedSetSigned(ssc)
+
+	// Handle consensus lock updates according to Tendermint rules
+	if moved {
+		signState.lockedCommitLock(ssc.HRSKey(), nextLock)
+	}
+
+	if len(ssc.Signature) > 0 {
+		signState.recordLockSignature(ssc.HRSKey(), ssc.Signature)
+	}
 
+	return signStateCopy, jsonBytes, nil
+}
+
+// lockedCached returns true if a signature for hrs is cached. Not thread-safe (requires external lock).
+func (signState *SignState) lockedCached(hrs HRSKey) bool {
+	_, ok := signState.cache[hrs]
+	return ok
+}
+
+// lockedCacheSigned caches ssc and forgets heights that have fallen out of the cache window.
+// Not thread-safe (requires external lock).
+func (signState *SignState) lockedCacheSigned(ssc SignStateConsensus) {
+	signState.cache[ssc.HRSKey()] = ssc
 	for hrs := range signState.cache {
 		if hrs.Height < ssc.Height-blocksToCache {
 			delete(signState.cache, hrs)
 		}
 	}
+}
 
+// lockedSetSigned moves the signed HRS to that of ssc. Not thread-safe (requires external lock).
+func (signState *SignState) lockedSetSigned(ssc SignStateConsensus) {
+	if signState.GlobalMonotonicHRS && ssc.HRSKey().GreaterThan(signState.HighestHRS) {
+		signState.HighestHRS = ssc.HRSKey()
+	}
+	if signState.MultiHeight {
+		signState.lockedTrackSigned(ssc.HRSKey())
+		// A pipelined height below the highest signed one leaves the signed HRS alone
+		if ssc.Height < signState.Height {
+			return
+		}
+	}
 	signState.Height = ssc.Height
 	signState.Round = ssc.Round
 	signState.Step = ssc.Step
 	signState.Signature = ssc.Signature
 	signState.SignBytes = ssc.SignBytes
 	signState.VoteExtensionSignature = ssc.VoteExtensionSignature
+	signState.lastRoundHeight = ssc.Height
+	signState.lastRound = ssc.Round
+}
 
-	// Handle consensus lock updates according to Tendermint rules
-	signState.ConsensusLock = nextConsensusLock(signState.ConsensusLock, ssc.HRSKey(), ssc.SignBytes)
+// AdvanceConsensusLock applies a signed request at hrs to the consensus lock and
+// returns the resulting lock. Save does this automatically for every signature.
+// Requests that violate the lock, such as a precommit for a different value in
+// the lock round, are rejected and leave the lock unchanged.
+func (signState *SignState) AdvanceConsensusLock(hrs HRSKey, signBytes []byte) (ConsensusLock, error) {
+	signState.mu.Lock()
+	defer signState.mu.Unlock()
+	if err := signState.lockedCheckConsensusLock(SignRequest{
+		HRS:       hrs,
+		SignBytes: signBytes,
+		PolRound:  -2,
+	}); err != nil {
+		return signState.lockedLockFor(hrs.Height), err
+	}
+	if err := signState.checkReleasingValue(hrs, signBytes); err != nil {
+		return signState.lockedLockFor(hrs.Height), err
+	}
+	signState.lockedAdvanceConsensusLock(hrs, signBytes, nil)
+	return signState.lockedLockFor(hrs.Height), nil
+}
+
+// ValidateAndAdvance validates a request at hrs against the consensus lock like
+// ValidateConsensusLock and, if it is an approved precommit, advances the lock like
+// AdvanceConsensusLock, all under a single acquisition of the SignState lock so that
+// concurrent callers never observe or act on an intermediate state. It returns the
+// resulting lock.
+func (signState *SignState) ValidateAndAdvance(hrs HRSKey, signBytes []byte) (ConsensusLock, error) {
+	signState.mu.Lock()
+	defer signState.mu.Unlock()
+	// In CanaryMode a request that would be refused is allowed, but does not advance the lock
+	if err := signState.lockedDecideConsensusLock(SignRequest{
+		HRS:       hrs,
+		SignBytes: signBytes,
+		PolRound:  -2,
+	}); err != nil {
+		return signState.lockedLockFor(hrs.Height), signState.canaryOverride(err)
+	}
+	if signState.lockSemantics(hrs.Step) != lockReleasing {
+		return signState.lockedLockFor(hrs.Height), nil
+	}
+	if err := signState.checkReleasingValue(hrs, signBytes); err != nil {
+		return signState.lockedLockFor(hrs.Height), err
+	}
+	signState.lockedAdvanceConsensusLock(hrs, signBytes, nil)
+	return signState.lockedLockFor(hrs.Height), nil
+}
+
+// CanonicalValue returns the value the consensus lock compares and stores for sign bytes
+// of step, after extraction and any normalization such as IncludeExtensionInValue (with an
+// empty extension, as AdvanceConsensusLock uses). The value of a vote for nil is empty.
+func (signState *SignState) CanonicalValue(step int8, signBytes []byte) ([]byte, error) {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+
+	if len(signBytes) > signState.maxSignBytesLen() {
+		return nil, newOversizedSignBytesError(len(signBytes), signState.maxSignBytesLen())
+	}
+	blockHash, err := signState.extractValue(step, signBytes)
+	if err == nil && len(blockHash) > 0 {
+		err = signState.checkLockableValue(step, blockHash)
+	}
+	if err != nil {
+		return nil, newBlockHashExtractionError(step, err)
+	}
+	return signState.lockValue(blockHash, nil), nil
+}
+
+// PeekNextLock returns the lock that AdvanceConsensusLock would produce for a
+// signed request at hrs, without changing the SignState.
+func (signState *SignState) PeekNextLock(hrs HRSKey, signBytes []byte) (ConsensusLock, error) {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+	if err := signState.lockedCheckConsensusLock(SignRequest{
+		HRS:       hrs,
+		SignBytes: signBytes,
+		PolRound:  -2,
+	}); err != nil {
+		return signState.lockedLockFor(hrs.Height), err
+	}
+	if err := signState.checkReleasingValue(hrs, signBytes); err != nil {
+		return signState.lockedLockFor(hrs.Height), err
+	}
+	next, _ := signState.lockedNextLock(hrs, signBytes, nil)
+	return next, nil
+}
+
+// lockedAdvanceConsensusLock applies a signed request to the consensus lock,
+// timestamping the lock if it was set or moved. Not thread-safe (requires external lock).
+func (signState *SignState) lockedAdvanceConsensusLock(hrs HRSKey, signBytes []byte, extension []byte) {
+	if nextLock, moved := signState.lockedNextLock(hrs, signBytes, extension); moved {
+		signState.lockedCommitLock(hrs, nextLock)
+	}
+}
+
+// lockedCommitLock moves the lock to nextLock, as returned by lockedNextLock for signing at hrs.
+// Not thread-safe (requires external lock).
+func (signState *SignState) lockedCommitLock(hrs HRSKey, nextLock ConsensusLock) {
+	signState.lockedRecordTransition(hrs, signState.lockedLockFor(hrs.Height), nextLock)
+	signState.lockedSetLock(hrs.Height, nextLock)
+	signState.lockedLockChanged()
+}
 
-	return signState.lockedCopy(), nil
+// lockedNextLock returns the lock that applies at hrs.Height after signing the request,
+// and whether it differs from the current one. Not thread-safe (requires external lock).
+func (signState *SignState) lockedNextLock(hrs HRSKey, signBytes []byte, extension []byte) (ConsensusLock, bool) {
+	lock := signState.lockedLockFor(hrs.Height)
+	nextLock := signState.ConsensusLockOptions.nextConsensusLock(lock, hrs, signBytes, extension)
+	if !lockMoved(lock, nextLock) {
+		return lock, false
+	}
+	if nextLock.IsLocked() {
+		nextLock.UpdatedAt = signState.now()
+	}
+	return nextLock, true
 }
 
 // Save updates the high watermark height/round/step (HRS) if it is greater
@@ -297,7 +562,7 @@ func (signState *SignState) Save(
 	ssc SignStateConsensus,
 	pendingDiskWG *sync.WaitGroup,
 ) error {
-	signStateCopy, err := signState.blockDoubleSign(ssc)
+	signStateCopy, jsonBytes, err := signState.blockDoubleSign(ssc)
 	if err != nil {
 		return err
 	}
@@ -310,28 +575,27 @@ func (signState *SignState) Save(
 		pendingDiskWG.Add(1)
 		go func() {
 			defer pendingDiskWG.Done()
-			saveSignState(signStateCopy)
+			writeSignState(signStateCopy.filePath, jsonBytes)
 		}()
 	} else {
-		saveSignState(signStateCopy)
+		writeSignState(signStateCopy.filePath, jsonBytes)
 	}
 
 	return nil
 }
 
-// copy returns a deep copy of the SignState. Not thread-safe (requires external lock).
+// copy returns a deep copy of the SignState, without the cache and the state only tracked
+// in memory, such as approvals and lock transitions (see Clone). Not thread-safe (requires external lock).
 func (signState *SignState) lockedCopy() *SignState {
 	sig := make([]byte, len(signState.Signature))
 	noncePub := make([]byte, len(signState.NoncePublic))
 	signBz := make([]byte, len(signState.SignBytes))
 	voteExtSig := make([]byte, len(signState.VoteExtensionSignature))
-	lockValue := make([]byte, len(signState.ConsensusLock.Value))
 
 	copy(sig, signState.Signature)
 	copy(noncePub, signState.NoncePublic)
 	copy(signBz, signState.SignBytes)
 	copy(voteExtSig, signState.VoteExtensionSignature)
-	copy(lockValue, signState.ConsensusLock.Value)
 
 	return &SignState{
 		Height:                 signState.Height,
@@ -341,32 +605,137 @@ func (signState *SignState) lockedCopy() *SignState {
 		Signature:              sig,
 		SignBytes:              signBz,
 		VoteExtensionSignature: voteExtSig,
-		ConsensusLock: ConsensusLock{
-			Height: signState.ConsensusLock.Height,
-			Round:  signState.ConsensusLock.Round,
-			Value:  lockValue,
-		},
-		filePath: signState.filePath,
+		ConsensusLock:          copyConsensusLock(signState.ConsensusLock),
+		Halted:                 signState.Halted,
+		HighestHRS:             signState.HighestHRS,
+		ConsensusLockOptions:   signState.ConsensusLockOptions.clone(),
+		lastRoundHeight:        signState.lastRoundHeight,
+		lastRound:              signState.lastRound,
+		heightLocks:            signState.lockedCopyHeightLocks(),
+		heightSigned:           signState.lockedCopyHeightSigned(),
+		filePath:               signState.filePath,
+	}
+}
+
+// copyConsensusLock returns a deep copy of lock.
+func copyConsensusLock(lock ConsensusLock) ConsensusLock {
+	// A nil lock value means unlocked, so it must stay nil in the copy.
+	if lock.Value != nil {
+		lock.Value = append([]byte{}, lock.Value...)
+	}
+	lock.PartSetHeader = append([]byte(nil), lock.PartSetHeader...)
+	return lock
+}
+
+// Clone returns a deep copy of the SignState, including its cache and the approvals,
+// violations, timestamps, lock transitions and signatures tracked in memory, that is
+// safe to hand to other goroutines. Mutations to the clone are not visible to the
+// original and vice versa. The options are copied too, but function hooks and interfaces
+// such as OnViolation and ValueExtractor are shared. Debounced lock saving is not carried
+// over, so that the clone never writes to the original's LockStore.
+func (signState *SignState) Clone() *SignState {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+
+	clone := signState.lockedCopy()
+	clone.cache = make(map[HRSKey]SignStateConsensus, len(signState.cache))
+	for hrs, ssc := range signState.cache {
+		clone.cache[hrs] = ssc
 	}
+	clone.cond = cond.New(&clone.mu)
+
+	clone.approvals = signState.approvals.clone()
+	clone.violations = signState.violations.clone()
+	clone.timestamps = signState.timestamps.clone()
+	clone.longLockWarning = signState.longLockWarning.clone()
+	clone.transitions = signState.lockedCopyTransitions()
+	signState.lockSignatures.Range(func(hrs, sig any) bool {
+		clone.lockSignatures.Store(hrs, append([]byte(nil), sig.([]byte)...))
+		return true
+	})
+
+	return clone
 }
 
 // Save persists the FilePvLastSignState to its filePath.
 // IMPORTANT: This method is not thread-safe and should only be called with a copy of the SignState.
 func saveSignState(ss *SignState) {
-	jsonBytes, err := cometjson.MarshalIndent(ss, "", "  ")
+	if err := trySaveSignState(ss); err != nil {
+		panic(err)
+	}
+}
+
+// trySaveSignState is like saveSignState, but returns errors instead of panicking.
+// IMPORTANT: This method is not thread-safe and should only be called with a copy of the SignState.
+func trySaveSignState(ss *SignState) error {
+	jsonBytes, err := encodeSignState(ss)
 	if err != nil {
+		return err
+	}
+	return tryWriteSignState(ss.filePath, jsonBytes)
+}
+
+// signStateEncoder encodes a SignState for disk.
+var signStateEncoder = func(ss *SignState) ([]byte, error) {
+	return cometjson.MarshalIndent(ss, "", "  ")
+}
+
+// encodeSignState encodes ss for disk and verifies that the encoding decodes back to the
+// same consensus lock, so that corruption in memory or in the encoder is caught before it
+// is written. A mismatch is returned as a *SerializationMismatchError.
+// IMPORTANT: This method is not thread-safe and should only be called with a copy of the SignState.
+func encodeSignState(ss *SignState) ([]byte, error) {
+	ss.HeightLocks, ss.HeightSigned = ss.lockedPersistedHeights()
+	jsonBytes, err := signStateEncoder(ss)
+	if err != nil {
+		return nil, err
+	}
+	var decoded SignState
+	if err := cometjson.Unmarshal(jsonBytes, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode encoded sign state: %w", err)
+	}
+	// An unlocked lock is encoded as null, whatever its fields
+	unlocked := !ss.ConsensusLock.IsLocked() && !decoded.ConsensusLock.IsLocked()
+	if !unlocked && !sameStoredLock(ss.ConsensusLock, decoded.ConsensusLock) {
+		return nil, newSerializationMismatchError(ss.ConsensusLock, decoded.ConsensusLock)
+	}
+	return jsonBytes, nil
+}
+
+// writeSignState atomically writes an encoded SignState to outFile.
+func writeSignState(outFile string, jsonBytes []byte) {
+	if err := tryWriteSignState(outFile, jsonBytes); err != nil {
 		panic(err)
 	}
-	outFile := ss.filePath
+}
+
+// tryWriteSignState is like writeSignState, but returns errors instead of panicking.
+func tryWriteSignState(outFile string, jsonBytes []byte) error {
 	if outFile == os.DevNull {
-		return
+		return nil
 	}
 	if outFile == "" {
-		panic("cannot save SignState: filePath not set")
+		return errors.New("cannot save SignState: filePath not set")
 	}
+	return tempfile.WriteFileAtomic(outFile, jsonBytes, 0600)
+}
 
-	if err := tempfile.WriteFileAtomic(outFile, jsonBytes, 0600); err != nil {
-		panic(err)
+// SerializationMismatchError is returned when an encoded SignState does not decode back
+// to the consensus lock it was encoded from.
+type SerializationMismatchError struct {
+	Expected ConsensusLock
+	Decoded  ConsensusLock
+}
+
+func (e *SerializationMismatchError) Error() string {
+	return fmt.Sprintf("encoded sign state does not round trip: lock at %d/%d on %x decoded as %d/%d on %x",
+		e.Expected.Height, e.Expected.Round, e.Expected.Value, e.Decoded.Height, e.Decoded.Round, e.Decoded.Value)
+}
+
+func newSerializationMismatchError(expected, decoded ConsensusLock) *SerializationMismatchError {
+	return &SerializationMismatchError{
+		Expected: expected,
+		Decoded:  decoded,
 	}
 }
 
@@ -429,6 +798,24 @@ func newStepRegressionError(height, round int64, regressed, last int8) *StepRegr
 	}
 }
 
+// HRSNotIncreasingError is returned with GlobalMonotonicHRS for a request at or below the highest HRS ever signed.
+type HRSNotIncreasingError struct {
+	HRS     HRSKey
+	Highest HRSKey
+}
+
+func (e *HRSNotIncreasingError) Error() string {
+	return fmt.Sprintf("HRS %d/%d/%d is not above the highest ever signed %d/%d/%d",
+		e.HRS.Height, e.HRS.Round, e.HRS.Step, e.Highest.Height, e.Highest.Round, e.Highest.Step)
+}
+
+func newHRSNotIncreasingError(hrs, highest HRSKey) *HRSNotIncreasingError {
+	return &HRSNotIncreasingError{
+		HRS:     hrs,
+		Highest: highest,
+	}
+}
+
 var ErrEmptySignBytes = errors.New("no SignBytes found")
 
 // CheckHRS checks the given height, round, step (HRS) against that of the
@@ -438,32 +825,41 @@ var ErrEmptySignBytes = errors.New("no SignBytes found")
 // we have already signed for this HRS, and can reuse the existing signature).
 // It panics if the HRS matches the arguments, there's a SignBytes, but no Signature.
 func (signState *SignState) CheckHRS(hrst HRSTKey) (bool, error) {
-	if signState.Height > hrst.Height {
-		return false, newHeightRegressionError(hrst.Height, signState.Height)
+	if err := signState.errorIfRegression(hrst.HRSKey()); err != nil {
+		return false, err
 	}
 
-	if signState.Height == hrst.Height {
-		if signState.Round > hrst.Round {
-			return false, newRoundRegressionError(hrst.Height, hrst.Round, signState.Round)
-		}
-
-		if signState.Round == hrst.Round {
-			if signState.Step > hrst.Step {
-				return false, newStepRegressionError(hrst.Height, hrst.Round, hrst.Step, signState.Step)
-			} else if signState.Step == hrst.Step {
-				if signState.SignBytes != nil {
-					if signState.Signature == nil {
-						panic("pv: Signature is nil but SignBytes is not!")
-					}
-					return true, nil
-				}
-				return false, ErrEmptySignBytes
+	if signState.Height == hrst.Height && signState.Round == hrst.Round && signState.Step == hrst.Step {
+		if signState.SignBytes != nil {
+			if signState.Signature == nil {
+				panic("pv: Signature is nil but SignBytes is not!")
 			}
+			return true, nil
 		}
+		return false, ErrEmptySignBytes
 	}
 	return false, nil
 }
 
+// errorIfRegression returns a height, round or step regression error if hrs is below the
+// HRS last signed. Unlike CheckHRS it does not look at what was signed at the same HRS.
+func (signState *SignState) errorIfRegression(hrs HRSKey) error {
+	if signState.Height > hrs.Height {
+		return newHeightRegressionError(hrs.Height, signState.Height)
+	}
+
+	if signState.Height == hrs.Height {
+		if signState.Round > hrs.Round {
+			return newRoundRegressionError(hrs.Height, hrs.Round, signState.Round)
+		}
+
+		if signState.Round == hrs.Round && signState.Step > hrs.Step {
+			return newStepRegressionError(hrs.Height, hrs.Round, hrs.Step, signState.Step)
+		}
+	}
+	return nil
+}
+
 type SameHRSError struct {
 	Height int64
 	Round  int64
@@ -482,16 +878,15 @@ func newSameHRSError(hrs HRSKey) *SameHRSError {
 	}
 }
 
-func (signState *SignState) lockedGetErrorIfLessOrEqual(height int64, round int64, step int8) error {
-	hrs := HRSKey{Height: height, Round: round, Step: step}
-	signStateHRS := signState.lockedHrsKey()
-	if signStateHRS.GreaterThan(hrs) {
+// errorIfNotAbove returns an error unless hrs is greater than signed.
+func errorIfNotAbove(hrs HRSKey, signed HRSKey) error {
+	if signed.GreaterThan(hrs) {
 		return errors.New("regression not allowed")
 	}
 
-	if hrs == signStateHRS {
+	if hrs == signed {
 		// same HRS as current
-		return newSameHRSError(HRSKey{Height: height, Round: round, Step: step})
+		return newSameHRSError(hrs)
 	}
 	// Step is greater, so all good
 	return nil
@@ -509,6 +904,13 @@ func (signState *SignState) FreshCache() *SignState {
 		SignBytes:              signState.SignBytes,
 		VoteExtensionSignature: signState.VoteExtensionSignature,
 		ConsensusLock:          signState.ConsensusLock,
+		Halted:                 signState.Halted,
+		HighestHRS:             signState.HighestHRS,
+		ConsensusLockOptions:   signState.ConsensusLockOptions,
+		lastRoundHeight:        signState.Height,
+		lastRound:              signState.Round,
+		heightLocks:            signState.lockedCopyHeightLocks(),
+		heightSigned:           signState.lockedCopyHeightSigned(),
 		cache:                  make(map[HRSKey]SignStateConsensus),
 
 		filePath: signState.filePath,
@@ -548,6 +950,8 @@ func LoadSignState(filepath string) (*SignState, error) {
 	}
 
 	state.filePath = filepath
+	state.lockedRestoreHeights(state.HeightLocks, state.HeightSigned)
+	state.HeightLocks, state.HeightSigned = nil, nil
 
 	return state.FreshCache(), nil
 }
@@ -681,12 +1085,181 @@ func (e *ConsensusLockStepViolationError) Error() string {
 	return fmt.Sprintf("consensus lock step violation at %d:%d:%d", e.Height, e.Round, e.Step)
 }
 
+func newConsensusLockStepViolationError(hrs HRSKey) *ConsensusLockStepViolationError {
+	return &ConsensusLockStepViolationError{
+		Height: hrs.Height,
+		Round:  hrs.Round,
+		Step:   hrs.Step,
+	}
+}
+
+// ConsensusLockActiveError is returned when an operation requires that no consensus lock is active.
+type ConsensusLockActiveError struct {
+	HRS HRSKey
+}
+
+func (e *ConsensusLockActiveError) Error() string {
+	return fmt.Sprintf("consensus lock active at %d:%d:%d", e.HRS.Height, e.HRS.Round, e.HRS.Step)
+}
+
+func newConsensusLockActiveError(hrs HRSKey) *ConsensusLockActiveError {
+	return &ConsensusLockActiveError{HRS: hrs}
+}
+
+// UninitializedSignStateError is returned when FailClosedOnMissingState is set
+// and a vote is requested before any sign state exists.
+type UninitializedSignStateError struct {
+	HRS HRSKey
+}
+
+func (e *UninitializedSignStateError) Error() string {
+	return fmt.Sprintf("refusing to sign %d:%d:%d: sign state is uninitialized", e.HRS.Height, e.HRS.Round, e.HRS.Step)
+}
+
+func newUninitializedSignStateError(hrs HRSKey) *UninitializedSignStateError {
+	return &UninitializedSignStateError{HRS: hrs}
+}
+
+// TimestampSkewError is returned when the timestamp in sign bytes is too far from the local clock.
+type TimestampSkewError struct {
+	Timestamp time.Time
+	Now       time.Time
+	Tolerance time.Duration
+}
+
+func (e *TimestampSkewError) Error() string {
+	return fmt.Sprintf("sign bytes timestamp %s is more than %s from local time %s",
+		e.Timestamp.Format(time.RFC3339Nano), e.Tolerance, e.Now.Format(time.RFC3339Nano))
+}
+
+func newTimestampSkewError(timestamp, now time.Time, tolerance time.Duration) *TimestampSkewError {
+	return &TimestampSkewError{
+		Timestamp: timestamp,
+		Now:       now,
+		Tolerance: tolerance,
+	}
+}
+
+// NilSignStateError is returned when validating against a nil SignState.
+// Without state there is nothing to check against, so signing is refused.
+type NilSignStateError struct {
+	HRS HRSKey
+}
+
+func (e *NilSignStateError) Error() string {
+	return fmt.Sprintf("cannot validate %d:%d:%d against a nil sign state", e.HRS.Height, e.HRS.Round, e.HRS.Step)
+}
+
+func newNilSignStateError(hrs HRSKey) *NilSignStateError {
+	return &NilSignStateError{HRS: hrs}
+}
+
+// CrossStepConflictError is returned when a prevote conflicts with the proposal signed in the same round.
+type CrossStepConflictError struct {
+	HRS      HRSKey
+	Proposed []byte
+	Prevoted []byte
+}
+
+func (e *CrossStepConflictError) Error() string {
+	return fmt.Sprintf("prevote for %x at %d:%d conflicts with signed proposal for %x",
+		e.Prevoted, e.HRS.Height, e.HRS.Round, e.Proposed)
+}
+
+func newCrossStepConflictError(hrs HRSKey, proposed, prevoted []byte) *CrossStepConflictError {
+	return &CrossStepConflictError{
+		HRS:      hrs,
+		Proposed: proposed,
+		Prevoted: prevoted,
+	}
+}
+
+// ValidateTimeoutError is returned when validating a sign request takes longer than ValidateTimeout.
+type ValidateTimeoutError struct {
+	HRS     HRSKey
+	Timeout time.Duration
+}
+
+func (e *ValidateTimeoutError) Error() string {
+	return fmt.Sprintf("validation of %d/%d/%d did not finish within %s",
+		e.HRS.Height, e.HRS.Round, e.HRS.Step, e.Timeout)
+}
+
+func newValidateTimeoutError(hrs HRSKey, timeout time.Duration) *ValidateTimeoutError {
+	return &ValidateTimeoutError{HRS: hrs, Timeout: timeout}
+}
+
+// MissingRollbackProofError is returned when a rollback is attempted without a proof of the fork.
+type MissingRollbackProofError struct {
+	Height int64
+}
+
+func (e *MissingRollbackProofError) Error() string {
+	return fmt.Sprintf("refusing to roll back to height %d without a fork proof", e.Height)
+}
+
+func newMissingRollbackProofError(height int64) *MissingRollbackProofError {
+	return &MissingRollbackProofError{Height: height}
+}
+
+// PinnedLockError is returned when clearing a pinned consensus lock is attempted.
+type PinnedLockError struct {
+	Lock HRSKey
+	HRS  HRSKey
+}
+
+func (e *PinnedLockError) Error() string {
+	return fmt.Sprintf("refusing to clear pinned consensus lock at %d:%d for %d:%d:%d",
+		e.Lock.Height, e.Lock.Round, e.HRS.Height, e.HRS.Round, e.HRS.Step)
+}
+
+func newPinnedLockError(lock, hrs HRSKey) *PinnedLockError {
+	return &PinnedLockError{
+		Lock: lock,
+		HRS:  hrs,
+	}
+}
+
 // IsConsensusLockViolationError checks if the error is a consensus lock violation
 func IsConsensusLockViolationError(err error) bool {
 	var violationErr *ConsensusLockViolationError
 	return errors.As(err, &violationErr)
 }
 
+// OversizedSignBytesError is returned when sign bytes exceed the configured maximum length.
+type OversizedSignBytesError struct {
+	Len int
+	Max int
+}
+
+func (e *OversizedSignBytesError) Error() string {
+	return fmt.Sprintf("sign bytes too large: %d bytes exceeds maximum of %d", e.Len, e.Max)
+}
+
+func newOversizedSignBytesError(length, maxLen int) *OversizedSignBytesError {
+	return &OversizedSignBytesError{
+		Len: length,
+		Max: maxLen,
+	}
+}
+
+// RoundCeilingError is returned when a sign request is for a round above MaxRound.
+type RoundCeilingError struct {
+	HRS      HRSKey
+	MaxRound int32
+}
+
+func (e *RoundCeilingError) Error() string {
+	return fmt.Sprintf("round %d at height %d exceeds maximum round %d", e.HRS.Round, e.HRS.Height, e.MaxRound)
+}
+
+func newRoundCeilingError(hrs HRSKey, maxRound int32) *RoundCeilingError {
+	return &RoundCeilingError{
+		HRS:      hrs,
+		MaxRound: maxRound,
+	}
+}
+
 type BlockHashExtractionError struct {
 	step int8
 	err  error
@@ -696,6 +1269,10 @@ func (e *BlockHashExtractionError) Error() string {
 	return fmt.Sprintf("failed to extract block hash from sign bytes for step %d: %v", e.step, e.err)
 }
 
+func (e *BlockHashExtractionError) Unwrap() error {
+	return e.err
+}
+
 func newBlockHashExtractionError(step int8, err error) *BlockHashExtractionError {
 	return &BlockHashExtractionError{
 		step: step,
@@ -711,30 +1288,330 @@ func IsConsensusLockStepViolationError(err error) bool {
 
 // ValidateConsensusLock validates consensus lock using POL round from Tendermint
 // Tendermint sends POL round in the sign request
-func (signState *SignState) ValidateConsensusLock(hrs HRSKey, signBytes []byte, polRound int64) error {
+// Called on a nil SignState, it returns a *NilSignStateError rather than panicking.
+func (signState *SignState) ValidateConsensusLock(
+	hrs HRSKey, signBytes []byte, polRound int64, opts ...ApproveOption,
+) error {
+	return signState.ValidateSignRequest(SignRequest{
+		HRS:       hrs,
+		SignBytes: signBytes,
+		PolRound:  polRound,
+	}, opts...)
+}
+
+// ValidateSignRequest validates a sign request against the consensus lock.
+// Unlike ValidateConsensusLock, the request may carry a vote extension.
+func (signState *SignState) ValidateSignRequest(req SignRequest, opts ...ApproveOption) error {
+	if signState == nil {
+		return newNilSignStateError(req.HRS)
+	}
+	if signState.Tracer == nil && signState.ValidateTimeout <= 0 {
+		return signState.validateSignRequest(req, opts, nil)
+	}
+	validate := func() error {
+		return signState.validateSignRequestWithTimeout(req, opts)
+	}
+	if signState.Tracer != nil {
+		return signState.traceValidation(req.HRS, validate)
+	}
+	return validate()
+}
+
+// validateSignRequestWithTimeout validates req, giving up with a *ValidateTimeoutError after
+// ValidateTimeout, if set. Validation that times out still runs to completion in the background,
+// but records nothing: its decision is not logged, reported, counted or approved.
+func (signState *SignState) validateSignRequestWithTimeout(req SignRequest, opts []ApproveOption) error {
+	timeout := signState.ValidateTimeout
+	if timeout <= 0 {
+		return signState.validateSignRequest(req, opts, nil)
+	}
+
+	// Whichever of the validation and the timer is first decides the outcome
+	var once sync.Once
+	claim := func() (claimed bool) {
+		once.Do(func() { claimed = true })
+		return claimed
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- signState.validateSignRequest(req, opts, claim)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		if claim() {
+			return newValidateTimeoutError(req.HRS, timeout)
+		}
+		// The validation finished just in time and is recording its decision
+		return <-done
+	}
+}
+
+// validateSignRequest validates req and records the decision. If claim is set, the decision
+// is only recorded if claim returns true, i.e. the validation has not timed out.
+func (signState *SignState) validateSignRequest(req SignRequest, opts []ApproveOption, claim func() bool) error {
+	cfg := newApproveConfig(opts)
+	answer := signState.askQuorum(cfg.ctx, req.HRS)
+
 	signState.mu.RLock()
 	defer signState.mu.RUnlock()
+	err := signState.lockedCheckConsensusLock(req)
+	// Optionally refuse unless a quorum of cosigners agrees on the lock
+	if err == nil {
+		err = signState.lockedCheckQuorumLock(req.HRS, answer)
+	}
+	if claim != nil && !claim() {
+		return newValidateTimeoutError(req.HRS, signState.ValidateTimeout)
+	}
+	return signState.lockedApplyDecision(req, err)
+}
 
-	// If no consensus lock exists, allow signing
-	if !signState.ConsensusLock.IsLocked() {
+// lockedValidateConsensusLock validates the consensus lock and records the decision.
+// Not thread-safe (requires external lock).
+func (signState *SignState) lockedValidateConsensusLock(req SignRequest) error {
+	return signState.lockedApplyDecision(req, signState.lockedCheckConsensusLock(req))
+}
+
+// lockedApplyDecision records the decision err of lockedCheckConsensusLock on req and returns
+// the error refusing req, if any, in ShadowMode or CanaryMode. Not thread-safe (requires external lock).
+func (signState *SignState) lockedApplyDecision(req SignRequest, err error) error {
+	signState.lockedRecordDecision(req, err)
+	return signState.canaryOverride(signState.shadowOverride(err))
+}
+
+// lockedDecideConsensusLock validates the consensus lock and records the decision, as if
+// enforcing in CanaryMode. Not thread-safe (requires external lock).
+func (signState *SignState) lockedDecideConsensusLock(req SignRequest) error {
+	err := signState.lockedCheckConsensusLock(req)
+	signState.lockedRecordDecision(req, err)
+	return signState.shadowOverride(err)
+}
+
+// lockedPermitConsensusLock returns what lockedValidateConsensusLock would for req, without
+// recording the decision, e.g. to check a request again that was already validated.
+// Not thread-safe (requires external lock).
+func (signState *SignState) lockedPermitConsensusLock(req SignRequest) error {
+	if signState.CanaryMode {
+		return nil
+	}
+	return signState.shadowOverride(signState.lockedCheckConsensusLock(req))
+}
+
+// lockedRecordDecision logs, reports and counts the decision err of lockedCheckConsensusLock
+// on req, and records req as approved if it is allowed. Not thread-safe (requires external lock).
+func (signState *SignState) lockedRecordDecision(req SignRequest, err error) {
+	consensusLockAge.Set(signState.lockedConsensusLockAge().Seconds())
+
+	signState.logConsensusLockDecision(req.HRS, req.SignBytes, err)
+	signState.reportViolation(err, req.HRS, req.SignBytes)
+	signState.warnLongLock(err, req.HRS)
+	if isViolation(err) {
+		consensusLockViolations.Inc()
+		signState.violations.inc(req.HRS.Height)
+	}
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		signBytesDecodeErrors.Inc()
+	}
+	if signState.shadowOverride(err) != nil {
+		return
+	}
+	signState.recordTimestamp(req.HRS, req.SignBytes)
+	if signState.approvals.add(req.HRS, req.SignBytes, func(a, b []byte) bool {
+		aValue, aErr := signState.extractValue(req.HRS.Step, a)
+		bValue, bErr := signState.extractValue(req.HRS.Step, b)
+		return aErr == nil && bErr == nil && lockValuesEqual(aValue, bValue)
+	}) {
+		duplicateSignRequests.Inc()
+	}
+}
+
+// shadowOverride returns nil in ShadowMode, unless CanaryMode is set or err refuses a halted
+// signer, and err otherwise.
+func (signState *SignState) shadowOverride(err error) error {
+	var haltedErr *SignerHaltedError
+	if signState.ShadowMode && !signState.CanaryMode && !errors.As(err, &haltedErr) {
+		return nil
+	}
+	return err
+}
+
+// canaryOverride returns nil in CanaryMode, counting err if it would have refused the request,
+// and err otherwise.
+func (signState *SignState) canaryOverride(err error) error {
+	if !signState.CanaryMode || err == nil {
+		return err
+	}
+	canaryOverrides.Inc()
+	return nil
+}
+
+// reportViolation calls OnViolation if err is a consensus lock violation or a double sign.
+func (signState *SignState) reportViolation(err error, hrs HRSKey, attempted []byte) {
+	if signState.OnViolation != nil && isViolation(err) {
+		signState.OnViolation(err, hrs, attempted)
+	}
+}
+
+// isViolation returns true if err is a consensus lock violation or a double sign.
+func isViolation(err error) bool {
+	return err != nil &&
+		(IsConsensusLockViolationError(err) || IsConsensusLockStepViolationError(err) || IsDoubleSignError(err))
+}
+
+// logConsensusLockDecision logs a consensus lock decision along with the chain ID,
+// height, round and value decoded from the sign bytes, so the log reflects the actual
+// message that was allowed or denied rather than only the HRS we were handed.
+// Allowed requests are only logged with LockLogAllowsAndDenies.
+func (signState *SignState) logConsensusLockDecision(hrs HRSKey, signBytes []byte, err error) {
+	if signState.Logger == nil || (err == nil && signState.LogLevel < LockLogAllowsAndDenies) {
+		return
+	}
+
+	decision := "allow"
+	switch {
+	case err == nil:
+	case signState.CanaryMode, signState.ShadowMode && !signState.Halted:
+		decision = "would_deny"
+	default:
+		decision = "deny"
+	}
+
+	keyvals := []interface{}{
+		"decision", decision,
+		"height", hrs.Height,
+		"round", hrs.Round,
+		"step", hrs.Step,
+	}
+
+	if len(signBytes) > signState.maxSignBytesLen() {
+		keyvals = append(keyvals, "decode_error", "sign bytes too large to decode")
+	} else if decoded, decodeErr := signState.decode(hrs.Step, signBytes); decodeErr != nil {
+		keyvals = append(keyvals, "decode_error", decodeErr.Error())
+	} else {
+		keyvals = append(keyvals,
+			"signed_chain_id", decoded.chainID,
+			"signed_height", decoded.height,
+			"signed_round", decoded.round,
+		)
+		if value, valueErr := signState.extractValue(hrs.Step, signBytes); valueErr == nil {
+			keyvals = append(keyvals, "value", fmt.Sprintf("%X", value))
+		}
+	}
+
+	if err != nil {
+		keyvals = append(keyvals, "error", err.Error())
+	}
+
+	signState.Logger.Info("Consensus lock decision", keyvals...)
+}
+
+// lockedCheckConsensusLock applies the consensus lock rules. Not thread-safe (requires external lock).
+func (signState *SignState) lockedCheckConsensusLock(req SignRequest) error {
+	hrs, signBytes, polRound := req.HRS, req.SignBytes, req.PolRound
+
+	// Refuse everything once a double sign was detected
+	if signState.Halted {
+		return newSignerHaltedError(hrs)
+	}
+
+	// Refuse to spend time decoding absurdly large sign bytes
+	if maxLen := signState.maxSignBytesLen(); len(signBytes) > maxLen {
+		return newOversizedSignBytesError(len(signBytes), maxLen)
+	}
+
+	// Optionally refuse grossly skewed timestamps
+	if signState.MaxTimestampSkew > 0 {
+		if err := signState.checkTimestampSkew(hrs.Step, signBytes); err != nil {
+			return err
+		}
+	}
+
+	// Optionally refuse timestamps going backwards within a height
+	if signState.MonotonicTimestamps {
+		if err := signState.checkTimestampRegression(hrs, signBytes); err != nil {
+			return err
+		}
+	}
+
+	// Optionally refuse absurdly high rounds
+	if signState.MaxRound > 0 && hrs.Round > int64(signState.MaxRound) {
+		return newRoundCeilingError(hrs, signState.MaxRound)
+	}
+
+	// Optionally refuse to sign proposals for another validator
+	if err := signState.checkProposer(req); err != nil {
+		return err
+	}
+
+	// Optionally refuse to vote without any prior state to check against
+	if signState.FailClosedOnMissingState && (hrs.Step == stepPrevote || hrs.Step == stepPrecommit) &&
+		signState.lockedUninitialized() {
+		return newUninitializedSignStateError(hrs)
+	}
+
+	// Rounds must never go backwards within the height we last signed. An HRS already signed
+	// is left to the signature cache, which answers a repeated request with the signature.
+	if signState.lastRoundHeight != 0 && hrs.Height == signState.lastRoundHeight && hrs.Round < signState.lastRound &&
+		!signState.lockedCached(hrs) {
+		return newRoundRegressionError(hrs.Height, hrs.Round, signState.lastRound)
+	}
+
+	// Optionally refuse any HRS not above the highest ever signed
+	if signState.GlobalMonotonicHRS {
+		highest := signState.HighestHRS
+		if signed := signState.lockedHrsKey(); signed.GreaterThan(highest) {
+			highest = signed
+		}
+		if !hrs.GreaterThan(highest) {
+			return newHRSNotIncreasingError(hrs, highest)
+		}
+	}
+
+	// Everything below enforces the consensus lock
+	if signState.DisableLock {
+		return nil
+	}
+
+	// Never prevote a different value than the one we proposed in the same round
+	if err := signState.lockedCheckCrossStep(hrs, signBytes); err != nil {
+		return err
+	}
+
+	lock := signState.lockedLockFor(hrs.Height)
+
+	// If no consensus lock exists, allow signing without decoding the sign bytes
+	if !lock.IsLocked() {
 		return nil
 	}
 
 	// If we're signing for a different height, the lock is no longer relevant
-	if hrs.Height != signState.ConsensusLock.Height {
+	if hrs.Height != lock.Height {
 		return nil
 	}
 
+	// Optionally refuse to propose at a round earlier than the one we locked in
+	if signState.BlockEarlierRoundPropose && hrs.Step == stepPropose && hrs.Round < lock.Round {
+		return newConsensusLockStepViolationError(hrs)
+	}
+
 	// For PROPOSAL and PREVOTE messages in rounds R' >= locked_round, only allow signing for the locked value V
-	if (hrs.Step == stepPropose || hrs.Step == stepPrevote) && hrs.Round >= signState.ConsensusLock.Round {
+	if signState.lockSemantics(hrs.Step) == lockConstrained && hrs.Round >= lock.Round {
 		// Extract the block hash from the sign bytes to compare with the locked value
-		blockHash, err := extractBlockHashFromSignBytes(signBytes, hrs.Step)
+		blockHash, err := signState.extractValue(hrs.Step, signBytes)
 		if err != nil {
 			return newBlockHashExtractionError(hrs.Step, err)
 		}
+		value := signState.lockValue(blockHash, req.VoteExtension)
 
-		// Check if we're trying to sign a different value than what we're locked on
-		if !bytes.Equal(blockHash, signState.ConsensusLock.Value) {
+		// Check if we're trying to sign a different value than what we're locked on.
+		// A prevote for nil is always allowed.
+		if !signState.sameLockedValue(lock, value, hrs.Step, signBytes) && !isNilVote(hrs.Step, value) {
 			// For PREVOTE, check if we can unlock based on POL round
 			if hrs.Step == stepPrevote {
 				// if protomsg without polRound
@@ -745,7 +1622,7 @@ func (signState *SignState) ValidateConsensusLock(hrs HRSKey, signBytes []byte,
 				// polRound >= 0: New Tendermint version with POL round information
 				if polRound >= 0 {
 					// Check if POL round is greater than locked's
-					if polRound > signState.ConsensusLock.Round {
+					if polRound > lock.Round {
 						return nil // POL justification
 					}
 					// POL justification is old
@@ -754,74 +1631,477 @@ func (signState *SignState) ValidateConsensusLock(hrs HRSKey, signBytes []byte,
 			}
 
 			return newConsensusLockViolationError(
-				signState.ConsensusLock.Value,
-				blockHash,
-				signState.ConsensusLock.Height,
-				signState.ConsensusLock.Round,
+				lock.Value,
+				value,
+				lock.Height,
+				lock.Round,
 			)
 		}
 	}
 
+	// A PRECOMMIT can only move the lock in a later round. A PRECOMMIT for a
+	// different value in the lock round itself is equivocation, not a release.
+	if signState.lockSemantics(hrs.Step) == lockReleasing && hrs.Round == lock.Round {
+		blockHash, err := signState.extractValue(hrs.Step, signBytes)
+		if err != nil {
+			return newBlockHashExtractionError(hrs.Step, err)
+		}
+		value := signState.lockValue(blockHash, req.VoteExtension)
+		// A precommit for nil neither violates nor moves the lock
+		if !signState.sameLockedValue(lock, value, hrs.Step, signBytes) && !isNilVote(hrs.Step, value) {
+			return newConsensusLockViolationError(lock.Value, value, lock.Height, lock.Round)
+		}
+	}
+	return nil
+}
+
+// lockedCheckCrossStep returns a *CrossStepConflictError if hrs is a prevote for a different
+// block than a proposal already signed at the same height and round. Votes for nil are never
+// in conflict. Not thread-safe (requires external lock).
+func (signState *SignState) lockedCheckCrossStep(hrs HRSKey, signBytes []byte) error {
+	if hrs.Step != stepPrevote {
+		return nil
+	}
+	proposal, ok := signState.cache[HRSKey{Height: hrs.Height, Round: hrs.Round, Step: stepPropose}]
+	if !ok {
+		return nil
+	}
+	proposed, err := signState.extractValue(stepPropose, proposal.SignBytes)
+	if err != nil {
+		return nil
+	}
+	prevoted, err := signState.extractValue(stepPrevote, signBytes)
+	if err != nil || len(prevoted) == 0 {
+		return nil
+	}
+	if !lockValuesEqual(proposed, prevoted) {
+		return newCrossStepConflictError(hrs, proposed, prevoted)
+	}
+	return nil
+}
+
+// checkTimestampSkew returns a *TimestampSkewError if the timestamp in signBytes is
+// further than MaxTimestampSkew from the local clock.
+func (signState *SignState) checkTimestampSkew(step int8, signBytes []byte) error {
+	decoded, err := signState.decode(step, signBytes)
+	if err != nil {
+		return err
+	}
+	now := signState.now()
+	skew := decoded.timestamp.Sub(now)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > signState.MaxTimestampSkew {
+		return newTimestampSkewError(decoded.timestamp, now, signState.MaxTimestampSkew)
+	}
 	return nil
 }
 
-// ClearConsensusLock clears the consensus lock when appropriate
-func (signState *SignState) ClearConsensusLock(hrs HRSKey) {
+// lockedUninitialized returns true if nothing has been signed and no lock is held.
+// Not thread-safe (requires external lock).
+func (signState *SignState) lockedUninitialized() bool {
+	return signState.Height == 0 && signState.Round == 0 && signState.Step == 0 &&
+		!signState.ConsensusLock.IsLocked()
+}
+
+// now returns the current time from the configured clock.
+func (signState *SignState) now() time.Time {
+	if signState.Now != nil {
+		return signState.Now()
+	}
+	return time.Now()
+}
+
+// lockedConsensusLockAge returns how long ago the consensus lock was last updated.
+// It is zero when unlocked or when the update time is unknown. Not thread-safe (requires external lock).
+func (signState *SignState) lockedConsensusLockAge() time.Duration {
+	lock := signState.ConsensusLock
+	if !lock.IsLocked() || lock.UpdatedAt.IsZero() {
+		return 0
+	}
+	return signState.now().Sub(lock.UpdatedAt)
+}
+
+// lockMoved returns true if next locks a different height, round or value than prev.
+func lockMoved(prev, next ConsensusLock) bool {
+	return prev.Height != next.Height || prev.Round != next.Round || !bytes.Equal(prev.Value, next.Value)
+}
+
+// lockValuesEqual compares two lock values in constant time.
+// Values of different lengths are never equal.
+func lockValuesEqual(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// LastRound returns the highest round signed at height, if height is the height last signed.
+func (signState *SignState) LastRound(height int64) (int64, bool) {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+	if signState.lastRoundHeight == 0 || height != signState.lastRoundHeight {
+		return 0, false
+	}
+	return signState.lastRound, true
+}
+
+// RequireUnlocked returns an error if a consensus lock is active.
+// Operations that must not run mid-consensus, such as key rotation, can use it as a gate.
+func (signState *SignState) RequireUnlocked() error {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+	if signState.ConsensusLock.IsLocked() {
+		return newConsensusLockActiveError(signState.ConsensusLock.HRSKey())
+	}
+	return nil
+}
+
+// LastSignedValue returns a copy of the value of the last precommit that set or moved the
+// consensus lock, i.e. the value we last committed to, and false if there is no lock.
+func (signState *SignState) LastSignedValue() ([]byte, bool) {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+	if !signState.ConsensusLock.IsLocked() {
+		return nil, false
+	}
+	return append([]byte{}, signState.ConsensusLock.Value...), true
+}
+
+// PreferredValue returns the locked value if it is among candidates.
+// It returns false if there is no lock or none of the candidates is the locked value.
+func (signState *SignState) PreferredValue(candidates [][]byte) ([]byte, bool) {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+	if !signState.ConsensusLock.IsLocked() {
+		return nil, false
+	}
+	for _, candidate := range candidates {
+		if lockValuesEqual(candidate, signState.ConsensusLock.Value) {
+			return candidate, true
+		}
+	}
+	return nil, false
+}
+
+// BlockedSteps returns, in order, every HRS at the locked height up to and including
+// upToRound at which ValidateConsensusLock would reject signing value, assuming no
+// POL round justifies a prevote. value is compared as stored in the lock.
+// It returns nil when there is no lock.
+func (signState *SignState) BlockedSteps(value []byte, upToRound int32) []HRSKey {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+
+	lock := signState.ConsensusLock
+	if !lock.IsLocked() {
+		return nil
+	}
+	locked := lockValuesEqual(value, lock.Value)
+
+	var blocked []HRSKey
+	for round := int64(0); round <= int64(upToRound); round++ {
+		regressed := signState.lastRoundHeight == lock.Height && round < signState.lastRound
+		for _, step := range []int8{stepPropose, stepPrevote, stepPrecommit} {
+			constrained := signState.lockSemantics(step) == lockConstrained && round >= lock.Round && !locked
+			earlyPropose := signState.BlockEarlierRoundPropose && step == stepPropose && round < lock.Round
+			if regressed || constrained || earlyPropose {
+				blocked = append(blocked, HRSKey{Height: lock.Height, Round: round, Step: step})
+			}
+		}
+	}
+	return blocked
+}
+
+// ConstrainedHRS returns, in order, every HRS at the locked height from the lock round up to
+// and including upToRound that may only sign the locked value, i.e. the constrained steps
+// (proposals and prevotes by default). It returns nil when there is no lock.
+func (signState *SignState) ConstrainedHRS(upToRound int32) []HRSKey {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+
+	lock := signState.ConsensusLock
+	if !lock.IsLocked() {
+		return nil
+	}
+
+	var constrained []HRSKey
+	for round := lock.Round; round <= int64(upToRound); round++ {
+		for _, step := range []int8{stepPropose, stepPrevote, stepPrecommit} {
+			if signState.lockSemantics(step) == lockConstrained {
+				constrained = append(constrained, HRSKey{Height: lock.Height, Round: round, Step: step})
+			}
+		}
+	}
+	return constrained
+}
+
+// SeedFromHeight moves the SignState to height, e.g. the last committed height
+// reported by the chain node at boot, and clears any lock from a lower height so
+// that a stale lock never constrains signing. Seeding below the current height is
+// refused, and a pinned lock at a lower height is never cleared.
+func (signState *SignState) SeedFromHeight(height int64) error {
 	signState.mu.Lock()
 	defer signState.mu.Unlock()
 
+	if height < signState.Height {
+		return newHeightRegressionError(height, signState.Height)
+	}
+	if lock := signState.ConsensusLock; lock.IsLocked() && lock.Height < height && lock.Pinned {
+		return newPinnedLockError(lock.HRSKey(), HRSKey{Height: height})
+	}
+	if height == signState.Height {
+		return nil
+	}
+
+	signState.Height = height
+	signState.Round = 0
+	signState.Step = 0
+	signState.lastRoundHeight = height
+	signState.lastRound = 0
+
+	for h := range signState.heightLocks {
+		if h < height {
+			delete(signState.heightLocks, h)
+		}
+	}
+	for h := range signState.heightSigned {
+		if h < height {
+			delete(signState.heightSigned, h)
+		}
+	}
+	if signState.ConsensusLock.IsLocked() && signState.ConsensusLock.Height < height {
+		signState.ConsensusLock = ConsensusLock{}
+		signState.lockedLockChanged()
+	}
+	return nil
+}
+
+// RollbackTo resets the signed HRS to the start of height and clears the consensus lock,
+// for recovery after the chain has provably reorged. It refuses with a *MissingRollbackProofError
+// unless proof is non-empty, since signing again at rolled back heights would otherwise be
+// a double sign. Cached signatures from height onwards are dropped.
+func (signState *SignState) RollbackTo(height int64, proof []byte) error {
+	signState.mu.Lock()
+	defer signState.mu.Unlock()
+
+	if len(proof) == 0 {
+		return newMissingRollbackProofError(height)
+	}
+
+	if signState.Logger != nil {
+		signState.Logger.Error(
+			"Rolling back sign state after proven fork",
+			"from_height", signState.Height,
+			"from_round", signState.Round,
+			"from_step", signState.Step,
+			"to_height", height,
+			"lock_height", signState.ConsensusLock.Height,
+			"lock_round", signState.ConsensusLock.Round,
+			"proof", fmt.Sprintf("%X", proof),
+		)
+	}
+
+	signState.Height = height
+	signState.Round = 0
+	signState.Step = 0
+	signState.Signature = nil
+	signState.SignBytes = nil
+	signState.VoteExtensionSignature = nil
+	signState.lastRoundHeight = height
+	signState.lastRound = 0
+
+	for hrs := range signState.cache {
+		if hrs.Height >= height {
+			delete(signState.cache, hrs)
+		}
+	}
+	signState.heightLocks = nil
+	signState.heightSigned = nil
+	signState.ConsensusLock = ConsensusLock{}
+	signState.lockedLockChanged()
+	return nil
+}
+
+// AdvanceRound moves the signed HRS to the proposal step of the next round, as a round
+// timeout does, e.g. to simulate liveness handling in tests. The next signature is then
+// expected to be a prevote of the new round. The consensus lock is kept untouched, since
+// locks persist across the rounds of a height.
+func (signState *SignState) AdvanceRound() {
+	signState.mu.Lock()
+	defer signState.mu.Unlock()
+
+	signState.Round++
+	signState.Step = stepPropose
+	signState.Signature = nil
+	signState.SignBytes = nil
+	signState.VoteExtensionSignature = nil
+	signState.lastRoundHeight = signState.Height
+	signState.lastRound = signState.Round
+}
+
+// ClearLockOutcome describes what ClearConsensusLock did.
+type ClearLockOutcome int
+
+const (
+	// LockCleared means the lock was cleared for a request at an earlier height than the lock.
+	LockCleared ClearLockOutcome = iota
+	// LockClearedHeightChange means the lock was cleared because consensus moved to a later height.
+	LockClearedHeightChange
+	// LockAlreadyEmpty means there was no lock to clear.
+	LockAlreadyEmpty
+	// LockKeptLowerRound means the lock was kept for a request at a round below the lock round.
+	LockKeptLowerRound
+	// LockKeptSameRound means the lock was kept for a request at the lock round.
+	LockKeptSameRound
+	// LockKeptLaterRound means the lock was kept for a request at a later round of the same height.
+	LockKeptLaterRound
+	// LockKeptPinned means the lock would have been cleared, but it is pinned.
+	LockKeptPinned
+)
+
+func (o ClearLockOutcome) String() string {
+	switch o {
+	case LockCleared:
+		return "cleared"
+	case LockClearedHeightChange:
+		return "cleared_height_change"
+	case LockAlreadyEmpty:
+		return "already_empty"
+	case LockKeptLowerRound:
+		return "kept_lower_round"
+	case LockKeptSameRound:
+		return "kept_same_round"
+	case LockKeptLaterRound:
+		return "kept_later_round"
+	case LockKeptPinned:
+		return "kept_pinned"
+	default:
+		return fmt.Sprintf("ClearLockOutcome(%d)", int(o))
+	}
+}
+
+// ClearConsensusLock clears the consensus lock when appropriate and reports what it did.
+// A pinned lock is never cleared; attempting to do so returns a *PinnedLockError.
+func (signState *SignState) ClearConsensusLock(hrs HRSKey) (ClearLockOutcome, error) {
+	signState.mu.Lock()
+	defer signState.mu.Unlock()
+
+	lock := signState.ConsensusLock
+	if !lock.IsLocked() {
+		return LockAlreadyEmpty, nil
+	}
+
 	// Only clear lock if we're moving to a different height
 	// Locks persist for all future rounds within the same height according to Tendermint rules
-	if hrs.Height != signState.ConsensusLock.Height {
+	if hrs.Height != lock.Height {
+		if lock.Pinned {
+			return LockKeptPinned, newPinnedLockError(lock.HRSKey(), hrs)
+		}
 		signState.ConsensusLock = ConsensusLock{}
-		return
+		signState.lockedLockChanged()
+		if hrs.Height > lock.Height {
+			return LockClearedHeightChange, nil
+		}
+		return LockCleared, nil
 	}
 
 	// For same height, locks persist for all rounds (no clearing)
+	switch {
+	case hrs.Round < lock.Round:
+		return LockKeptLowerRound, nil
+	case hrs.Round == lock.Round:
+		return LockKeptSameRound, nil
+	default:
+		return LockKeptLaterRound, nil
+	}
 }
 
 // extractBlockHashFromSignBytes extracts the block hash from Tendermint sign bytes
 func extractBlockHashFromSignBytes(signBytes []byte, step int8) ([]byte, error) {
-	if len(signBytes) == 0 {
-		return nil, fmt.Errorf("empty sign bytes")
+	return extractBlockHash(signBytes, step, 0, StepEncodingCometBFT)
+}
+
+// extractBlockHash is like extractBlockHashFromSignBytes, but the block hash must have
+// length hashLength, if non-zero, and the message type be that of step in encoding, as
+// checked by decodeCanonicalWith.
+func extractBlockHash(signBytes []byte, step int8, hashLength int, encoding StepEncoding) ([]byte, error) {
+	decoded, err := decodeCanonicalWith(signBytes, step, hashLength, encoding)
+	if err != nil {
+		return nil, err
+	}
+	if decoded.blockID == nil {
+		return nil, fmt.Errorf("%s has %w", signType(step), errNoBlockID)
 	}
+	return decoded.blockID.GetHash(), nil
+}
 
-	switch step {
-	case stepPropose:
-		// For PROPOSAL, extract block hash from CanonicalProposal
-		var proposal cometproto.CanonicalProposal
-		if err := protoio.UnmarshalDelimited(signBytes, &proposal); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal proposal: %w", err)
-		}
-		blockID := proposal.GetBlockID()
-		if blockID == nil {
-			return nil, fmt.Errorf("proposal has no block ID")
-		}
-		return blockID.GetHash(), nil
+// isNilVote returns true if value, as extracted from sign bytes for step, is a vote for nil.
+func isNilVote(step int8, value []byte) bool {
+	return step != stepPropose && len(value) == 0
+}
 
-	case stepPrevote, stepPrecommit:
-		// For PREVOTE/PRECOMMIT, extract block hash from CanonicalVote
-		var vote cometproto.CanonicalVote
-		if err := protoio.UnmarshalDelimited(signBytes, &vote); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal vote: %w", err)
-		}
-		blockID := vote.GetBlockID()
-		if blockID == nil {
-			return nil, fmt.Errorf("vote has no block ID")
-		}
-		return blockID.GetHash(), nil
+// errNoBlockID is returned when extracting the block hash of sign bytes without a block ID,
+// such as votes for nil.
+var errNoBlockID = errors.New("no block ID")
 
-	default:
-		return nil, fmt.Errorf("unknown step: %d", step)
+// SameSignedValue returns true if two sign byte blobs, possibly for different
+// steps, rounds or timestamps, carry the same block hash.
+func SameSignedValue(aStep int8, a []byte, bStep int8, b []byte) (bool, error) {
+	aHash, err := extractBlockHashFromSignBytes(a, aStep)
+	if err != nil {
+		return false, newBlockHashExtractionError(aStep, err)
+	}
+	bHash, err := extractBlockHashFromSignBytes(b, bStep)
+	if err != nil {
+		return false, newBlockHashExtractionError(bStep, err)
 	}
+	return lockValuesEqual(aHash, bHash), nil
+}
+
+var warmUpDecoderOnce sync.Once
+
+// WarmUpDecoder decodes a synthetic proposal and vote so that any lazily
+// initialized proto state is primed before the first real sign request.
+// It is safe to call multiple times and from multiple goroutines.
+func WarmUpDecoder() {
+	warmUpDecoderOnce.Do(func() {
+		blockID := &cometproto.CanonicalBlockID{Hash: make([]byte, 32)}
+
+		proposal, err := protoio.MarshalDelimited(&cometproto.CanonicalProposal{
+			Type:    cometproto.ProposalType,
+			Height:  1,
+			BlockID: blockID,
+		})
+		if err == nil {
+			_, _ = extractBlockHashFromSignBytes(proposal, stepPropose)
+		}
+
+		vote, err := protoio.MarshalDelimited(&cometproto.CanonicalVote{
+			Type:    cometproto.PrevoteType,
+			Height:  1,
+			BlockID: blockID,
+		})
+		if err == nil {
+			_, _ = extractBlockHashFromSignBytes(vote, stepPrevote)
+		}
+	})
 }
 
 // nextConsensusLock updates the consensus lock based on Tendermint rules
 // This is a helper function that can be used by both SignState and other components
 func nextConsensusLock(existingLock ConsensusLock, hrs HRSKey, signBytes []byte) ConsensusLock {
-	// Only update lock for PRECOMMIT steps (step 3)
-	if hrs.Step != stepPrecommit {
-		// For non-PRECOMMIT steps, only clear lock if moving to different height
+	return ConsensusLockOptions{}.nextConsensusLock(existingLock, hrs, signBytes, nil)
+}
+
+// nextConsensusLock updates the consensus lock based on Tendermint rules and the lock options.
+// extension is the vote extension signed alongside signBytes, if any.
+func (opts ConsensusLockOptions) nextConsensusLock(
+	existingLock ConsensusLock, hrs HRSKey, signBytes []byte, extension []byte,
+) ConsensusLock {
+	// Only update lock for releasing steps (PRECOMMIT by default)
+	if opts.lockSemantics(hrs.Step) != lockReleasing {
+		// For non-releasing steps, only clear lock if moving to different height
 		// Locks persist for all future rounds within the same height
 		if hrs.Height != existingLock.Height {
 			return ConsensusLock{}
@@ -830,32 +2110,40 @@ func nextConsensusLock(existingLock ConsensusLock, hrs HRSKey, signBytes []byte)
 	}
 
 	// Extract the block hash from the sign bytes
-	blockHash, err := extractBlockHashFromSignBytes(signBytes, hrs.Step)
-	if err != nil {
-		// If we can't extract the block hash, return existing lock unchanged
+	blockHash, err := opts.extractValue(hrs.Step, signBytes)
+	if err != nil || len(blockHash) == 0 || opts.refusesZeroValue(blockHash) ||
+		opts.checkLockableValue(hrs.Step, blockHash) != nil {
+		// If we can't extract a valid block hash, or it is a precommit for nil (or the zero hash),
+		// return existing lock unchanged
 		return existingLock
 	}
+	value := opts.lockValue(blockHash, extension)
+	partSetHeader := opts.partSetHeaderHash(hrs.Step, signBytes)
 
 	// Rule 1.2: If PRECOMMIT for V' is signed in round R' > R where V' != V,
 	// then lock on V' instead for all rounds R'' > R'
 	if hrs.Round > existingLock.Round &&
 		existingLock.IsLocked() &&
-		!bytes.Equal(blockHash, existingLock.Value) {
+		(!bytes.Equal(value, existingLock.Value) || !bytes.Equal(partSetHeader, existingLock.PartSetHeader)) {
 		// Release old lock and set new lock on V'
 		// Round is where we locked on this value (lockedRound)
 		return ConsensusLock{
-			Height: hrs.Height,
-			Round:  hrs.Round, // Round where we locked on this value
-			Value:  blockHash,
+			Height:        hrs.Height,
+			Round:         hrs.Round, // Round where we locked on this value
+			Value:         value,
+			SetBy:         hrs,
+			PartSetHeader: partSetHeader,
 		}
 	}
-	if !existingLock.IsLocked() {
-		// First lock for this height
+	if !existingLock.IsLocked() || existingLock.Height != hrs.Height {
+		// First lock for this height; a lock from another height never carries over
 		// Round is where we locked on this value (lockedRound)
 		return ConsensusLock{
-			Height: hrs.Height,
-			Round:  hrs.Round, // Round where we locked on this value
-			Value:  blockHash,
+			Height:        hrs.Height,
+			Round:         hrs.Round, // Round where we locked on this value
+			Value:         value,
+			SetBy:         hrs,
+			PartSetHeader: partSetHeader,
 		}
 	}
 	// If PRECOMMIT for same value V in higher round, keep existing lock (no change needed)
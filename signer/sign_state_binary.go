@@ -0,0 +1,267 @@
+package signer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/strangelove-ventures/horcrux/v3/signer/cond"
+)
+
+// signStateBinaryVersion is the version of the SignState binary layout.
+const signStateBinaryVersion byte = 1
+
+// MarshalBinary encodes the persisted fields of the SignState (the same fields
+// as its JSON form), followed by the lock transition history, in a compact,
+// versioned layout. All integers are big endian and byte slices are prefixed
+// with their uint32 length:
+//
+//	version | height | round | step
+//	nonce public | signature | sign bytes | vote extension signature
+//	lock: locked | [lock height | lock round | lock value | updated at (unix s, ns)
+//	      | set by (height | round | step) | pinned | part set header]
+//	halted | highest hrs (height | round | step)
+//	height locks (uint32 count | lock...) | height signed (uint32 count | hrs (height | round | step)...)
+//	transitions (uint32 count | time (unix s, ns) | hrs | from lock | to lock...)
+func (signState *SignState) MarshalBinary() ([]byte, error) {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+
+	var buf bytes.Buffer
+	w := func(v any) { _ = binary.Write(&buf, binary.BigEndian, v) }
+	wBytes := func(b []byte) {
+		w(uint32(len(b)))
+		buf.Write(b)
+	}
+
+	w(signStateBinaryVersion)
+	w(signState.Height)
+	w(signState.Round)
+	w(signState.Step)
+	wBytes(signState.NoncePublic)
+	wBytes(signState.Signature)
+	wBytes(signState.SignBytes)
+	wBytes(signState.VoteExtensionSignature)
+
+	wTime := func(t time.Time) {
+		var sec int64
+		var nsec int32
+		if !t.IsZero() {
+			sec, nsec = t.Unix(), int32(t.Nanosecond())
+		}
+		w(sec)
+		w(nsec)
+	}
+	wLock := func(lock ConsensusLock) {
+		w(lock.IsLocked())
+		if !lock.IsLocked() {
+			return
+		}
+		w(lock.Height)
+		w(lock.Round)
+		wBytes(lock.Value)
+		wTime(lock.UpdatedAt)
+		w(lock.SetBy.Height)
+		w(lock.SetBy.Round)
+		w(lock.SetBy.Step)
+		w(lock.Pinned)
+		wBytes(lock.PartSetHeader)
+	}
+	wHRS := func(hrs HRSKey) {
+		w(hrs.Height)
+		w(hrs.Round)
+		w(hrs.Step)
+	}
+
+	wLock(signState.ConsensusLock)
+	w(signState.Halted)
+	wHRS(signState.HighestHRS)
+
+	heightLocks, heightSigned := signState.lockedPersistedHeights()
+	w(uint32(len(heightLocks)))
+	for _, lock := range heightLocks {
+		wLock(lock)
+	}
+	w(uint32(len(heightSigned)))
+	for _, hrs := range heightSigned {
+		wHRS(hrs)
+	}
+
+	w(uint32(len(signState.transitions)))
+	for _, t := range signState.transitions {
+		wTime(t.time)
+		wHRS(t.hrs)
+		wLock(t.from)
+		wLock(t.to)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a SignState encoded with MarshalBinary, replacing its
+// persisted fields and lock history, and starting a fresh cache as LoadSignState does.
+func (signState *SignState) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	var err error
+	read := func(v any) {
+		if err == nil {
+			err = binary.Read(r, binary.BigEndian, v)
+		}
+	}
+	readBytes := func() []byte {
+		var n uint32
+		read(&n)
+		if err != nil || n == 0 {
+			return nil
+		}
+		if int64(n) > int64(r.Len()) {
+			err = io.ErrUnexpectedEOF
+			return nil
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return b
+	}
+
+	var version byte
+	read(&version)
+	if err == nil && version != signStateBinaryVersion {
+		return fmt.Errorf("unsupported sign state binary version %d", version)
+	}
+
+	readTime := func() (t time.Time) {
+		var sec int64
+		var nsec int32
+		read(&sec)
+		read(&nsec)
+		if sec != 0 || nsec != 0 {
+			t = time.Unix(sec, int64(nsec)).UTC()
+		}
+		return t
+	}
+	readLock := func() (lock ConsensusLock) {
+		var locked bool
+		read(&locked)
+		if !locked {
+			return lock
+		}
+		read(&lock.Height)
+		read(&lock.Round)
+		lock.Value = readBytes()
+		lock.UpdatedAt = readTime()
+		read(&lock.SetBy.Height)
+		read(&lock.SetBy.Round)
+		read(&lock.SetBy.Step)
+		read(&lock.Pinned)
+		lock.PartSetHeader = readBytes()
+		if lock.Value == nil {
+			lock.Value = []byte{}
+		}
+		return lock
+	}
+	readHRS := func() (hrs HRSKey) {
+		read(&hrs.Height)
+		read(&hrs.Round)
+		read(&hrs.Step)
+		return hrs
+	}
+
+	var (
+		height, round                                             int64
+		step                                                      int8
+		noncePublic, signature, signBytes, voteExtensionSignature []byte
+		halted                                                    bool
+		heightLocks                                               []ConsensusLock
+		heightSigned                                              []HRSKey
+		transitions                                               []lockTransition
+		count                                                     uint32
+	)
+	read(&height)
+	read(&round)
+	read(&step)
+	noncePublic = readBytes()
+	signature = readBytes()
+	signBytes = readBytes()
+	voteExtensionSignature = readBytes()
+	lock := readLock()
+	read(&halted)
+	highest := readHRS()
+	read(&count)
+	for i := uint32(0); i < count && err == nil; i++ {
+		if heightLock := readLock(); heightLock.IsLocked() {
+			heightLocks = append(heightLocks, heightLock)
+		}
+	}
+	read(&count)
+	for i := uint32(0); i < count && err == nil; i++ {
+		heightSigned = append(heightSigned, readHRS())
+	}
+	read(&count)
+	for i := uint32(0); i < count && err == nil; i++ {
+		t := lockTransition{time: readTime(), hrs: readHRS()}
+		t.from, t.to = readLock(), readLock()
+		transitions = append(transitions, t)
+	}
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("failed to decode sign state: %w", err)
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("failed to decode sign state: %d trailing bytes", r.Len())
+	}
+
+	signState.mu.Lock()
+	defer signState.mu.Unlock()
+
+	signState.Height = height
+	signState.Round = round
+	signState.Step = step
+	signState.NoncePublic = noncePublic
+	signState.Signature = signature
+	signState.SignBytes = signBytes
+	signState.VoteExtensionSignature = voteExtensionSignature
+	signState.ConsensusLock = lock
+	signState.Halted = halted
+	signState.HighestHRS = highest
+	signState.lastRoundHeight = height
+	signState.lastRound = round
+	signState.lockedRestoreHeights(heightLocks, heightSigned)
+	signState.transitions = transitions
+	signState.cache = map[HRSKey]SignStateConsensus{
+		{Height: height, Round: round, Step: step}: {
+			Height:                 height,
+			Round:                  round,
+			Step:                   step,
+			Signature:              signature,
+			SignBytes:              signBytes,
+			VoteExtensionSignature: voteExtensionSignature,
+			ConsensusLock:          lock,
+		},
+	}
+	if signState.cond == nil {
+		signState.cond = cond.New(&signState.mu)
+	}
+	signState.lockedLockChanged()
+	return nil
+}
+
+// Snapshot returns the persisted fields and the lock transition history of the
+// SignState in the binary format, for Restore to return to later, e.g. to replay
+// round progression in tests. Other state only tracked in memory, such as the
+// approval counts, is not part of it.
+func (signState *SignState) Snapshot() []byte {
+	bz, _ := signState.MarshalBinary()
+	return bz
+}
+
+// Restore returns the SignState to a Snapshot, exactly restoring its HRS,
+// consensus lock and lock transition history. Nothing is written to disk, but the restored lock is handed to
+// the debounced saver, if enabled.
+func (signState *SignState) Restore(snapshot []byte) error {
+	return signState.UnmarshalBinary(snapshot)
+}
@@ -0,0 +1,82 @@
+package signer
+
+import (
+	"fmt"
+	"os"
+)
+
+// SignerHaltedError is returned for every sign request while the SignState is halted
+// after a double sign was detected.
+type SignerHaltedError struct {
+	HRS HRSKey
+}
+
+func (e *SignerHaltedError) Error() string {
+	return fmt.Sprintf(
+		"signer halted after a double sign attempt, refusing to sign at height %d round %d step %d until unhalted",
+		e.HRS.Height, e.HRS.Round, e.HRS.Step,
+	)
+}
+
+func newSignerHaltedError(hrs HRSKey) *SignerHaltedError {
+	return &SignerHaltedError{HRS: hrs}
+}
+
+// haltOnDoubleSign halts the SignState with HaltOnDoubleSign if err is a double sign (see
+// IsDoubleSignError). Being asked to sign conflicting data means the node or a sentry
+// misbehaves, so no further request is trusted until an operator calls Unhalt. It returns
+// an error if the halt could not be persisted; the SignState is halted regardless.
+func (signState *SignState) haltOnDoubleSign(err error, hrs HRSKey) error {
+	if !signState.HaltOnDoubleSign || err == nil || !IsDoubleSignError(err) {
+		return nil
+	}
+
+	signState.mu.Lock()
+	if signState.Halted {
+		signState.mu.Unlock()
+		return nil
+	}
+	signState.Halted = true
+	stateCopy := signState.lockedCopy()
+	signState.mu.Unlock()
+
+	if signState.Logger != nil {
+		signState.Logger.Error(
+			"Signer halted after a double sign attempt",
+			"height", hrs.Height,
+			"round", hrs.Round,
+			"step", hrs.Step,
+			"err", err,
+		)
+	}
+	return saveHalted(stateCopy)
+}
+
+// Unhalt lets the SignState sign again after it was halted by a double sign.
+func (signState *SignState) Unhalt() error {
+	signState.mu.Lock()
+	if !signState.Halted {
+		signState.mu.Unlock()
+		return nil
+	}
+	signState.Halted = false
+	stateCopy := signState.lockedCopy()
+	signState.mu.Unlock()
+
+	if signState.Logger != nil {
+		signState.Logger.Info("Signer unhalted")
+	}
+	return saveHalted(stateCopy)
+}
+
+// saveHalted persists a change of Halted in stateCopy, a copy of the SignState, so that it
+// survives a restart.
+func saveHalted(stateCopy *SignState) error {
+	if stateCopy.filePath == "" || stateCopy.filePath == os.DevNull {
+		return nil
+	}
+	if err := trySaveSignState(stateCopy); err != nil {
+		return fmt.Errorf("failed to persist halted state: %w", err)
+	}
+	return nil
+}
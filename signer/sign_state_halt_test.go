@@ -0,0 +1,106 @@
+package signer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignStateHaltsOnDoubleSign(t *testing.T) {
+	signedValue := []byte("signed_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+
+	statePath := filepath.Join(t.TempDir(), "sign_state.json")
+	signState, err := LoadOrCreateSignState(statePath)
+	require.NoError(t, err)
+	signState.HaltOnDoubleSign = true
+	signState.Height, signState.Round, signState.Step = 100, 6, stepPrevote
+	signState.SignBytes = createTestSignBytes(signedValue, stepPrevote)
+	signState.Signature = []byte("signature")
+
+	// Re-signing the same vote does not halt
+	hrst := HRSTKey{Height: 100, Round: 6, Step: stepPrevote}
+	signature, err := signState.existingSignatureOrErrorIfRegression(hrst, createTestSignBytes(signedValue, stepPrevote))
+	require.NoError(t, err)
+	require.Equal(t, []byte("signature"), signature)
+	require.False(t, signState.Halted)
+
+	// A conflicting vote at the same HRS halts the signer
+	_, err = signState.existingSignatureOrErrorIfRegression(hrst, createTestSignBytes(differentValue, stepPrevote))
+	require.True(t, IsDoubleSignError(err))
+	require.True(t, signState.Halted)
+
+	// Unrelated requests are refused while halted
+	unrelated := HRSKey{Height: 200, Round: 0, Step: stepPrevote}
+	err = signState.ValidateConsensusLock(unrelated, createTestSignBytes(differentValue, stepPrevote), -1)
+	var haltedErr *SignerHaltedError
+	require.ErrorAs(t, err, &haltedErr)
+	require.Equal(t, unrelated, haltedErr.HRS)
+	_, err = signState.existingSignatureOrErrorIfRegression(
+		HRSTKey{Height: 200, Round: 0, Step: stepPrevote}, createTestSignBytes(differentValue, stepPrevote))
+	require.ErrorAs(t, err, &haltedErr)
+
+	// The halt survives a restart
+	reloaded, err := LoadSignState(statePath)
+	require.NoError(t, err)
+	require.True(t, reloaded.Halted)
+
+	// Only Unhalt lets the signer sign again
+	require.NoError(t, signState.Unhalt())
+	require.False(t, signState.Halted)
+	require.NoError(t, signState.ValidateConsensusLock(unrelated, createTestSignBytes(differentValue, stepPrevote), -1))
+	reloaded, err = LoadSignState(statePath)
+	require.NoError(t, err)
+	require.False(t, reloaded.Halted)
+}
+
+func TestSignStateHaltOnDoubleSignDisabled(t *testing.T) {
+	signedValue := []byte("signed_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+
+	signState := &SignState{
+		Height:    100,
+		Round:     6,
+		Step:      stepPrevote,
+		SignBytes: createTestSignBytes(signedValue, stepPrevote),
+		Signature: []byte("signature"),
+		filePath:  os.DevNull,
+	}
+
+	// The double sign is refused, but the signer is not halted
+	hrst := HRSTKey{Height: 100, Round: 6, Step: stepPrevote}
+	_, err := signState.existingSignatureOrErrorIfRegression(hrst, createTestSignBytes(differentValue, stepPrevote))
+	require.True(t, IsDoubleSignError(err))
+	require.False(t, signState.Halted)
+}
+
+func TestSignStateHaltNotPersisted(t *testing.T) {
+	signedValue := []byte("signed_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+
+	signState := &SignState{
+		ConsensusLockOptions: ConsensusLockOptions{HaltOnDoubleSign: true},
+		Height:               100,
+		Round:                6,
+		Step:                 stepPrevote,
+		SignBytes:            createTestSignBytes(signedValue, stepPrevote),
+		Signature:            []byte("signature"),
+		filePath:             filepath.Join(t.TempDir(), "missing", "sign_state.json"),
+	}
+
+	// A halt that cannot be written is reported instead of panicking, and still halts
+	hrst := HRSTKey{Height: 100, Round: 6, Step: stepPrevote}
+	_, err := signState.existingSignatureOrErrorIfRegression(hrst, createTestSignBytes(differentValue, stepPrevote))
+	require.True(t, IsDoubleSignError(err))
+	require.ErrorContains(t, err, "failed to persist halted state")
+	require.True(t, signState.Halted)
+}
+
+func TestSignStateHaltedBinaryRoundTrip(t *testing.T) {
+	signState := &SignState{Height: 100, Round: 6, Step: stepPrevote, Halted: true}
+	restored := new(SignState)
+	require.NoError(t, restored.Restore(signState.Snapshot()))
+	require.True(t, restored.Halted)
+}
@@ -0,0 +1,190 @@
+package signer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// InconsistentSignStateError is returned when the SignState contradicts itself.
+type InconsistentSignStateError struct {
+	Reason string
+}
+
+func (e *InconsistentSignStateError) Error() string {
+	return fmt.Sprintf("inconsistent sign state: %s", e.Reason)
+}
+
+func newInconsistentSignStateError(format string, args ...interface{}) *InconsistentSignStateError {
+	return &InconsistentSignStateError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// CheckConsistency returns an *InconsistentSignStateError if the SignState contradicts
+// itself, e.g. because a state file was edited by hand: a negative HRS, a consensus lock
+// ahead of the last signed HRS, or a lock whose SetBy does not match its height and round.
+func (signState *SignState) CheckConsistency() error {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+	return signState.lockedCheckConsistency()
+}
+
+// lockedCheckConsistency implements CheckConsistency. Not thread-safe (requires external lock).
+func (signState *SignState) lockedCheckConsistency() error {
+	if signState.Height < 0 || signState.Round < 0 || signState.Step < 0 {
+		return newInconsistentSignStateError("negative HRS %d/%d/%d", signState.Height, signState.Round, signState.Step)
+	}
+
+	if err := signState.lockedCheckLockConsistency(signState.ConsensusLock); err != nil {
+		return err
+	}
+	for height, lock := range signState.heightLocks {
+		if lock.IsLocked() && lock.Height != height {
+			return newInconsistentSignStateError("lock at height %d is tracked for height %d", lock.Height, height)
+		}
+		if err := signState.lockedCheckLockConsistency(lock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockedCheckLockConsistency checks lock against the last signed HRS. Not thread-safe (requires external lock).
+func (signState *SignState) lockedCheckLockConsistency(lock ConsensusLock) error {
+	if !lock.IsLocked() {
+		return nil
+	}
+
+	// The lock is taken by a precommit we signed, so it cannot be ahead of what we signed.
+	// A state that has never signed anything may still hold a lock set directly.
+	if signState.Height != 0 {
+		if lock.Height > signState.Height ||
+			(lock.Height == signState.Height && lock.Round > signState.Round) {
+			return newInconsistentSignStateError("lock at %d/%d is ahead of last signed %d/%d",
+				lock.Height, lock.Round, signState.Height, signState.Round)
+		}
+	}
+
+	if lock.SetBy != (HRSKey{}) && (lock.SetBy.Height != lock.Height || lock.SetBy.Round != lock.Round) {
+		return newInconsistentSignStateError("lock at %d/%d was set by %d/%d/%d",
+			lock.Height, lock.Round, lock.SetBy.Height, lock.SetBy.Round, lock.SetBy.Step)
+	}
+	return nil
+}
+
+// DetectRoundMismatch compares the round of the consensus lock with the round recorded for
+// its height, to diagnose locks stored with the wrong round, e.g. one less than the round of
+// the precommit that set them. The recorded round is that of SetBy, or for locks without
+// SetBy, the last signed round if the lock claims to be ahead of it. It returns whether they
+// differ and the recorded round minus the lock round. It only reports and never repairs.
+func (signState *SignState) DetectRoundMismatch() (bool, int32) {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+
+	lock := signState.ConsensusLock
+	if !lock.IsLocked() {
+		return false, 0
+	}
+
+	var recorded int64
+	switch {
+	case lock.SetBy != (HRSKey{}) && lock.SetBy.Height == lock.Height:
+		recorded = lock.SetBy.Round
+	case signState.Height == lock.Height && signState.Round < lock.Round:
+		recorded = signState.Round
+	default:
+		return false, 0
+	}
+
+	diff := recorded - lock.Round
+	return diff != 0, int32(diff)
+}
+
+// HealthCheck returns an error if the SignState is not fit to sign with, for use by
+// readiness probes. It checks internal consistency (see CheckConsistency), that the
+// locked value has the length of a block hash, and that the state file, if any, is writable.
+func (signState *SignState) HealthCheck() error {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+
+	if err := signState.lockedCheckConsistency(); err != nil {
+		return err
+	}
+
+	// Custom extractors lock on values of their own shape
+	lock := signState.ConsensusLock
+	_, defaultExtractor := signState.valueExtractor(stepPrecommit).(BlockHashExtractor)
+	if lock.IsLocked() && defaultExtractor && len(lock.Value) > 0 && len(lock.Value) != signState.blockHashLength() {
+		return newInconsistentSignStateError("locked value has length %d", len(lock.Value))
+	}
+
+	if signState.filePath == "" || signState.filePath == os.DevNull {
+		return nil
+	}
+	if err := checkWritable(signState.filePath); err != nil {
+		return fmt.Errorf("sign state file %s is not writable: %w", signState.filePath, err)
+	}
+	return nil
+}
+
+// Weights of the HealthScore penalties.
+const (
+	healthLockAgeStep          = 15 * time.Second
+	healthMaxLockAgePenalty    = 40
+	healthRoundGapPenalty      = 5
+	healthMaxRoundGapPenalty   = 30
+	healthViolationPenalty     = 10
+	healthMaxViolationsPenalty = 30
+)
+
+// HealthScore returns a health score for the consensus lock from 0 (unhealthy) to 100 (healthy),
+// for fleet dashboards. It starts at 100 and subtracts:
+//
+//   - 1 point per 15s of lock age, up to 40, while locked at the last signed height
+//   - 5 points per round signed since the lock round without a release, up to 30, likewise
+//   - 10 points per consensus lock violation blocked at the recent heights, up to 30
+//
+// The result is clamped to [0, 100]. A halted signer scores 0. An unlocked state, or one
+// whose lock is released within a round or two, scores close to 100.
+func (signState *SignState) HealthScore() int {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+
+	if signState.Halted {
+		return 0
+	}
+
+	score := 100
+	lock := signState.ConsensusLock
+	if lock.IsLocked() && lock.Height == signState.Height {
+		age := max(signState.lockedConsensusLockAge(), 0)
+		score -= min(int(age/healthLockAgeStep), healthMaxLockAgePenalty)
+		if gap := signState.Round - lock.Round; gap > 0 {
+			score -= int(min(gap*healthRoundGapPenalty, healthMaxRoundGapPenalty))
+		}
+	}
+	score -= min(signState.violations.total()*healthViolationPenalty, healthMaxViolationsPenalty)
+
+	return max(0, min(score, 100))
+}
+
+// checkWritable returns an error if path cannot be written, or if a file cannot be created
+// next to it, which atomic writes require.
+func checkWritable(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".healthcheck-")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Remove(tmp.Name())
+}
@@ -0,0 +1,148 @@
+package signer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignStateHealthCheck(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+
+	newState := func(t *testing.T) *SignState {
+		ss, err := LoadOrCreateSignState(filepath.Join(t.TempDir(), "sign_state.json"))
+		require.NoError(t, err)
+		ss.Height, ss.Round, ss.Step = 100, 2, stepPrecommit
+		ss.ConsensusLock = ConsensusLock{
+			Height: 100,
+			Round:  1,
+			Value:  blockHash,
+			SetBy:  HRSKey{Height: 100, Round: 1, Step: stepPrecommit},
+		}
+		return ss
+	}
+
+	t.Run("healthy", func(t *testing.T) {
+		ss := newState(t)
+		require.NoError(t, ss.CheckConsistency())
+		require.NoError(t, ss.HealthCheck())
+	})
+
+	testCases := []struct {
+		name    string
+		corrupt func(ss *SignState)
+	}{
+		{"negative HRS", func(ss *SignState) { ss.Round = -1 }},
+		{"lock ahead of signed height", func(ss *SignState) { ss.ConsensusLock.Height = 101 }},
+		{"lock ahead of signed round", func(ss *SignState) { ss.ConsensusLock.Round = 3 }},
+		{"lock set by another round", func(ss *SignState) { ss.ConsensusLock.SetBy.Round = 0 }},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := newState(t)
+			tc.corrupt(ss)
+			var inconsistentErr *InconsistentSignStateError
+			require.ErrorAs(t, ss.CheckConsistency(), &inconsistentErr)
+			require.ErrorAs(t, ss.HealthCheck(), &inconsistentErr)
+		})
+	}
+
+	t.Run("malformed lock value", func(t *testing.T) {
+		ss := newState(t)
+		ss.ConsensusLock.Value = blockHash[:10]
+		require.NoError(t, ss.CheckConsistency())
+		var inconsistentErr *InconsistentSignStateError
+		require.ErrorAs(t, ss.HealthCheck(), &inconsistentErr)
+	})
+
+	t.Run("state file not writable", func(t *testing.T) {
+		ss := newState(t)
+		require.NoError(t, os.RemoveAll(filepath.Dir(ss.filePath)))
+		err := ss.HealthCheck()
+		require.ErrorIs(t, err, os.ErrNotExist)
+		require.ErrorContains(t, err, "not writable")
+	})
+}
+
+func TestDetectRoundMismatch(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+
+	// Matching stored round
+	ss := &SignState{
+		Height: 100, Round: 5, Step: stepPrecommit,
+		ConsensusLock: ConsensusLock{
+			Height: 100, Round: 5, Value: blockHash, SetBy: HRSKey{Height: 100, Round: 5, Step: stepPrecommit},
+		},
+	}
+	mismatch, diff := ss.DetectRoundMismatch()
+	require.False(t, mismatch)
+	require.Zero(t, diff)
+
+	// Stored one less than the round of the precommit that set it
+	ss.ConsensusLock.Round = 4
+	mismatch, diff = ss.DetectRoundMismatch()
+	require.True(t, mismatch)
+	require.Equal(t, int32(1), diff)
+
+	// Without SetBy, a lock round ahead of the last signed round
+	ss.ConsensusLock = ConsensusLock{Height: 100, Round: 6, Value: blockHash}
+	mismatch, diff = ss.DetectRoundMismatch()
+	require.True(t, mismatch)
+	require.Equal(t, int32(-1), diff)
+
+	// Without SetBy, a lock round behind the last signed round may just be an older lock
+	ss.ConsensusLock.Round = 3
+	mismatch, _ = ss.DetectRoundMismatch()
+	require.False(t, mismatch)
+
+	// Nothing to compare without a lock
+	mismatch, _ = (&SignState{Height: 100, Round: 5}).DetectRoundMismatch()
+	require.False(t, mismatch)
+}
+
+func TestHealthScore(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	options := ConsensusLockOptions{Now: func() time.Time { return now }}
+
+	locked := func(signedRound int64, age time.Duration) *SignState {
+		return &SignState{
+			Height: 100, Round: signedRound, Step: stepPrevote,
+			ConsensusLock:        ConsensusLock{Height: 100, Round: 0, Value: lockedValue, UpdatedAt: now.Add(-age)},
+			ConsensusLockOptions: options,
+		}
+	}
+	block := func(ss *SignState, n int) {
+		for i := 0; i < n; i++ {
+			hrs := HRSKey{Height: 100, Round: ss.Round, Step: stepPrevote}
+			require.Error(t, ss.ValidateConsensusLock(hrs, createTestSignBytes(differentValue, stepPrevote), -1))
+		}
+	}
+
+	// Healthy: unlocked, or locked and progressing normally
+	require.Equal(t, 100, (&SignState{Height: 100, Round: 3, Step: stepPrecommit}).HealthScore())
+	require.Equal(t, 100, locked(0, 5*time.Second).HealthScore())
+
+	// A lock held at an older height does not count
+	stale := locked(0, time.Hour)
+	stale.Height = 101
+	require.Equal(t, 100, stale.HealthScore())
+
+	// 10 for the age, 10 for the round gap and 10 for the violation
+	ss := locked(2, 150*time.Second)
+	block(ss, 1)
+	require.Equal(t, 70, ss.HealthScore())
+
+	// Stuck: an old lock held for many rounds that keeps blocking
+	stuck := locked(8, 20*time.Minute)
+	block(stuck, 3)
+	require.Equal(t, 0, stuck.HealthScore())
+
+	// Halted
+	require.Equal(t, 0, (&SignState{Halted: true}).HealthScore())
+}
@@ -0,0 +1,9 @@
+//go:build !mutexcontention
+
+package signer
+
+import "sync"
+
+// signStateMutex is the mutex guarding a SignState. Build with the
+// mutexcontention tag to count contended acquisitions.
+type signStateMutex = sync.RWMutex
@@ -0,0 +1,35 @@
+//go:build mutexcontention
+
+package signer
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var signStateMutexContention = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "horcrux_signstate_mutex_contention_total",
+	Help: "Number of SignState mutex acquisitions that had to wait for another holder",
+})
+
+// signStateMutex is a sync.RWMutex that counts acquisitions that could not
+// proceed immediately. Only built with the mutexcontention tag.
+type signStateMutex struct {
+	sync.RWMutex
+}
+
+func (m *signStateMutex) Lock() {
+	if !m.RWMutex.TryLock() {
+		signStateMutexContention.Inc()
+		m.RWMutex.Lock()
+	}
+}
+
+func (m *signStateMutex) RLock() {
+	if !m.RWMutex.TryRLock() {
+		signStateMutexContention.Inc()
+		m.RWMutex.RLock()
+	}
+}
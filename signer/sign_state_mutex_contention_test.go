@@ -0,0 +1,34 @@
+//go:build mutexcontention
+
+package signer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignStateMutexContention(t *testing.T) {
+	signState := &SignState{}
+	before := testutil.ToFloat64(signStateMutexContention)
+
+	// Uncontended acquisitions are not counted
+	_ = signState.RequireUnlocked()
+	require.Equal(t, before, testutil.ToFloat64(signStateMutexContention))
+
+	signState.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = signState.RequireUnlocked()
+	}()
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(signStateMutexContention) == before+1
+	}, time.Second, time.Millisecond)
+
+	signState.mu.Unlock()
+	<-done
+}
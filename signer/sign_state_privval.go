@@ -0,0 +1,30 @@
+package signer
+
+import (
+	"fmt"
+	"math"
+
+	cometjson "github.com/cometbft/cometbft/libs/json"
+	cometprivval "github.com/cometbft/cometbft/privval"
+)
+
+// ToPrivValidatorStateJSON exports the last signed HRS, sign bytes and signature in the
+// priv_validator_state.json format of the CometBFT file signer, for tooling that expects it.
+// The sign bytes are those last signed: they cannot be rebuilt from the lock value alone,
+// which lacks the chain ID and timestamp. Horcrux only fields such as the consensus lock are
+// not part of the format.
+func (signState *SignState) ToPrivValidatorStateJSON() ([]byte, error) {
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+
+	if signState.Round > math.MaxInt32 {
+		return nil, fmt.Errorf("round %d does not fit the priv validator state format", signState.Round)
+	}
+	return cometjson.MarshalIndent(cometprivval.FilePVLastSignState{
+		Height:    signState.Height,
+		Round:     int32(signState.Round),
+		Step:      signState.Step,
+		Signature: signState.Signature,
+		SignBytes: signState.SignBytes,
+	}, "", "  ")
+}
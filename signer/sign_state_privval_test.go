@@ -0,0 +1,51 @@
+package signer
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	cometjson "github.com/cometbft/cometbft/libs/json"
+	cometprivval "github.com/cometbft/cometbft/privval"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToPrivValidatorStateJSON(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	signBytes := createTestSignBytes(blockHash, stepPrecommit)
+	signState := &SignState{
+		Height:        100,
+		Round:         5,
+		Step:          stepPrecommit,
+		SignBytes:     signBytes,
+		Signature:     []byte("signature"),
+		ConsensusLock: ConsensusLock{Height: 100, Round: 5, Value: blockHash},
+	}
+
+	bz, err := signState.ToPrivValidatorStateJSON()
+	require.NoError(t, err)
+
+	// The field names and encodings are those of CometBFT
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(bz, &fields))
+	require.Equal(t, map[string]any{
+		"height":    "100",
+		"round":     float64(5),
+		"step":      float64(stepPrecommit),
+		"signbytes": signState.SignBytes.String(),
+		"signature": "c2lnbmF0dXJl",
+	}, fields)
+
+	// CometBFT reads it back
+	var lss cometprivval.FilePVLastSignState
+	require.NoError(t, cometjson.Unmarshal(bz, &lss))
+	require.Equal(t, int64(100), lss.Height)
+	require.Equal(t, int32(5), lss.Round)
+	require.Equal(t, stepPrecommit, lss.Step)
+	require.Equal(t, signBytes, []byte(lss.SignBytes))
+	require.Equal(t, []byte("signature"), lss.Signature)
+
+	signState.Round = math.MaxInt32 + 1
+	_, err = signState.ToPrivValidatorStateJSON()
+	require.Error(t, err)
+}
@@ -1,9 +1,16 @@
 package signer
 
 import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
+	cometjson "github.com/cometbft/cometbft/libs/json"
+	cometlog "github.com/cometbft/cometbft/libs/log"
 	"github.com/stretchr/testify/require"
 )
 
@@ -102,3 +109,503 @@ func TestSignStateCheckDiskWG(t *testing.T) {
 		require.Equal(t, numErr, 99)
 	}
 }
+
+func TestSignStateClone(t *testing.T) {
+	filepath := t.TempDir() + "/sign_state.json"
+	ss, err := LoadOrCreateSignState(filepath)
+	require.NoError(t, err)
+
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	ss.Height, ss.Round, ss.Step = 100, 5, stepPrecommit
+	ss.ConsensusLock = ConsensusLock{Height: 100, Round: 5, Value: append([]byte(nil), lockedValue...)}
+
+	clone := ss.Clone()
+	require.Equal(t, ss.ConsensusLock, clone.ConsensusLock)
+
+	// Mutate the clone's lock in place and replace its round.
+	clone.ConsensusLock.Value[0] ^= 0xFF
+	clone.ConsensusLock.Round = 6
+	clone.Height = 101
+
+	require.Equal(t, lockedValue, ss.ConsensusLock.Value)
+	require.Equal(t, int64(5), ss.ConsensusLock.Round)
+	require.Equal(t, int64(100), ss.Height)
+
+	// The clone must be usable on its own.
+	require.NoError(t, clone.Save(SignStateConsensus{Height: 102}, nil))
+	require.Equal(t, int64(100), ss.Height)
+
+	// An unlocked state must remain unlocked when cloned.
+	ss.ConsensusLock = ConsensusLock{}
+	require.False(t, ss.Clone().ConsensusLock.IsLocked())
+}
+
+func TestSignStateCloneTracking(t *testing.T) {
+	blockA := []byte("block_a_hash_123456789012345678901234567890")[:32]
+	blockB := []byte("block_b_hash_123456789012345678901234567890")[:32]
+	ss := &SignState{ConsensusLockOptions: ConsensusLockOptions{
+		ReleaseSteps:     map[int8]bool{stepPrecommit: true},
+		EquivalentValues: [][]byte{append([]byte(nil), blockA...)},
+	}}
+
+	precommit := HRSKey{Height: 100, Round: 0, Step: stepPrecommit}
+	_, err := ss.ValidateAndAdvance(precommit, createTestSignBytes(blockA, stepPrecommit))
+	require.NoError(t, err)
+	require.NoError(t, ss.ValidateConsensusLock(precommit, createTestSignBytes(blockA, stepPrecommit), -1))
+	ss.recordLockSignature(precommit, []byte("signature"))
+
+	// The tracked history is carried over
+	clone := ss.Clone()
+	require.Equal(t, ss.ApprovalsAtHeight(100), clone.ApprovalsAtHeight(100))
+	sig, ok := clone.LockSignature(precommit)
+	require.True(t, ok)
+	require.Equal(t, []byte("signature"), sig)
+	require.Len(t, clone.transitions, 1)
+
+	// But not shared
+	_, err = clone.ValidateAndAdvance(
+		HRSKey{Height: 100, Round: 1, Step: stepPrecommit}, createTestSignBytes(blockB, stepPrecommit))
+	require.NoError(t, err)
+	require.Len(t, clone.transitions, 2)
+	require.Len(t, ss.transitions, 1)
+	require.Equal(t, 2, ss.ApprovalsAtHeight(100))
+	require.Equal(t, 3, clone.ApprovalsAtHeight(100))
+
+	// Nor are the options
+	clone.ReleaseSteps[stepPrevote] = true
+	clone.EquivalentValues[0][0] ^= 0xFF
+	require.False(t, ss.ReleaseSteps[stepPrevote])
+	require.Equal(t, blockA, ss.EquivalentValues[0])
+}
+
+func TestSignStateRoundRegressionWithinHeight(t *testing.T) {
+	ss, err := LoadOrCreateSignState(t.TempDir() + "/sign_state.json")
+	require.NoError(t, err)
+
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	for _, round := range []int64{3, 5} {
+		require.NoError(t, ss.Save(SignStateConsensus{
+			Height:    100,
+			Round:     round,
+			Step:      stepPrevote,
+			SignBytes: createTestSignBytes(blockHash, stepPrevote),
+		}, nil))
+	}
+
+	lastRound, ok := ss.LastRound(100)
+	require.True(t, ok)
+	require.Equal(t, int64(5), lastRound)
+
+	for _, step := range []int8{stepPropose, stepPrevote, stepPrecommit} {
+		err := ss.ValidateConsensusLock(HRSKey{Height: 100, Round: 4, Step: step}, createTestSignBytes(blockHash, step), -1)
+		var regressionErr *RoundRegressionError
+		require.ErrorAs(t, err, &regressionErr)
+	}
+
+	// A request already signed is left to the signature cache
+	require.NoError(t, ss.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 3, Step: stepPrevote}, createTestSignBytes(blockHash, stepPrevote), -1))
+
+	// Same and later rounds are fine
+	require.NoError(t, ss.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 5, Step: stepPrecommit}, createTestSignBytes(blockHash, stepPrecommit), -1))
+	require.NoError(t, ss.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 6, Step: stepPropose}, createTestSignBytes(blockHash, stepPropose), -1))
+}
+
+func TestSignStateRoundTrackerResetsOnNewHeight(t *testing.T) {
+	ss, err := LoadOrCreateSignState(t.TempDir() + "/sign_state.json")
+	require.NoError(t, err)
+
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	require.NoError(t, ss.Save(SignStateConsensus{
+		Height:    100,
+		Round:     5,
+		Step:      stepPrevote,
+		SignBytes: createTestSignBytes(blockHash, stepPrevote),
+	}, nil))
+
+	// A fresh height starts again from round 0
+	require.NoError(t, ss.ValidateConsensusLock(
+		HRSKey{Height: 101, Round: 0, Step: stepPropose}, createTestSignBytes(blockHash, stepPropose), -1))
+
+	require.NoError(t, ss.Save(SignStateConsensus{
+		Height:    101,
+		Round:     0,
+		Step:      stepPropose,
+		SignBytes: createTestSignBytes(blockHash, stepPropose),
+	}, nil))
+
+	_, ok := ss.LastRound(100)
+	require.False(t, ok)
+
+	lastRound, ok := ss.LastRound(101)
+	require.True(t, ok)
+	require.Equal(t, int64(0), lastRound)
+}
+
+func TestSignStateBinaryRoundTrip(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+
+	for _, tc := range []struct {
+		name  string
+		state *SignState
+	}{
+		{name: "empty", state: &SignState{}},
+		{
+			name: "locked",
+			state: &SignState{
+				Height:                 100,
+				Round:                  6,
+				Step:                   stepPrevote,
+				NoncePublic:            []byte("nonce"),
+				Signature:              []byte("signature"),
+				SignBytes:              createTestSignBytes(blockHash, stepPrevote),
+				VoteExtensionSignature: []byte("extension signature"),
+				ConsensusLock: ConsensusLock{
+					Height:    100,
+					Round:     5,
+					Value:     blockHash,
+					UpdatedAt: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+					SetBy:     HRSKey{Height: 100, Round: 5, Step: stepPrecommit},
+					Pinned:    true,
+
+					PartSetHeader: []byte("part_set_header_hash_1234567890ab"),
+				},
+				HighestHRS: HRSKey{Height: 100, Round: 6, Step: stepPrevote},
+				heightLocks: map[int64]ConsensusLock{
+					99:  {Height: 99, Round: 0, Value: blockHash, SetBy: HRSKey{Height: 99, Round: 0, Step: stepPrecommit}},
+					100: {Height: 100, Round: 5, Value: blockHash, SetBy: HRSKey{Height: 100, Round: 5, Step: stepPrecommit}},
+				},
+				heightSigned: map[int64]HRSKey{
+					99:  {Height: 99, Round: 1, Step: stepPrevote},
+					100: {Height: 100, Round: 6, Step: stepPrevote},
+				},
+				transitions: []lockTransition{
+					{
+						time: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+						hrs:  HRSKey{Height: 100, Round: 0, Step: stepPrecommit},
+						to:   ConsensusLock{Height: 100, Round: 0, Value: blockHash},
+					},
+					{
+						time: time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC),
+						hrs:  HRSKey{Height: 100, Round: 5, Step: stepPrecommit},
+						from: ConsensusLock{Height: 100, Round: 0, Value: blockHash},
+						to:   ConsensusLock{Height: 100, Round: 5, Value: blockHash, Pinned: true},
+					},
+				},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			bz, err := tc.state.MarshalBinary()
+			require.NoError(t, err)
+			fromBinary := new(SignState)
+			require.NoError(t, fromBinary.UnmarshalBinary(bz))
+
+			jsonBz, err := cometjson.Marshal(tc.state)
+			require.NoError(t, err)
+			fromJSON := new(SignState)
+			require.NoError(t, cometjson.Unmarshal(jsonBz, fromJSON))
+
+			// Both encodings carry the same logical content
+			binaryJSON, err := cometjson.Marshal(fromBinary)
+			require.NoError(t, err)
+			require.JSONEq(t, string(jsonBz), string(binaryJSON))
+			require.Equal(t, fromJSON.ConsensusLock, fromBinary.ConsensusLock)
+			require.Equal(t, fromJSON.SignBytes, fromBinary.SignBytes)
+			require.Equal(t, tc.state.heightLocks, fromBinary.heightLocks)
+			require.Equal(t, tc.state.heightSigned, fromBinary.heightSigned)
+			require.Equal(t, tc.state.transitions, fromBinary.transitions)
+
+			// The decoded state is ready for use
+			_, ssc := fromBinary.GetFromCache(HRSKey{Height: tc.state.Height, Round: tc.state.Round, Step: tc.state.Step})
+			require.NotNil(t, ssc)
+
+			// Corrupted input is rejected
+			require.Error(t, new(SignState).UnmarshalBinary(bz[:len(bz)-1]))
+			for _, version := range []byte{0, signStateBinaryVersion + 1} {
+				bad := append([]byte{version}, bz[1:]...)
+				require.Error(t, new(SignState).UnmarshalBinary(bad))
+			}
+		})
+	}
+}
+
+func TestSignStateSnapshotRestore(t *testing.T) {
+	ss, err := LoadOrCreateSignState(t.TempDir() + "/sign_state.json")
+	require.NoError(t, err)
+	ss.Now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	otherValue := []byte("other_block_hash_1234567890123456789012345678901")[:32]
+	require.NoError(t, ss.Save(SignStateConsensus{
+		Height:    100,
+		Round:     0,
+		Step:      stepPrecommit,
+		SignBytes: createTestSignBytes(lockedValue, stepPrecommit),
+	}, nil))
+	lock, hrs := ss.ConsensusLock, HRSKey{Height: ss.Height, Round: ss.Round, Step: ss.Step}
+	transitions := ss.lockedCopyTransitions()
+	require.Len(t, transitions, 1)
+
+	snapshot := ss.Snapshot()
+
+	// Progress through several rounds, moving the lock
+	for round := int64(1); round <= 3; round++ {
+		require.NoError(t, ss.Save(SignStateConsensus{
+			Height:    100,
+			Round:     round,
+			Step:      stepPrevote,
+			SignBytes: createTestSignBytes(otherValue, stepPrevote),
+		}, nil))
+		require.NoError(t, ss.Save(SignStateConsensus{
+			Height:    100,
+			Round:     round,
+			Step:      stepPrecommit,
+			SignBytes: createTestSignBytes(otherValue, stepPrecommit),
+		}, nil))
+	}
+	require.Equal(t, otherValue, ss.ConsensusLock.Value)
+
+	store := &memLockStore{}
+	ss.EnableDebouncedSave(store, time.Hour)
+	require.NotEqual(t, transitions, ss.transitions)
+
+	require.NoError(t, ss.Restore(snapshot))
+	require.Equal(t, lock, ss.ConsensusLock)
+	require.Equal(t, hrs, HRSKey{Height: ss.Height, Round: ss.Round, Step: ss.Step})
+
+	// The debounced saver saves the restored lock, and the history is that of the snapshot
+	require.NoError(t, ss.Close())
+	saved, err := store.LoadLock()
+	require.NoError(t, err)
+	require.True(t, sameStoredLock(lock, saved))
+	require.Equal(t, transitions, ss.transitions)
+
+	// The restored state accepts the rounds again
+	require.NoError(t, ss.Save(SignStateConsensus{
+		Height:    100,
+		Round:     1,
+		Step:      stepPrevote,
+		SignBytes: createTestSignBytes(lockedValue, stepPrevote),
+	}, nil))
+
+	require.Error(t, ss.Restore(snapshot[:len(snapshot)-1]))
+}
+
+func TestSignStateSeedFromHeight(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	staleLock := ConsensusLock{Height: 100, Round: 5, Value: lockedValue}
+
+	// Seeding above the current height clears a stale lock
+	ss := &SignState{Height: 100, Round: 5, Step: stepPrecommit, ConsensusLock: staleLock}
+	require.NoError(t, ss.SeedFromHeight(150))
+	require.Equal(t, int64(150), ss.Height)
+	require.Equal(t, int64(0), ss.Round)
+	require.Equal(t, int8(0), ss.Step)
+	require.False(t, ss.ConsensusLock.IsLocked())
+
+	// Signing at the seeded height is allowed, below it is not
+	_, err := ss.CheckHRS(HRSTKey{Height: 150, Round: 0, Step: stepPropose})
+	require.NoError(t, err)
+	_, err = ss.CheckHRS(HRSTKey{Height: 149, Round: 0, Step: stepPropose})
+	require.Error(t, err)
+
+	// Seeding below the current height is refused and changes nothing
+	err = ss.SeedFromHeight(149)
+	var regressionErr *HeightRegressionError
+	require.ErrorAs(t, err, &regressionErr)
+	require.Equal(t, int64(150), ss.Height)
+
+	// A lock at the seeded height is kept
+	currentLock := ConsensusLock{Height: 150, Round: 0, Value: lockedValue}
+	ss.ConsensusLock = currentLock
+	require.NoError(t, ss.SeedFromHeight(150))
+	require.Equal(t, currentLock, ss.ConsensusLock)
+
+	// A pinned stale lock is never cleared
+	pinned := ConsensusLock{Height: 100, Round: 5, Value: lockedValue, Pinned: true}
+	ss = &SignState{Height: 100, Round: 5, Step: stepPrecommit, ConsensusLock: pinned}
+	var pinnedErr *PinnedLockError
+	require.ErrorAs(t, ss.SeedFromHeight(150), &pinnedErr)
+	require.Equal(t, int64(100), ss.Height)
+	require.Equal(t, pinned, ss.ConsensusLock)
+}
+
+func TestSignStateRollbackTo(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	lock := ConsensusLock{Height: 100, Round: 5, Value: lockedValue}
+
+	var buf bytes.Buffer
+	ss := &SignState{Height: 100, Round: 5, Step: stepPrecommit, ConsensusLock: lock}
+	ss.Logger = cometlog.NewTMLogger(cometlog.NewSyncWriter(&buf))
+
+	// Without a proof nothing changes
+	var proofErr *MissingRollbackProofError
+	require.ErrorAs(t, ss.RollbackTo(90, nil), &proofErr)
+	require.Equal(t, int64(100), ss.Height)
+	require.Equal(t, lock, ss.ConsensusLock)
+	require.Empty(t, buf.String())
+
+	require.NoError(t, ss.RollbackTo(90, []byte("fork evidence")))
+	require.Equal(t, int64(90), ss.Height)
+	require.Equal(t, int64(0), ss.Round)
+	require.Equal(t, int8(0), ss.Step)
+	require.False(t, ss.ConsensusLock.IsLocked())
+	require.Contains(t, buf.String(), "Rolling back sign state after proven fork")
+
+	// Signing at the rolled back height is allowed again
+	_, err := ss.CheckHRS(HRSTKey{Height: 90, Round: 0, Step: stepPropose})
+	require.NoError(t, err)
+}
+
+func TestSignStateAlreadySigned(t *testing.T) {
+	ss := &SignState{Height: 100, Round: 2, Step: stepPrevote}
+
+	require.True(t, ss.AlreadySigned(HRSKey{Height: 100, Round: 2, Step: stepPrevote}))
+	require.True(t, ss.AlreadySigned(HRSKey{Height: 100, Round: 2, Step: stepPropose}))
+	require.True(t, ss.AlreadySigned(HRSKey{Height: 99, Round: 7, Step: stepPrecommit}))
+
+	require.False(t, ss.AlreadySigned(HRSKey{Height: 100, Round: 2, Step: stepPrecommit}))
+	require.False(t, ss.AlreadySigned(HRSKey{Height: 100, Round: 3, Step: stepPropose}))
+	require.False(t, ss.AlreadySigned(HRSKey{Height: 101, Round: 0, Step: stepPropose}))
+}
+
+func TestSignStateAdvanceRound(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	differentValue := []byte("different_block_hash_123456789012345678901234")[:32]
+	lock := ConsensusLock{Height: 100, Round: 2, Value: lockedValue}
+	ss := &SignState{
+		Height:        100,
+		Round:         2,
+		Step:          stepPrecommit,
+		SignBytes:     createTestSignBytes(lockedValue, stepPrecommit),
+		Signature:     []byte("signature"),
+		ConsensusLock: lock,
+	}
+
+	ss.AdvanceRound()
+	require.Equal(t, int64(100), ss.Height)
+	require.Equal(t, int64(3), ss.Round)
+	require.Equal(t, stepPropose, ss.Step)
+	require.Equal(t, lock, ss.ConsensusLock)
+
+	// The lock still applies in the new round
+	hrs := HRSKey{Height: 100, Round: 3, Step: stepPrevote}
+	require.False(t, ss.AlreadySigned(hrs))
+	require.NoError(t, ss.ValidateConsensusLock(hrs, createTestSignBytes(lockedValue, stepPrevote), -1))
+	require.True(t, IsConsensusLockViolationError(
+		ss.ValidateConsensusLock(hrs, createTestSignBytes(differentValue, stepPrevote), -1)))
+}
+
+func TestSignStateSaveVerifiesEncoding(t *testing.T) {
+	lockedValue := []byte("locked_block_hash_123456789012345678901234567890")[:32]
+	statePath := filepath.Join(t.TempDir(), "sign_state.json")
+	ss, err := LoadOrCreateSignState(statePath)
+	require.NoError(t, err)
+
+	// An encoder that drops the lock round
+	encoder := signStateEncoder
+	t.Cleanup(func() { signStateEncoder = encoder })
+	signStateEncoder = func(state *SignState) ([]byte, error) {
+		bz, err := cometjson.Marshal(state)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]any
+		if err := json.Unmarshal(bz, &fields); err != nil {
+			return nil, err
+		}
+		if lock, ok := fields["consensus_lock"].(map[string]any); ok {
+			delete(lock, "round")
+		}
+		return json.Marshal(fields)
+	}
+
+	// Unlocked states are unaffected
+	require.NoError(t, ss.Save(SignStateConsensus{
+		Height:    100,
+		Round:     5,
+		Step:      stepPrevote,
+		SignBytes: createTestSignBytes(lockedValue, stepPrevote),
+	}, nil))
+
+	err = ss.Save(SignStateConsensus{
+		Height:    100,
+		Round:     5,
+		Step:      stepPrecommit,
+		SignBytes: createTestSignBytes(lockedValue, stepPrecommit),
+	}, nil)
+	var mismatchErr *SerializationMismatchError
+	require.ErrorAs(t, err, &mismatchErr)
+	require.Equal(t, int64(5), mismatchErr.Expected.Round)
+	require.Equal(t, int64(0), mismatchErr.Decoded.Round)
+
+	// Neither the HRS nor the lock moved in memory
+	require.Equal(t, stepPrevote, ss.Step)
+	require.False(t, ss.ConsensusLock.IsLocked())
+
+	// The corrupted encoding was not written
+	loaded, err := LoadSignState(statePath)
+	require.NoError(t, err)
+	require.Equal(t, stepPrevote, loaded.Step)
+	require.False(t, loaded.ConsensusLock.IsLocked())
+}
+
+func TestGlobalMonotonicHRS(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+	stateFile := filepath.Join(t.TempDir(), "sign_state.json")
+	ss, err := LoadOrCreateSignState(stateFile)
+	require.NoError(t, err)
+	ss.GlobalMonotonicHRS = true
+
+	sign := func(hrs HRSKey) error {
+		signBytes := createTestSignBytes(blockHash, hrs.Step)
+		if err := ss.ValidateConsensusLock(hrs, signBytes, -1); err != nil {
+			return err
+		}
+		return ss.Save(SignStateConsensus{Height: hrs.Height, Round: hrs.Round, Step: hrs.Step, SignBytes: signBytes}, nil)
+	}
+
+	// Strictly increasing
+	for _, hrs := range []HRSKey{
+		{Height: 100, Round: 0, Step: stepPropose},
+		{Height: 100, Round: 0, Step: stepPrevote},
+		{Height: 100, Round: 0, Step: stepPrecommit},
+		{Height: 100, Round: 1, Step: stepPrevote},
+		{Height: 101, Round: 0, Step: stepPropose},
+	} {
+		require.NoError(t, sign(hrs), "%+v", hrs)
+	}
+	highest := HRSKey{Height: 101, Round: 0, Step: stepPropose}
+	require.Equal(t, highest, ss.HighestHRS)
+
+	// A repeat is rejected
+	err = ss.ValidateConsensusLock(highest, createTestSignBytes(blockHash, stepPropose), -1)
+	var notIncreasingErr *HRSNotIncreasingError
+	require.ErrorAs(t, err, &notIncreasingErr)
+	require.Equal(t, highest, notIncreasingErr.Highest)
+	require.True(t, IsHRSRegressionError(err))
+
+	// Even after a rollback, and after a restart
+	require.NoError(t, ss.RollbackTo(100, []byte("proof")))
+	err = ss.ValidateConsensusLock(HRSKey{Height: 100, Round: 2, Step: stepPropose},
+		createTestSignBytes(blockHash, stepPropose), -1)
+	require.ErrorAs(t, err, &notIncreasingErr)
+
+	loaded, err := LoadSignState(stateFile)
+	require.NoError(t, err)
+	require.Equal(t, highest, loaded.HighestHRS)
+
+	// Not tracked, nor written, by default
+	stateFile = filepath.Join(t.TempDir(), "sign_state.json")
+	ss, err = LoadOrCreateSignState(stateFile)
+	require.NoError(t, err)
+	require.NoError(t, sign(HRSKey{Height: 100, Round: 0, Step: stepPropose}))
+	require.Zero(t, ss.HighestHRS)
+	bz, err := os.ReadFile(stateFile)
+	require.NoError(t, err)
+	require.NotContains(t, string(bz), "highest_hrs")
+}
@@ -0,0 +1,86 @@
+package signer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StateAnalysis describes a persisted SignState, see AnalyzeStateFile.
+type StateAnalysis struct {
+	// HRS is the last signed height, round and step.
+	HRS HRSKey
+	// Lock is the persisted consensus lock.
+	Lock ConsensusLock
+	// LockAge is how long ago the lock was last updated, zero if unlocked or unknown.
+	LockAge time.Duration
+	// Anomalies lists everything about the state that looks wrong. Empty for a healthy state.
+	Anomalies []string
+}
+
+// String formats the analysis as a human readable report.
+func (a *StateAnalysis) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "last signed: %d/%d/%d\n", a.HRS.Height, a.HRS.Round, a.HRS.Step)
+	if a.Lock.IsLocked() {
+		fmt.Fprintf(&sb, "consensus lock: %X at %d/%d, age %s\n", a.Lock.Value, a.Lock.Height, a.Lock.Round, a.LockAge)
+	} else {
+		sb.WriteString("consensus lock: none\n")
+	}
+	for _, anomaly := range a.Anomalies {
+		fmt.Fprintf(&sb, "anomaly: %s\n", anomaly)
+	}
+	return sb.String()
+}
+
+// AnalyzeStateFile loads the SignState persisted at path and reports its HRS, consensus
+// lock and any anomalies, e.g. to find out why a signer refuses to start. It never
+// modifies the file. An error is only returned if the file cannot be loaded at all.
+func AnalyzeStateFile(path string) (*StateAnalysis, error) {
+	signState, err := LoadSignState(path)
+	if err != nil {
+		return nil, err
+	}
+
+	signState.mu.RLock()
+	defer signState.mu.RUnlock()
+
+	analysis := &StateAnalysis{
+		HRS:     signState.lockedHrsKey(),
+		Lock:    signState.ConsensusLock,
+		LockAge: signState.lockedConsensusLockAge(),
+	}
+
+	if err := signState.lockedCheckConsistency(); err != nil {
+		analysis.Anomalies = append(analysis.Anomalies, err.Error())
+	}
+
+	if lock := signState.ConsensusLock; lock.IsLocked() {
+		if !IsValidBlockHash(lock.Value, true) {
+			analysis.Anomalies = append(analysis.Anomalies,
+				fmt.Sprintf("locked value has length %d", len(lock.Value)))
+		}
+		switch {
+		case lock.UpdatedAt.IsZero():
+			analysis.Anomalies = append(analysis.Anomalies, "lock has no update time")
+		case analysis.LockAge < 0:
+			analysis.Anomalies = append(analysis.Anomalies,
+				fmt.Sprintf("lock updated in the future at %s", lock.UpdatedAt.Format(time.RFC3339)))
+		}
+	}
+
+	// The last sign bytes must be for the last signed HRS
+	if len(signState.SignBytes) > 0 {
+		decoded, err := signState.decode(signState.Step, signState.SignBytes)
+		switch {
+		case err != nil:
+			analysis.Anomalies = append(analysis.Anomalies, fmt.Sprintf("last sign bytes do not decode: %v", err))
+		case decoded.height != signState.Height || decoded.round != signState.Round:
+			analysis.Anomalies = append(analysis.Anomalies, fmt.Sprintf(
+				"last sign bytes are for %d/%d, not %d/%d",
+				decoded.height, decoded.round, signState.Height, signState.Round))
+		}
+	}
+
+	return analysis, nil
+}
@@ -0,0 +1,61 @@
+package signer
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeStateFile(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+
+	t.Run("consistent", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sign_state.json")
+		ss, err := LoadOrCreateSignState(path)
+		require.NoError(t, err)
+		require.NoError(t, ss.Save(SignStateConsensus{
+			Height:    100,
+			Round:     5,
+			Step:      stepPrecommit,
+			SignBytes: createTestSignBytes(blockHash, stepPrecommit),
+		}, nil))
+
+		analysis, err := AnalyzeStateFile(path)
+		require.NoError(t, err)
+		require.Empty(t, analysis.Anomalies)
+		require.Equal(t, HRSKey{Height: 100, Round: 5, Step: stepPrecommit}, analysis.HRS)
+		require.Equal(t, blockHash, analysis.Lock.Value)
+		require.GreaterOrEqual(t, analysis.LockAge, time.Duration(0))
+		require.Contains(t, analysis.String(), "last signed: 100/5/3")
+	})
+
+	t.Run("anomalous", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sign_state.json")
+		saveSignState(&SignState{
+			Height:    100,
+			Round:     2,
+			Step:      stepPrecommit,
+			SignBytes: createTestSignBytes(blockHash, stepPrecommit),
+			ConsensusLock: ConsensusLock{
+				Height: 101,
+				Round:  0,
+				Value:  blockHash,
+			},
+			filePath: path,
+		})
+
+		analysis, err := AnalyzeStateFile(path)
+		require.NoError(t, err)
+		require.Len(t, analysis.Anomalies, 3)
+		require.Contains(t, analysis.Anomalies[0], "ahead of last signed")
+		require.Equal(t, "lock has no update time", analysis.Anomalies[1])
+		require.Equal(t, "last sign bytes are for 100/5, not 100/2", analysis.Anomalies[2])
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := AnalyzeStateFile(filepath.Join(t.TempDir(), "sign_state.json"))
+		require.Error(t, err)
+	})
+}
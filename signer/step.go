@@ -0,0 +1,373 @@
+package signer
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/cometbft/cometbft/crypto/tmhash"
+	"github.com/cometbft/cometbft/libs/protoio"
+	cometproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lockSemantics describes how signing a step interacts with the consensus lock.
+type lockSemantics int
+
+const (
+	// lockUnconstrained steps are never checked against the lock.
+	lockUnconstrained lockSemantics = iota
+	// lockConstrained steps may only sign the locked value in rounds >= the lock round.
+	lockConstrained
+	// lockReleasing steps set the lock, or move it to a new value in a later round.
+	lockReleasing
+)
+
+// stepSemantics describes a signing step: its name, how its canonical sign
+// bytes are decoded, and its consensus lock semantics.
+type stepSemantics struct {
+	name   string
+	decode func(signBytes []byte) (decodedSignBytes, error)
+	lock   lockSemantics
+	// msgType is the type the decoded message must have. UnknownType accepts any type.
+	msgType cometproto.SignedMsgType
+}
+
+// stepRegistry holds the semantics of every known step. Supporting a new step
+// only requires a new step constant and an entry here: decoding, lock checks, names
+// and the mapping between steps and message types are all derived from it.
+var stepRegistry = map[int8]stepSemantics{
+	stepPropose: {
+		name: "proposal", decode: decodeCanonicalProposal, lock: lockConstrained, msgType: cometproto.ProposalType,
+	},
+	stepPrevote: {
+		name: "prevote", decode: decodeCanonicalVote, lock: lockConstrained, msgType: cometproto.PrevoteType,
+	},
+	stepPrecommit: {
+		name: "precommit", decode: decodeCanonicalVote, lock: lockReleasing, msgType: cometproto.PrecommitType,
+	},
+}
+
+// stepLockSemantics returns the lock semantics of step. Unknown steps are unconstrained.
+func stepLockSemantics(step int8) lockSemantics {
+	return stepRegistry[step].lock
+}
+
+// StepEncoding selects how steps map to names and signed message types, which may
+// differ between Tendermint and CometBFT versions.
+type StepEncoding int
+
+const (
+	// StepEncodingCometBFT is the encoding of CometBFT 0.37 and later, as held by the step
+	// registry. It is the default.
+	StepEncodingCometBFT StepEncoding = iota
+	// StepEncodingTendermint034 is the encoding of Tendermint 0.34.
+	StepEncodingTendermint034
+)
+
+// encodedStep is the name and signed message type of a step in a StepEncoding.
+type encodedStep struct {
+	name    string
+	msgType cometproto.SignedMsgType
+}
+
+// stepEncodings holds the steps of every StepEncoding other than the default. Tendermint
+// 0.34 uses the same values as CometBFT 0.37, but lists them on its own so that a version
+// that differs only needs its own entry here.
+var stepEncodings = map[StepEncoding]map[int8]encodedStep{
+	StepEncodingTendermint034: {
+		stepPropose:   {name: "proposal", msgType: cometproto.ProposalType},
+		stepPrevote:   {name: "prevote", msgType: cometproto.PrevoteType},
+		stepPrecommit: {name: "precommit", msgType: cometproto.PrecommitType},
+	},
+}
+
+// step returns the name and signed message type of step in e.
+func (e StepEncoding) step(step int8) (encodedStep, bool) {
+	if e == StepEncodingCometBFT {
+		semantics, ok := stepRegistry[step]
+		return encodedStep{name: semantics.name, msgType: semantics.msgType}, ok
+	}
+	encoded, ok := stepEncodings[e][step]
+	return encoded, ok
+}
+
+// MsgType returns the signed message type of step in e. It returns false for an unknown
+// step, or one without a message type.
+func (e StepEncoding) MsgType(step int8) (cometproto.SignedMsgType, bool) {
+	encoded, ok := e.step(step)
+	if !ok || encoded.msgType == cometproto.UnknownType {
+		return cometproto.UnknownType, false
+	}
+	return encoded.msgType, true
+}
+
+// StepName returns the name of step in e, e.g. "prevote", or "unknown".
+func (e StepEncoding) StepName(step int8) string {
+	if encoded, ok := e.step(step); ok {
+		return encoded.name
+	}
+	return "unknown"
+}
+
+// ParseStep returns the step named name in e.
+func (e StepEncoding) ParseStep(name string) (int8, error) {
+	if e == StepEncodingCometBFT {
+		for step, semantics := range stepRegistry {
+			if semantics.name == name {
+				return step, nil
+			}
+		}
+	}
+	for step, encoded := range stepEncodings[e] {
+		if encoded.name == name {
+			return step, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown step %q", name)
+}
+
+// msgTypeToStep returns the step registered with the message type t.
+func msgTypeToStep(t cometproto.SignedMsgType) (int8, bool) {
+	for step, semantics := range stepRegistry {
+		if t != cometproto.UnknownType && semantics.msgType == t {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// decodedSignBytes holds the fields of a canonical proposal or vote.
+type decodedSignBytes struct {
+	msgType   cometproto.SignedMsgType
+	chainID   string
+	height    int64
+	round     int64
+	polRound  int64
+	timestamp time.Time
+	blockID   *cometproto.CanonicalBlockID
+}
+
+// decodeSecondsObserver observes the duration of every decodeCanonical call.
+var decodeSecondsObserver prometheus.Observer = signBytesDecodeSeconds
+
+// decodeCanonical decodes the canonical proposal or vote in signBytes for step, in the
+// default StepEncoding. Errors are returned as a *DecodeError.
+func decodeCanonical(signBytes []byte, step int8) (decodedSignBytes, error) {
+	return decodeCanonicalWith(signBytes, step, 0, StepEncodingCometBFT)
+}
+
+// decode is like decodeCanonical, but in the StepEncoding of opts.
+func (opts ConsensusLockOptions) decode(step int8, signBytes []byte) (decodedSignBytes, error) {
+	return decodeCanonicalWith(signBytes, step, 0, opts.StepEncoding)
+}
+
+// decodeCanonicalWith is like decodeCanonical, but the message type must be that of step in
+// encoding, and if hashLength is non-zero, block hashes must have that length instead of that
+// of a CometBFT hash. A hash of another length is then rejected with a *HashLengthError.
+func decodeCanonicalWith(signBytes []byte, step int8, hashLength int, encoding StepEncoding) (decodedSignBytes, error) {
+	timer := prometheus.NewTimer(decodeSecondsObserver)
+	decoded, err := decodeSignBytes(signBytes, step, hashLength, encoding)
+	timer.ObserveDuration()
+	if err != nil {
+		return decodedSignBytes{}, newDecodeError(step, err)
+	}
+	return decoded, nil
+}
+
+func decodeSignBytes(signBytes []byte, step int8, hashLength int, encoding StepEncoding) (decodedSignBytes, error) {
+	if len(signBytes) == 0 {
+		return decodedSignBytes{}, fmt.Errorf("empty sign bytes")
+	}
+
+	semantics, ok := stepRegistry[step]
+	if !ok || semantics.decode == nil {
+		return decodedSignBytes{}, fmt.Errorf("unknown step: %d", step)
+	}
+	decoded, err := semantics.decode(signBytes)
+	if err != nil {
+		return decodedSignBytes{}, err
+	}
+	if msgType, ok := encoding.MsgType(step); ok && decoded.msgType != msgType {
+		return decodedSignBytes{}, newStepTypeMismatchError(step, msgType, decoded.msgType)
+	}
+	if err := decoded.checkPlausible(step, hashLength); err != nil {
+		return decodedSignBytes{}, err
+	}
+	return decoded, nil
+}
+
+// checkPlausible guards against sign bytes that decode without error but into
+// values no real proposal or vote carries, e.g. after the canonical proto shape
+// changes underneath us. Block hashes must have length hashLength, if non-zero.
+func (d decodedSignBytes) checkPlausible(step int8, hashLength int) error {
+	if d.height < 0 {
+		return newSuspiciousDecodeError("height", fmt.Sprintf("negative height %d", d.height))
+	}
+	if d.round < 0 {
+		return newSuspiciousDecodeError("round", fmt.Sprintf("negative round %d", d.round))
+	}
+	hash := d.blockID.GetHash()
+	if hashLength > 0 && len(hash) > 0 && len(hash) != hashLength {
+		return newHashLengthError(step, len(hash), hashLength)
+	}
+	if hashLength == 0 && !IsValidBlockHash(hash, true) {
+		return newSuspiciousDecodeError("block_id.hash", fmt.Sprintf("hash length %d, expected %d", len(hash), tmhash.Size))
+	}
+	return nil
+}
+
+// IsValidBlockHash returns true if b has the length of a block hash. An empty b,
+// as carried by votes for nil, is only valid if allowEmpty is set.
+func IsValidBlockHash(b []byte, allowEmpty bool) bool {
+	if len(b) == 0 {
+		return allowEmpty
+	}
+	return len(b) == tmhash.Size
+}
+
+// DecodeError is returned when sign bytes cannot be decoded, or decode into something
+// implausible (see SuspiciousDecodeError). Its message is that of the underlying error.
+type DecodeError struct {
+	Step int8
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func newDecodeError(step int8, err error) *DecodeError {
+	return &DecodeError{
+		Step: step,
+		Err:  err,
+	}
+}
+
+// StepTypeMismatchError is returned when sign bytes decode to a message of a
+// different type than the step they are signed for, e.g. a precommit passed as a prevote.
+type StepTypeMismatchError struct {
+	Step     int8
+	Expected cometproto.SignedMsgType
+	Got      cometproto.SignedMsgType
+}
+
+func (e *StepTypeMismatchError) Error() string {
+	return fmt.Sprintf("%s sign bytes have type %s, expected %s", signType(e.Step), e.Got, e.Expected)
+}
+
+func newStepTypeMismatchError(step int8, expected, got cometproto.SignedMsgType) *StepTypeMismatchError {
+	return &StepTypeMismatchError{
+		Step:     step,
+		Expected: expected,
+		Got:      got,
+	}
+}
+
+// SuspiciousDecodeError is returned when sign bytes decode successfully but
+// yield an implausible result. Such sign bytes are never acted on.
+type SuspiciousDecodeError struct {
+	Field  string
+	Reason string
+}
+
+func (e *SuspiciousDecodeError) Error() string {
+	return fmt.Sprintf("suspicious decode of %s: %s", e.Field, e.Reason)
+}
+
+func newSuspiciousDecodeError(field, reason string) *SuspiciousDecodeError {
+	return &SuspiciousDecodeError{
+		Field:  field,
+		Reason: reason,
+	}
+}
+
+func decodeCanonicalProposal(signBytes []byte) (decodedSignBytes, error) {
+	var proposal cometproto.CanonicalProposal
+	if err := protoio.UnmarshalDelimited(signBytes, &proposal); err != nil {
+		return decodedSignBytes{}, fmt.Errorf("failed to unmarshal proposal: %w", err)
+	}
+	return decodedSignBytes{
+		msgType:   proposal.Type,
+		chainID:   proposal.ChainID,
+		height:    proposal.Height,
+		round:     proposal.Round,
+		polRound:  proposal.POLRound,
+		timestamp: proposal.Timestamp,
+		blockID:   proposal.BlockID,
+	}, nil
+}
+
+func decodeCanonicalVote(signBytes []byte) (decodedSignBytes, error) {
+	var vote cometproto.CanonicalVote
+	if err := protoio.UnmarshalDelimited(signBytes, &vote); err != nil {
+		return decodedSignBytes{}, fmt.Errorf("failed to unmarshal vote: %w", err)
+	}
+	return decodedSignBytes{
+		msgType:   vote.Type,
+		chainID:   vote.ChainID,
+		height:    vote.Height,
+		round:     vote.Round,
+		polRound:  -1,
+		timestamp: vote.Timestamp,
+		blockID:   vote.BlockID,
+	}, nil
+}
+
+// LockRule is one row of the consensus lock behavior table: the outcome of
+// signing a step while locked, for the locked value or a different one, in the
+// lock round or a later round.
+type LockRule struct {
+	Step           string
+	SameValue      bool
+	DifferentValue bool
+	LaterRound     bool
+	Outcome        string // "allow", "block" or "release"
+	Note           string
+}
+
+// DescribeLockRules returns the consensus lock behavior table for every known
+// step, as enforced by ValidateConsensusLock and AdvanceConsensusLock.
+func DescribeLockRules() []LockRule {
+	steps := make([]int8, 0, len(stepRegistry))
+	for step := range stepRegistry {
+		steps = append(steps, step)
+	}
+	slices.Sort(steps)
+
+	var rules []LockRule
+	for _, step := range steps {
+		semantics := stepRegistry[step]
+		for _, sameValue := range []bool{true, false} {
+			for _, laterRound := range []bool{false, true} {
+				rule := LockRule{
+					Step:           semantics.name,
+					SameValue:      sameValue,
+					DifferentValue: !sameValue,
+					LaterRound:     laterRound,
+					Outcome:        "allow",
+				}
+				switch {
+				case sameValue:
+				case semantics.lock == lockConstrained:
+					rule.Outcome = "block"
+					if step == stepPrevote {
+						rule.Note = "allowed if the POL round is later than the lock round"
+					}
+				case semantics.lock == lockReleasing && laterRound:
+					rule.Outcome = "release"
+					rule.Note = "the lock moves to the new value in this round"
+				case semantics.lock == lockReleasing:
+					rule.Outcome = "block"
+					rule.Note = "a different value in the lock round is equivocation"
+				}
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return rules
+}
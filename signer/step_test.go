@@ -0,0 +1,208 @@
+package signer
+
+import (
+	"testing"
+
+	cometproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsValidBlockHash(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		hash       []byte
+		allowEmpty bool
+		valid      bool
+	}{
+		{"31 bytes", make([]byte, 31), false, false},
+		{"32 bytes", make([]byte, 32), false, true},
+		{"33 bytes", make([]byte, 33), false, false},
+		{"empty", []byte{}, false, false},
+		{"nil", nil, false, false},
+		{"empty allowed", []byte{}, true, true},
+		{"nil allowed", nil, true, true},
+		{"31 bytes with empty allowed", make([]byte, 31), true, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.valid, IsValidBlockHash(tc.hash, tc.allowEmpty))
+		})
+	}
+}
+
+func TestDescribeLockRules(t *testing.T) {
+	rules := DescribeLockRules()
+	require.Len(t, rules, len(stepRegistry)*4)
+
+	require.Contains(t, rules, LockRule{
+		Step:           "precommit",
+		DifferentValue: true,
+		LaterRound:     true,
+		Outcome:        "release",
+		Note:           "the lock moves to the new value in this round",
+	})
+
+	for _, rule := range rules {
+		require.NotEqual(t, rule.SameValue, rule.DifferentValue)
+		if rule.SameValue {
+			require.Equal(t, "allow", rule.Outcome, "%+v", rule)
+		}
+		if rule.Step == "proposal" && rule.DifferentValue {
+			require.Equal(t, "block", rule.Outcome, "%+v", rule)
+		}
+	}
+}
+
+func TestDecodeStepTypeMismatch(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+
+	testCases := []struct {
+		name      string
+		step      int8
+		signBytes []byte
+		expected  cometproto.SignedMsgType
+		got       cometproto.SignedMsgType
+	}{
+		{
+			"precommit as prevote", stepPrevote, createTestSignBytes(blockHash, stepPrecommit),
+			cometproto.PrevoteType, cometproto.PrecommitType,
+		},
+		{
+			"prevote as precommit", stepPrecommit, createTestSignBytes(blockHash, stepPrevote),
+			cometproto.PrecommitType, cometproto.PrevoteType,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decodeCanonical(tc.signBytes, tc.step)
+			var mismatchErr *StepTypeMismatchError
+			require.ErrorAs(t, err, &mismatchErr)
+			require.Equal(t, tc.expected, mismatchErr.Expected)
+			require.Equal(t, tc.got, mismatchErr.Got)
+		})
+	}
+
+	// Matching pairs decode
+	for _, step := range []int8{stepPropose, stepPrevote, stepPrecommit} {
+		_, err := decodeCanonical(createTestSignBytes(blockHash, step), step)
+		require.NoError(t, err)
+	}
+
+	// The mismatch is caught when validating against a lock
+	signState := &SignState{ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: blockHash}}
+	err := signState.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 1, Step: stepPrevote}, createTestSignBytes(blockHash, stepPrecommit), -1)
+	var mismatchErr *StepTypeMismatchError
+	require.ErrorAs(t, err, &mismatchErr)
+}
+
+// countingObserver counts observations without recording them.
+type countingObserver struct {
+	count int
+}
+
+func (o *countingObserver) Observe(float64) {
+	o.count++
+}
+
+func TestDecodeCanonicalObservesDuration(t *testing.T) {
+	blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+
+	observer := &countingObserver{}
+	decodeSecondsObserver = observer
+	t.Cleanup(func() { decodeSecondsObserver = signBytesDecodeSeconds })
+
+	for _, step := range []int8{stepPropose, stepPrevote, stepPrecommit} {
+		_, err := decodeCanonical(createTestSignBytes(blockHash, step), step)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, observer.count)
+
+	// Failed decodes are observed too
+	_, err := decodeCanonical([]byte("garbage"), stepPrevote)
+	require.Error(t, err)
+	require.Equal(t, 4, observer.count)
+
+	// Consensus lock validation observes each decode once
+	signState := &SignState{
+		ConsensusLock: ConsensusLock{Height: 100, Round: 0, Value: blockHash},
+	}
+	require.NoError(t, signState.ValidateConsensusLock(
+		HRSKey{Height: 100, Round: 1, Step: stepPrevote}, createTestSignBytes(blockHash, stepPrevote), -1))
+	require.Equal(t, 5, observer.count)
+}
+
+func TestExportedStepConstants(t *testing.T) {
+	require.Equal(t, stepPropose, StepPropose)
+	require.Equal(t, stepPrevote, StepPrevote)
+	require.Equal(t, stepPrecommit, StepPrecommit)
+	require.Equal(t, cometproto.ProposalType, StepToType(StepPropose))
+	require.Equal(t, cometproto.PrevoteType, StepToType(StepPrevote))
+	require.Equal(t, cometproto.PrecommitType, StepToType(StepPrecommit))
+}
+
+func TestStepMessageTypesFromRegistry(t *testing.T) {
+	for step, semantics := range stepRegistry {
+		require.Equal(t, semantics.msgType, StepToType(step))
+	}
+	require.Equal(t, stepPrevote, CanonicalVoteToStep(&cometproto.CanonicalVote{Type: cometproto.PrevoteType}))
+	require.Equal(t, stepPrecommit, VoteToStep(&cometproto.Vote{Type: cometproto.PrecommitType}))
+
+	// Proposals are not votes, even though their type is registered
+	require.Panics(t, func() { CanonicalVoteToStep(&cometproto.CanonicalVote{Type: cometproto.ProposalType}) })
+	require.Panics(t, func() { VoteToStep(&cometproto.Vote{Type: cometproto.UnknownType}) })
+
+	// A registered step without a message type has none to map to
+	const stepCommit int8 = 4
+	stepRegistry[stepCommit] = stepSemantics{name: "commit", decode: decodeCanonicalVote, lock: lockReleasing}
+	defer delete(stepRegistry, stepCommit)
+	require.Panics(t, func() { StepToType(stepCommit) })
+	require.Panics(t, func() { StepToType(5) })
+}
+
+func TestStepEncodings(t *testing.T) {
+	for _, encoding := range []StepEncoding{StepEncodingCometBFT, StepEncodingTendermint034} {
+		for _, tc := range []struct {
+			step    int8
+			name    string
+			msgType cometproto.SignedMsgType
+		}{
+			{step: stepPropose, name: "proposal", msgType: cometproto.ProposalType},
+			{step: stepPrevote, name: "prevote", msgType: cometproto.PrevoteType},
+			{step: stepPrecommit, name: "precommit", msgType: cometproto.PrecommitType},
+		} {
+			msgType, ok := encoding.MsgType(tc.step)
+			require.True(t, ok)
+			require.Equal(t, tc.msgType, msgType)
+			require.Equal(t, tc.name, encoding.StepName(tc.step))
+			step, err := encoding.ParseStep(tc.name)
+			require.NoError(t, err)
+			require.Equal(t, tc.step, step)
+		}
+
+		_, ok := encoding.MsgType(4)
+		require.False(t, ok)
+		require.Equal(t, "unknown", encoding.StepName(4))
+		_, err := encoding.ParseStep("commit")
+		require.Error(t, err)
+
+		// Decoding checks the message type of the encoding
+		signState := &SignState{ConsensusLockOptions: ConsensusLockOptions{StepEncoding: encoding}}
+		blockHash := []byte("block_hash_123456789012345678901234567890")[:32]
+		value, err := signState.extractValue(stepPrevote, createTestSignBytes(blockHash, stepPrevote))
+		require.NoError(t, err)
+		require.Equal(t, blockHash, value)
+		var mismatchErr *StepTypeMismatchError
+		_, err = signState.extractValue(stepPrevote, createTestSignBytes(blockHash, stepPrecommit))
+		require.ErrorAs(t, err, &mismatchErr)
+	}
+
+	// Only the default encoding follows the step registry
+	const stepCommit int8 = 4
+	stepRegistry[stepCommit] = stepSemantics{name: "commit", decode: decodeCanonicalVote, lock: lockReleasing}
+	defer delete(stepRegistry, stepCommit)
+	require.Equal(t, "commit", StepEncodingCometBFT.StepName(stepCommit))
+	require.Equal(t, "unknown", StepEncodingTendermint034.StepName(stepCommit))
+}
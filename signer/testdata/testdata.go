@@ -6,3 +6,6 @@ import (
 
 //go:embed rsa_keys.json
 var RSAKeys []byte
+
+//go:embed consensus_replay.bin
+var ConsensusReplay []byte
@@ -388,6 +388,13 @@ func (pv *ThresholdValidator) LoadSignStateIfNecessary(chainID string) error {
 		return err
 	}
 
+	opts, err := pv.config.ConsensusLockOptions(chainID)
+	if err != nil {
+		return err
+	}
+	opts.Logger = pv.logger
+	signState.ConsensusLockOptions = opts
+
 	lastSignStateInitiated := signState.FreshCache()
 	lastSignStateInitiated.filePath = os.DevNull
 
@@ -679,8 +686,16 @@ func (pv *ThresholdValidator) Sign(
 	css := pv.mustLoadChainState(chainID)
 
 	// Check for consensus lock violations before proceeding
-	// Use POL round validation
-	if err := css.lastSignState.ValidateConsensusLock(block.HRSKey(), signBytes, block.PolRound); err != nil {
+	// Use POL round validation, and our address as the proposer of proposals
+	policyCtx := WithPolRound(ctx, block.PolRound)
+	if step == stepPropose {
+		pubKey, err := pv.myCosigner.GetPubKey(chainID)
+		if err != nil {
+			return nil, nil, stamp, err
+		}
+		policyCtx = WithProposer(policyCtx, pubKey.Address())
+	}
+	if err := signingPolicy(css.lastSignState).Check(policyCtx, block.HRSKey(), signBytes); err != nil {
 		// Log the specific consensus lock violation with detailed context
 		log.Error("Consensus lock violation detected in threshold validator",
 			"chain_id", chainID,
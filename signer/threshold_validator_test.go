@@ -44,6 +44,47 @@ func TestThresholdValidator3of5(t *testing.T) {
 	testThresholdValidator(t, 3, 5)
 }
 
+func TestThresholdValidatorRepeatedRequest(t *testing.T) {
+	cosigners, pubKey := getTestLocalCosigners(t, 2, 3)
+
+	leader := &MockLeader{id: 1}
+	validator := NewThresholdValidator(
+		cometlog.NewNopLogger(),
+		cosigners[0].config,
+		2,
+		time.Second,
+		1,
+		cosigners[0],
+		[]Cosigner{cosigners[1]},
+		leader,
+	)
+	defer validator.Stop()
+	leader.leader = validator
+
+	ctx := context.Background()
+	require.NoError(t, validator.LoadSignStateIfNecessary(testChainID))
+
+	sign := func(round int32) []byte {
+		validator.nonceCache.LoadN(ctx, 1)
+		block := VoteToBlock(testChainID, &cometproto.Vote{
+			Height:    1,
+			Round:     round,
+			Type:      cometproto.PrevoteType,
+			Timestamp: time.Unix(1700000000, 0).UTC(),
+		})
+		signature, _, _, err := validator.Sign(ctx, testChainID, block)
+		require.NoError(t, err)
+		require.True(t, pubKey.VerifySignature(block.SignBytes, signature))
+		return signature
+	}
+
+	firstSignature := sign(0)
+	sign(1)
+
+	// A sentry asking again for an HRS already signed gets the cached signature
+	require.Equal(t, firstSignature, sign(0))
+}
+
 func loadKeyForLocalCosigner(
 	cosigner *LocalCosigner,
 	pubKey cometcrypto.PubKey,
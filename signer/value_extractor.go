@@ -0,0 +1,119 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ValueType is the kind of value extracted from sign bytes.
+type ValueType string
+
+const (
+	// ValueTypeBlock is a block hash.
+	ValueTypeBlock ValueType = "block"
+	// ValueTypeNil is the empty value of a vote for nil.
+	ValueTypeNil ValueType = "nil"
+)
+
+// Valid returns true if t is a known value type.
+func (t ValueType) Valid() bool {
+	return t == ValueTypeBlock || t == ValueTypeNil
+}
+
+// ValueExtractor extracts the value tracked by the consensus lock from sign bytes.
+// It returns the value and its type, e.g. ValueTypeBlock for a block hash. The value
+// of a vote for nil must be empty.
+type ValueExtractor interface {
+	Extract(step int8, signBytes []byte) ([]byte, ValueType, error)
+}
+
+// BlockHashExtractor is the default ValueExtractor. It extracts the block hash
+// from canonical CometBFT proposal and vote sign bytes.
+type BlockHashExtractor struct {
+	// HashLength, if non-zero, is the length block hashes must have instead of that of a
+	// CometBFT hash. Hashes of another length are rejected with a *HashLengthError when
+	// decoded. ConsensusLockOptions sets it to ValueLength.
+	HashLength int
+
+	// StepEncoding is the encoding the message type of sign bytes is checked against.
+	// ConsensusLockOptions sets it to its StepEncoding.
+	StepEncoding StepEncoding
+}
+
+// Extract implements ValueExtractor. The type is ValueTypeNil for a vote for nil and ValueTypeBlock otherwise.
+func (e BlockHashExtractor) Extract(step int8, signBytes []byte) ([]byte, ValueType, error) {
+	hash, err := extractBlockHash(signBytes, step, e.HashLength, e.StepEncoding)
+	if errors.Is(err, errNoBlockID) && step != stepPropose {
+		return nil, ValueTypeNil, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if len(hash) == 0 {
+		return hash, ValueTypeNil, nil
+	}
+	return hash, ValueTypeBlock, nil
+}
+
+// valueExtractor returns the ValueExtractor for step: the one registered for the step in
+// StepValueExtractors, else ValueExtractor, else BlockHashExtractor checking ValueLength.
+func (opts ConsensusLockOptions) valueExtractor(step int8) ValueExtractor {
+	if extractor, ok := opts.StepValueExtractors[step]; ok && extractor != nil {
+		return extractor
+	}
+	if opts.ValueExtractor != nil {
+		return opts.ValueExtractor
+	}
+	return BlockHashExtractor{HashLength: opts.ValueLength, StepEncoding: opts.StepEncoding}
+}
+
+// extractValue extracts the lock value from sign bytes with the ValueExtractor for step.
+// Values of an unknown type are rejected with an *UnknownValueTypeError unless
+// AllowUnknownValueTypes is set. With ValueLength set, block values of another length
+// are rejected with a *HashLengthError, by the default extractor as soon as they are decoded.
+func (opts ConsensusLockOptions) extractValue(step int8, signBytes []byte) ([]byte, error) {
+	value, valueType, err := opts.valueExtractor(step).Extract(step, signBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !valueType.Valid() && !opts.AllowUnknownValueTypes {
+		return nil, newUnknownValueTypeError(valueType)
+	}
+	if opts.ValueLength > 0 && valueType == ValueTypeBlock && len(value) != opts.ValueLength {
+		return nil, newHashLengthError(step, len(value), opts.ValueLength)
+	}
+	return value, nil
+}
+
+// HashLengthError is returned when a block value does not have the length set by ValueLength,
+// which means the sign bytes were not encoded for the configured chain.
+type HashLengthError struct {
+	Step     int8
+	Length   int
+	Expected int
+}
+
+func (e *HashLengthError) Error() string {
+	return fmt.Sprintf("%s value has length %d, expected %d", signType(e.Step), e.Length, e.Expected)
+}
+
+func newHashLengthError(step int8, length, expected int) *HashLengthError {
+	return &HashLengthError{
+		Step:     step,
+		Length:   length,
+		Expected: expected,
+	}
+}
+
+// UnknownValueTypeError is returned when a ValueExtractor returns a value of an unknown type.
+type UnknownValueTypeError struct {
+	Type ValueType
+}
+
+func (e *UnknownValueTypeError) Error() string {
+	return fmt.Sprintf("unknown value type %q", e.Type)
+}
+
+func newUnknownValueTypeError(valueType ValueType) *UnknownValueTypeError {
+	return &UnknownValueTypeError{Type: valueType}
+}